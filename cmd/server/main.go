@@ -9,10 +9,13 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	// Embedded zone database for the per-owner business timezone; slim images ship without one.
+	_ "time/tzdata"
 
 	"barberpos-backend/internal/config"
 	"barberpos-backend/internal/db"
 	"barberpos-backend/internal/handler"
+	"barberpos-backend/internal/payment"
 	"barberpos-backend/internal/repository"
 	"barberpos-backend/internal/server"
 	"barberpos-backend/internal/service"
@@ -67,18 +70,38 @@ func main() {
 	categoryRepo := repository.CategoryRepository{DB: pg}
 	customerRepo := repository.CustomerRepository{DB: pg}
 	regionRepo := repository.RegionRepository{DB: pg}
-	settingsRepo := repository.SettingsRepository{DB: pg}
+	settingsRepo := repository.SettingsRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
 	financeRepo := repository.FinanceRepository{DB: pg}
 	membershipRepo := repository.MembershipRepository{DB: pg}
 	stockRepo := repository.StockRepository{DB: pg}
 	employeeRepo := repository.EmployeeRepository{DB: pg}
 	fcmRepo := repository.FCMRepository{DB: pg}
 	notificationRepo := repository.NotificationRepository{DB: pg}
-	txRepo := repository.TransactionRepository{DB: pg}
+	txRepo := repository.TransactionRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
 	attendanceRepo := repository.AttendanceRepository{DB: pg}
-	dashboardRepo := repository.DashboardRepository{DB: pg}
-	closingRepo := repository.ClosingRepository{DB: pg}
+	dashboardRepo := repository.DashboardRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
+	closingRepo := repository.ClosingRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
 	activityLogRepo := repository.ActivityLogRepository{DB: pg}
+	promotionRepo := repository.PromotionRepository{DB: pg}
+	printJobRepo := repository.PrintJobRepository{DB: pg}
+	draftRepo := repository.DraftOrderRepository{DB: pg}
+	paymentIntentRepo := repository.PaymentIntentRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
+	cashDrawerRepo := repository.CashDrawerRepository{DB: pg, DefaultCurrency: cfg.DefaultCurrency}
+	shiftRepo := repository.ShiftRepository{DB: pg}
+
+	paymentProvider, err := payment.New(payment.Config{
+		Provider:      cfg.PaymentProvider,
+		ServerKey:     cfg.PaymentServerKey,
+		BaseURL:       cfg.PaymentBaseURL,
+		WebhookSecret: cfg.PaymentWebhookKey,
+	})
+	if err != nil {
+		logger.Error("failed to init payment provider", "provider", cfg.PaymentProvider, "err", err)
+		os.Exit(1)
+	}
+	if paymentProvider.Name() == "fake" && cfg.Env == "production" {
+		logger.Warn("payment provider is the local fake; non-cash payments are not collected")
+	}
 
 	// services
 	authSvc := service.AuthService{
@@ -89,6 +112,26 @@ func main() {
 		FirebaseAuth: firebaseAuth,
 	}
 	membershipSvc := service.MembershipService{Repo: membershipRepo}
+	pricingSvc := service.PricingService{Products: productRepo, Promotions: promotionRepo}
+	printSvc := service.PrintService{
+		Jobs:        printJobRepo,
+		Settings:    settingsRepo,
+		Logger:      logger,
+		Interval:    cfg.PrintInterval,
+		DialTimeout: cfg.PrintDialTimeout,
+		MaxAttempts: cfg.PrintMaxAttempts,
+	}
+	paymentSvc := service.PaymentService{
+		Provider:          paymentProvider,
+		Intents:           paymentIntentRepo,
+		TTL:               cfg.PaymentIntentTTL,
+		Logger:            logger,
+		Releaser:          service.SaleReleaser{Stocks: stockRepo, Promotions: promotionRepo, Membership: &membershipSvc},
+		Drafts:            draftRepo,
+		Notifications:     notificationRepo,
+		ReconcileInterval: cfg.PaymentReconcileInterval,
+		UnrecordedAfter:   cfg.PaymentUnrecordedAfter,
+	}
 
 	// handlers
 	healthHandler := handler.HealthHandler{DB: pg}
@@ -100,7 +143,7 @@ func main() {
 	regionHandler := handler.RegionHandler{Repo: regionRepo}
 	settingsHandler := handler.SettingsHandler{Repo: settingsRepo}
 	qrisHandler := handler.QRISHandler{Settings: settingsRepo, Employees: employeeRepo}
-	financeHandler := handler.FinanceHandler{Repo: financeRepo}
+	financeHandler := handler.FinanceHandler{Repo: financeRepo, Transactions: txRepo, Settings: settingsRepo}
 	membershipHandler := handler.MembershipHandler{Service: &membershipSvc, Employees: employeeRepo}
 	stockHandler := handler.StockHandler{Repo: stockRepo}
 	employeeHandler := handler.EmployeeHandler{Repo: employeeRepo}
@@ -113,14 +156,34 @@ func main() {
 		Employees:  employeeRepo,
 		Stocks:     stockRepo,
 		Finance:    financeRepo,
+		Pricing:    &pricingSvc,
+		Promotions: promotionRepo,
+		Settings:   settingsRepo,
+		Printer:    &printSvc,
+		Drafts:     draftRepo,
+		Payments:   &paymentSvc,
+		Shifts:     shiftRepo,
+
+		ActivityLogs: activityLogRepo,
 	}
-	attendanceHandler := handler.AttendanceHandler{Repo: attendanceRepo, Employees: employeeRepo}
+	attendanceHandler := handler.AttendanceHandler{Repo: attendanceRepo, Employees: employeeRepo, Settings: settingsRepo}
 	dashboardHandler := handler.DashboardHandler{Repo: dashboardRepo}
-	closingHandler := handler.ClosingHandler{Repo: closingRepo, Employees: employeeRepo}
+	closingHandler := handler.ClosingHandler{Repo: closingRepo, Employees: employeeRepo, Settings: settingsRepo}
+	cashDrawerHandler := handler.CashDrawerHandler{Repo: cashDrawerRepo, Employees: employeeRepo}
+	shiftHandler := handler.ShiftHandler{Repo: shiftRepo, Employees: employeeRepo}
 	activityLogHandler := handler.ActivityLogHandler{Repo: activityLogRepo, Employees: employeeRepo}
-	paymentHandler := handler.PaymentHandler{}
+	paymentHandler := handler.PaymentHandler{Service: &paymentSvc, Drafts: draftRepo, Employees: employeeRepo}
 	homeHandler := handler.HomeHandler{}
 	docsHandler := handler.DocsHandler{OpenAPIPath: "openapi.yaml"}
+	promotionHandler := handler.PromotionHandler{Repo: promotionRepo}
+	printJobHandler := handler.PrintJobHandler{
+		Service:      &printSvc,
+		Jobs:         printJobRepo,
+		Transactions: txRepo,
+		Closing:      closingRepo,
+		Employees:    employeeRepo,
+		Settings:     settingsRepo,
+	}
 
 	// Best-effort bootstrap: ensure core reference data exists so fresh installs aren't empty.
 	// These are idempotent and safe to run on every start.
@@ -131,7 +194,12 @@ func main() {
 		logger.Warn("bootstrap stocks sync failed", "err", err)
 	}
 
-	router := server.NewRouter(cfg, logger, healthHandler, authHandler, productHandler, productAdminHandler, categoryHandler, customerHandler, regionHandler, settingsHandler, qrisHandler, financeHandler, membershipHandler, transactionHandler, attendanceHandler, dashboardHandler, closingHandler, activityLogHandler, paymentHandler, fcmHandler, notificationHandler, stockHandler, employeeHandler, docsHandler, homeHandler)
+	router := server.NewRouter(cfg, logger, healthHandler, authHandler, productHandler, productAdminHandler, categoryHandler, customerHandler, regionHandler, settingsHandler, qrisHandler, financeHandler, membershipHandler, transactionHandler, attendanceHandler, dashboardHandler, closingHandler, activityLogHandler, paymentHandler, fcmHandler, notificationHandler, stockHandler, employeeHandler, docsHandler, homeHandler, promotionHandler, printJobHandler, cashDrawerHandler, shiftHandler)
+
+	// Delivers queued ESC/POS jobs to network printers until shutdown.
+	go printSvc.Run(ctx)
+	// Checks pending payment intents with the provider, expires stale ones and reports unrecorded payments.
+	go paymentSvc.Run(ctx)
 
 	if err := server.Start(ctx, cfg, router, logger); err != nil {
 		logger.Error("server error", "err", err)
@@ -27,6 +27,19 @@ type Config struct {
 	WriteTimeout      time.Duration
 	IdleTimeout       time.Duration
 	ShutdownTimeout   time.Duration
+	PrintInterval     time.Duration
+	PrintDialTimeout  time.Duration
+	PrintMaxAttempts  int
+	PaymentProvider   string
+	PaymentServerKey  string
+	PaymentBaseURL    string
+	PaymentWebhookKey string
+	PaymentIntentTTL  time.Duration
+
+	// PaymentReconcileInterval paces the background check of pending intents; PaymentUnrecordedAfter is how
+	// long a paid intent may go without a sale before the manager is notified.
+	PaymentReconcileInterval time.Duration
+	PaymentUnrecordedAfter   time.Duration
 }
 
 // Load reads environment variables and .env (if present).
@@ -50,6 +63,17 @@ func Load() (Config, error) {
 		WriteTimeout:      getDuration("HTTP_WRITE_TIMEOUT", 15*time.Second),
 		IdleTimeout:       getDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
 		ShutdownTimeout:   getDuration("HTTP_SHUTDOWN_TIMEOUT", 10*time.Second),
+		PrintInterval:     getDuration("PRINT_QUEUE_INTERVAL", 2*time.Second),
+		PrintDialTimeout:  getDuration("PRINT_DIAL_TIMEOUT", 5*time.Second),
+		PrintMaxAttempts:  getInt("PRINT_MAX_ATTEMPTS", 5),
+		PaymentProvider:   getEnv("PAYMENT_PROVIDER", "fake"),
+		PaymentServerKey:  os.Getenv("PAYMENT_SERVER_KEY"),
+		PaymentBaseURL:    os.Getenv("PAYMENT_BASE_URL"),
+		PaymentWebhookKey: os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+		PaymentIntentTTL:  getDuration("PAYMENT_INTENT_TTL", 15*time.Minute),
+
+		PaymentReconcileInterval: getDuration("PAYMENT_RECONCILE_INTERVAL", time.Minute),
+		PaymentUnrecordedAfter:   getDuration("PAYMENT_UNRECORDED_AFTER", 15*time.Minute),
 	}
 
 	if cfg.DatabaseURL == "" {
@@ -84,3 +108,11 @@ func getDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return d
 }
+
+func getInt(key string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return n
+}
@@ -17,8 +17,12 @@ const (
 	AttendanceSick    AttendanceStatus = "sick"
 	AttendanceOff     AttendanceStatus = "off"
 
-	TransactionPaid   TransactionStatus = "paid"
-	TransactionRefund TransactionStatus = "refund"
+	TransactionPending           TransactionStatus = "pending" // paid with a payment intent that has not settled
+	TransactionPaid              TransactionStatus = "paid"
+	TransactionPartiallyRefunded TransactionStatus = "partially_refunded"
+	TransactionRefund            TransactionStatus = "refund"
+	TransactionVoid              TransactionStatus = "void"
+	TransactionFailed            TransactionStatus = "failed" // the pending payment did not go through
 
 	FinanceRevenue FinanceEntryType = "revenue"
 	FinanceExpense FinanceEntryType = "expense"
@@ -26,6 +30,56 @@ const (
 	NotificationInfo    NotificationType = "info"
 	NotificationWarning NotificationType = "warning"
 	NotificationError   NotificationType = "error"
+
+	DiscountPercent DiscountKind = "percent"
+	DiscountFixed   DiscountKind = "fixed"
+
+	DiscountScopeOrder DiscountScope = "order"
+	DiscountScopeItem  DiscountScope = "item"
+
+	ReceiptDateNone  ReceiptDateFormat = "none"
+	ReceiptDateYear  ReceiptDateFormat = "yyyy"
+	ReceiptDateMonth ReceiptDateFormat = "yyyymm"
+	ReceiptDateDay   ReceiptDateFormat = "yyyymmdd"
+
+	ReceiptResetDaily  ReceiptReset = "daily"
+	ReceiptResetYearly ReceiptReset = "yearly"
+
+	RoundingNearest RoundingMode = "nearest"
+	RoundingUp      RoundingMode = "up"
+	RoundingDown    RoundingMode = "down"
+
+	PrintJobQueued PrintJobStatus = "queued"
+	PrintJobSent   PrintJobStatus = "sent"
+	PrintJobFailed PrintJobStatus = "failed"
+
+	PrintJobReceipt PrintJobKind = "receipt"
+	PrintJobClosing PrintJobKind = "closing"
+	PrintJobTest    PrintJobKind = "test"
+
+	DraftOpen      DraftStatus = "open"
+	DraftPaid      DraftStatus = "paid"
+	DraftCancelled DraftStatus = "cancelled"
+
+	PaymentIntentPending   PaymentIntentStatus = "pending"
+	PaymentIntentPaid      PaymentIntentStatus = "paid"
+	PaymentIntentFailed    PaymentIntentStatus = "failed"
+	PaymentIntentCancelled PaymentIntentStatus = "cancelled"
+	PaymentIntentExpired   PaymentIntentStatus = "expired"
+	PaymentIntentRefunded  PaymentIntentStatus = "refunded"
+
+	CashDrawerOpen   CashDrawerStatus = "open"
+	CashDrawerClosed CashDrawerStatus = "closed"
+
+	CashPayIn  CashMovementKind = "pay_in"
+	CashPayOut CashMovementKind = "pay_out"
+
+	ShiftOpen   ShiftStatus = "open"
+	ShiftClosed ShiftStatus = "closed"
+
+	ShiftPolicyOff    ShiftPolicy = "off"    // shiftId is optional and not checked
+	ShiftPolicyReject ShiftPolicy = "reject" // an order must name an open shift
+	ShiftPolicyAuto   ShiftPolicy = "auto"   // an order that names none joins the terminal's open shift
 )
 
 type UserRole string
@@ -34,6 +88,19 @@ type AttendanceStatus string
 type TransactionStatus string
 type FinanceEntryType string
 type NotificationType string
+type DiscountKind string
+type DiscountScope string
+type ReceiptDateFormat string
+type ReceiptReset string
+type RoundingMode string
+type PrintJobStatus string
+type PrintJobKind string
+type DraftStatus string
+type PaymentIntentStatus string
+type CashDrawerStatus string
+type CashMovementKind string
+type ShiftStatus string
+type ShiftPolicy string
 
 type Money struct {
 	Amount   int64
@@ -106,9 +173,23 @@ type Settings struct {
 	Notifications        bool
 	TrackStock           bool
 	RoundingPrice        bool
+	RoundingUnit         int64        // cash is rounded to a multiple of this (100, 500 or 1000)
+	RoundingMode         RoundingMode // nearest, up or down
 	AutoBackup           bool
 	CashierPin           bool
 	CurrencyCode         string
+	TaxRate              float64 // percent, e.g. 11 for PPN 11%
+	TaxInclusive         bool    // catalog prices already include tax
+	ServiceChargeRate    float64 // percent, charged on the discounted subtotal
+	ReceiptPrefix        string
+	ReceiptDateFormat    ReceiptDateFormat
+	ReceiptReset         ReceiptReset
+	ReceiptDigits        int      // zero-padded width of the running number
+	VoidReasons          []string // reason codes offered when voiding a transaction
+	Timezone             string   // IANA name, e.g. Asia/Makassar; business dates follow it
+	ShiftPolicy          ShiftPolicy
+	AllowCustomItems     bool
+	AllowManualDiscounts bool // staff may give line and order discounts by hand
 	UpdatedAt            time.Time
 }
 
@@ -122,15 +203,18 @@ type Category struct {
 }
 
 type Customer struct {
-	ID        int64
-	TenantID  *int64
-	Name      string
-	Phone     string
-	Email     string
-	Address   string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time
+	ID            int64
+	TenantID      *int64
+	Name          string
+	Phone         string
+	Email         string
+	Address       string
+	Visits        int        // non-void transactions linked to this customer
+	LastVisit     *time.Time // date of the latest of those
+	LifetimeSpend Money      // charged net of refunds, without tips
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time
 }
 
 type Product struct {
@@ -164,21 +248,21 @@ type Stock struct {
 }
 
 type Employee struct {
-	ID         int64
-	TenantID   *int64
-	ManagerID  *int64
-	Name       string
-	Role       string
+	ID             int64
+	TenantID       *int64
+	ManagerID      *int64
+	Name           string
+	Role           string
 	AllowedModules []string
-	Phone      string
-	Email      string
-	PinHash    *string
-	JoinDate   time.Time
-	Commission *float64
-	Active     bool
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	DeletedAt  *time.Time
+	Phone          string
+	Email          string
+	PinHash        *string
+	JoinDate       time.Time
+	Commission     *float64
+	Active         bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time
 }
 
 type Attendance struct {
@@ -196,20 +280,20 @@ type Attendance struct {
 }
 
 type FinanceEntry struct {
-	ID        int64
-	TenantID  *int64
-	Title     string
-	Amount    Money
-	Category  string
-	Date      time.Time
-	Type      FinanceEntryType
-	Note      string
-	TransactionID *int64
+	ID              int64
+	TenantID        *int64
+	Title           string
+	Amount          Money
+	Category        string
+	Date            time.Time
+	Type            FinanceEntryType
+	Note            string
+	TransactionID   *int64
 	TransactionCode *string
-	Staff     *string
-	Service   *string
-	CreatedAt time.Time
-	DeletedAt *time.Time
+	Staff           *string
+	Service         *string
+	CreatedAt       time.Time
+	DeletedAt       *time.Time
 }
 
 type MembershipState struct {
@@ -246,29 +330,49 @@ type Notification struct {
 }
 
 type Transaction struct {
-	ID               int64
-	TenantID         *int64
-	Code             string
-	Date             time.Time
-	Time             string
-	Amount           Money
-	PaymentMethod    string
-	ShiftID          *string
-	OperatorName     string
-	StylistID        *int64
-	PaymentIntentID  *string
-	PaymentReference *string
-	Status           TransactionStatus
-	RefundedAt       *time.Time
-	RefundedBy       *int64
-	RefundNote       string
-	Stylist          string
-	CustomerID       *int64
-	Customer         *TransactionCustomerSnapshot
-	Items            []TransactionItem
-	DeletedAt        *time.Time
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	ID                int64
+	TenantID          *int64
+	Code              string // internal id (ORD-<millis>)
+	ReceiptNumber     string // customer-facing, sequential per owner
+	Date              time.Time
+	Time              string
+	Amount            Money
+	Subtotal          Money
+	DiscountTotal     Money
+	ServiceCharge     Money
+	TaxAmount         Money
+	TaxRate           float64
+	TaxInclusive      bool
+	ServiceChargeRate float64
+	PaymentMethod     string
+	ShiftID           *string
+	OperatorName      string
+	StylistID         *int64
+	PaymentIntentID   *string
+	PaymentReference  *string
+	Status            TransactionStatus
+	RefundedAt        *time.Time
+	RefundedBy        *int64
+	RefundNote        string
+	RefundedAmount    Money
+	Refunds           []Refund
+	Stylist           string
+	CustomerID        *int64
+	Customer          *TransactionCustomerSnapshot
+	Items             []TransactionItem
+	Discounts         []TransactionDiscount
+	Payments          []TransactionPayment
+	TipTotal          Money
+	Tips              []TransactionTip
+	Rounding          Money // cash rounding added to the cash tender, negative when rounded down; not part of Amount
+	VoidedAt          *time.Time
+	VoidedBy          *int64
+	VoidApprovedBy    *int64 // manager who approved a void started by staff
+	VoidReason        string
+	VoidNote          string
+	DeletedAt         *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 type TransactionCustomerSnapshot struct {
@@ -290,10 +394,214 @@ type TransactionItem struct {
 	CategoryID    *int64
 	Price         Money
 	Qty           int
+	Discount      Money
+	RefundedQty   int
+	StylistID     *int64 // who did this line; the transaction's stylist unless set per line
+	Stylist       string
+	Custom        bool // rung up without a product, at the cashier's price
 	CreatedAt     time.Time
 	DeletedAt     *time.Time
 }
 
+// Refund is one refund against a transaction; a transaction can be refunded in several parts.
+type Refund struct {
+	ID            int64
+	TransactionID int64
+	Amount        Money
+	Units         int
+	Note          string
+	RefundedBy    *int64
+	Items         []RefundLine
+	ReversedAt    *time.Time
+	CreatedAt     time.Time
+}
+
+type RefundLine struct {
+	TransactionItemID int64
+	ProductID         *int64
+	Qty               int
+}
+
+// TransactionTip is the part of a transaction's tip owed to one stylist. Tips are not service revenue.
+type TransactionTip struct {
+	ID            int64
+	TransactionID int64
+	StylistID     *int64
+	Stylist       string
+	Amount        Money
+	CreatedAt     time.Time
+}
+
+// TransactionPayment is one tender applied to a transaction; split payments have several.
+type TransactionPayment struct {
+	ID              int64
+	TransactionID   int64
+	Method          string
+	Amount          Money
+	Reference       *string
+	PaymentIntentID *string
+	CreatedAt       time.Time
+}
+
+type TransactionDiscount struct {
+	ID                int64
+	TransactionID     int64
+	TransactionItemID *int64
+	PromotionID       *int64
+	Code              string
+	Kind              DiscountKind
+	Scope             DiscountScope
+	Value             int64
+	Amount            Money
+	Note              string
+	CreatedAt         time.Time
+}
+
+type Promotion struct {
+	ID          int64
+	TenantID    *int64
+	Code        string
+	Name        string
+	Kind        DiscountKind
+	Scope       DiscountScope
+	Value       int64
+	ProductID   *int64
+	MinSpend    Money
+	MaxDiscount *int64
+	StartsAt    *time.Time
+	EndsAt      *time.Time
+	UsageLimit  *int
+	UsedCount   int
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+}
+
+// DraftOrder is an open ticket built up while the customer is served; paying it records a Transaction.
+type DraftOrder struct {
+	ID            int64
+	Status        DraftStatus
+	CustomerName  string
+	CustomerID    *int64
+	CustomerPhone string
+	Stylist       string
+	StylistID     *int64
+	ShiftID       *string
+	OperatorName  string
+	Note          string
+	CreatedBy     *int64
+	TransactionID *int64
+	Lines         []DraftOrderLine
+	PaidAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// DraftOrderLine is one service or product on a draft; the discount is the cashier's manual one, if any.
+type DraftOrderLine struct {
+	ID            int64
+	DraftID       int64
+	ProductID     *int64
+	Name          string
+	Category      string
+	Price         Money
+	Qty           int
+	DiscountType  string
+	DiscountValue int64
+	DiscountNote  string
+	StylistID     *int64
+	Stylist       string
+	CreatedAt     time.Time
+}
+
+// PrintJob is an ESC/POS byte stream queued for a network printer.
+type PrintJob struct {
+	ID            int64
+	Kind          PrintJobKind
+	Reference     string // transaction code for receipts
+	PrinterHost   string
+	PrinterPort   int
+	Payload       []byte
+	Status        PrintJobStatus
+	Attempts      int
+	MaxAttempts   int
+	LastError     string
+	NextAttemptAt time.Time
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// PaymentIntent is one non-cash tender being collected through a payment provider. Orders reference it by ID
+// once it is paid.
+type PaymentIntent struct {
+	ID             string
+	Provider       string
+	ProviderRef    string
+	Method         string // qris or card
+	Amount         Money
+	Status         PaymentIntentStatus
+	QRString       string
+	Reference      string
+	RefundedAmount Money
+	DraftID        *int64
+	TransactionID  *int64
+	CreatedBy      *int64
+	ExpiresAt      time.Time
+	PaidAt         *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// CashDrawerSession is the till for one shift: the float it opened with, cash put in or taken out, and at
+// closing what should have been in it against what was counted.
+type CashDrawerSession struct {
+	ID           int64
+	ShiftID      string
+	OpeningFloat Money
+	Status       CashDrawerStatus
+	Note         string
+	OpenedBy     *int64
+	OpenedAt     time.Time
+	ClosedBy     *int64
+	ClosedAt     *time.Time
+	ExpectedCash *int64 // set at closing, in OpeningFloat's currency
+	CountedCash  *int64
+	Variance     *int64 // counted minus expected
+	Movements    []CashMovement
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Shift is one operator's turn at a terminal, from opening to closing. Sales, drafts, the cash drawer and
+// closings refer to it by ID; a terminal has at most one open shift.
+type Shift struct {
+	ID           string
+	TerminalID   string
+	OperatorName string
+	Status       ShiftStatus
+	Note         string
+	OpenedBy     *int64
+	OpenedAt     time.Time
+	ClosedBy     *int64
+	ClosedAt     *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// CashMovement is cash put into or taken out of the drawer outside a sale, e.g. buying supplies from the till.
+type CashMovement struct {
+	ID        int64
+	SessionID int64
+	Kind      CashMovementKind
+	Amount    Money
+	Reason    string
+	Note      string
+	CreatedBy *int64
+	CreatedAt time.Time
+}
+
 type ClosingHistory struct {
 	ID           int64
 	TenantID     *int64
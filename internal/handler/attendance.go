@@ -14,6 +14,7 @@ import (
 type AttendanceHandler struct {
 	Repo      repository.AttendanceRepository
 	Employees repository.EmployeeRepository
+	Settings  repository.SettingsRepository
 }
 
 func (h AttendanceHandler) RegisterRoutes(r chi.Router) {
@@ -48,7 +49,12 @@ func (h AttendanceHandler) checkIn(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "employeeName is required")
 		return
 	}
-	date := time.Now()
+	// Without an explicit date the shift counts for the shop's business day, not the server's.
+	date, err := h.Settings.Today(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	if req.Date != "" {
 		if t, err := time.Parse("2006-01-02", req.Date); err == nil {
 			date = t
@@ -86,7 +92,12 @@ func (h AttendanceHandler) checkOut(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "employeeName is required")
 		return
 	}
-	date := time.Now()
+	// Without an explicit date the shift counts for the shop's business day, not the server's.
+	date, err := h.Settings.Today(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	if req.Date != "" {
 		if t, err := time.Parse("2006-01-02", req.Date); err == nil {
 			date = t
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"github.com/go-chi/chi/v5"
+)
+
+// CashDrawerHandler keeps the till of each shift: the float it opens with and cash paid in or out between
+// sales. The drawer is counted and closed through POST /closing.
+type CashDrawerHandler struct {
+	Repo      repository.CashDrawerRepository
+	Employees repository.EmployeeRepository
+}
+
+func (h CashDrawerHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/cash-drawers", h.list)
+	r.Post("/cash-drawers", h.open)
+	r.Get("/cash-drawers/{id}", h.get)
+	r.Post("/cash-drawers/{id}/movements", h.addMovement)
+}
+
+func (h CashDrawerHandler) open(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		ShiftID      string `json:"shiftId"`
+		OpeningFloat int64  `json:"openingFloat"`
+		Note         string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	req.ShiftID = strings.TrimSpace(req.ShiftID)
+	if req.ShiftID == "" {
+		writeError(w, http.StatusBadRequest, "shiftId is required")
+		return
+	}
+	if req.OpeningFloat < 0 {
+		writeError(w, http.StatusBadRequest, "openingFloat must not be negative")
+		return
+	}
+	s, err := h.Repo.Open(r.Context(), ownerID, repository.OpenCashDrawerInput{
+		ShiftID:      req.ShiftID,
+		OpeningFloat: req.OpeningFloat,
+		Note:         strings.TrimSpace(req.Note),
+		OpenedBy:     &user.ID,
+	})
+	if err != nil {
+		writeCashDrawerError(w, err)
+		return
+	}
+	s.Movements = []domain.CashMovement{}
+	h.writeDrawer(w, r, ownerID, http.StatusCreated, s)
+}
+
+// list returns drawers newest first; shiftId narrows it to that shift's drawer, status to open or closed.
+func (h CashDrawerHandler) list(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	if shiftID := strings.TrimSpace(q.Get("shiftId")); shiftID != "" {
+		s, err := h.Repo.GetByShift(r.Context(), ownerID, shiftID)
+		if errors.Is(err, repository.ErrNotFound) {
+			writeJSON(w, http.StatusOK, []map[string]any{})
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, []map[string]any{toCashDrawerResponse(*s, nil)})
+		return
+	}
+	status := strings.ToLower(strings.TrimSpace(q.Get("status")))
+	if status != "" && status != string(domain.CashDrawerOpen) && status != string(domain.CashDrawerClosed) {
+		writeError(w, http.StatusBadRequest, "status must be open or closed")
+		return
+	}
+	limit := 50
+	if parsed, err := strconv.Atoi(q.Get("limit")); err == nil && parsed > 0 && parsed <= 200 {
+		limit = parsed
+	}
+	items, err := h.Repo.List(r.Context(), ownerID, status, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(items))
+	for _, s := range items {
+		resp = append(resp, toCashDrawerResponse(s, nil))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// get returns a drawer with its movements and the running expected cash.
+func (h CashDrawerHandler) get(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	s, err := h.Repo.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeCashDrawerError(w, err)
+		return
+	}
+	h.writeDrawer(w, r, ownerID, http.StatusOK, s)
+}
+
+func (h CashDrawerHandler) addMovement(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req struct {
+		Type   string `json:"type"`
+		Amount int64  `json:"amount"`
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	kind := domain.CashMovementKind(strings.ToLower(strings.TrimSpace(req.Type)))
+	if kind != domain.CashPayIn && kind != domain.CashPayOut {
+		writeError(w, http.StatusBadRequest, "type must be pay_in or pay_out")
+		return
+	}
+	if req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 100 {
+		writeError(w, http.StatusBadRequest, "reason is required, at most 100 characters")
+		return
+	}
+	m, err := h.Repo.AddMovement(r.Context(), ownerID, id, repository.CashMovementInput{
+		Kind:      kind,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		Note:      strings.TrimSpace(req.Note),
+		CreatedBy: &user.ID,
+	})
+	if err != nil {
+		writeCashDrawerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toCashMovementResponse(*m))
+}
+
+func (h CashDrawerHandler) writeDrawer(w http.ResponseWriter, r *http.Request, ownerID int64, status int, s *domain.CashDrawerSession) {
+	totals, err := h.Repo.Totals(r.Context(), ownerID, *s)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, status, toCashDrawerResponse(*s, &totals))
+}
+
+func writeCashDrawerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "cash drawer not found")
+	case errors.Is(err, repository.ErrCashDrawerExists), errors.Is(err, repository.ErrCashDrawerClosed):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// toCashDrawerResponse includes movements when they were loaded and the expected-cash breakdown when given.
+// Once closed, expectedCash is the figure fixed at closing; the breakdown is recomputed from current records.
+func toCashDrawerResponse(s domain.CashDrawerSession, totals *repository.CashDrawerTotals) map[string]any {
+	resp := map[string]any{
+		"id":           s.ID,
+		"shiftId":      s.ShiftID,
+		"openingFloat": s.OpeningFloat.Amount,
+		"currency":     s.OpeningFloat.Currency,
+		"status":       string(s.Status),
+		"note":         s.Note,
+		"openedBy":     s.OpenedBy,
+		"openedAt":     s.OpenedAt,
+		"closedBy":     s.ClosedBy,
+		"closedAt":     s.ClosedAt,
+		"expectedCash": s.ExpectedCash,
+		"countedCash":  s.CountedCash,
+		"variance":     s.Variance,
+	}
+	if s.Movements != nil {
+		movements := make([]map[string]any, 0, len(s.Movements))
+		for _, m := range s.Movements {
+			movements = append(movements, toCashMovementResponse(m))
+		}
+		resp["movements"] = movements
+	}
+	if totals != nil {
+		resp["totals"] = toCashDrawerTotalsResponse(*totals)
+		if s.ExpectedCash == nil {
+			resp["expectedCash"] = totals.Expected
+		}
+	}
+	return resp
+}
+
+func toCashDrawerTotalsResponse(t repository.CashDrawerTotals) map[string]any {
+	return map[string]any{
+		"openingFloat": t.OpeningFloat,
+		"cashSales":    t.CashSales,
+		"cashRefunds":  t.CashRefunds,
+		"payIns":       t.PayIns,
+		"payOuts":      t.PayOuts,
+		"expected":     t.Expected,
+		"currency":     t.Currency,
+	}
+}
+
+func toCashMovementResponse(m domain.CashMovement) map[string]any {
+	return map[string]any{
+		"id":        m.ID,
+		"sessionId": m.SessionID,
+		"type":      string(m.Kind),
+		"amount":    m.Amount.Amount,
+		"currency":  m.Amount.Currency,
+		"reason":    m.Reason,
+		"note":      m.Note,
+		"createdBy": m.CreatedBy,
+		"createdAt": m.CreatedAt,
+	}
+}
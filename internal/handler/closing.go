@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,6 +15,7 @@ import (
 type ClosingHandler struct {
 	Repo      repository.ClosingRepository
 	Employees repository.EmployeeRepository
+	Settings  repository.SettingsRepository
 }
 
 func (h ClosingHandler) RegisterRoutes(r chi.Router) {
@@ -35,13 +37,31 @@ func (h ClosingHandler) summary(w http.ResponseWriter, r *http.Request) {
 	}
 	data, err := h.Repo.Summary(r.Context(), ownerID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeSumError(w, err)
 		return
 	}
+	tenders := make([]map[string]any, 0, len(data.Tenders))
+	for _, t := range data.Tenders {
+		tenders = append(tenders, map[string]any{
+			"method": t.Method,
+			"amount": t.Amount,
+			"count":  t.Count,
+		})
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"totalCash":    data.TotalCash,
-		"totalNonCash": data.TotalNonCash,
-		"totalCard":    data.TotalCard,
+		"tenders":            tenders,
+		"totalCash":          data.TotalCash,
+		"totalNonCash":       data.TotalNonCash,
+		"totalCard":          data.TotalCard,
+		"totalDiscount":      data.TotalDiscount,
+		"totalServiceCharge": data.TotalServiceCharge,
+		"totalTax":           data.TotalTax,
+		"totalRefund":        data.TotalRefund,
+		"totalTips":          data.TotalTips,
+		"totalRounding":      data.TotalRounding,
+		"totalVoid":          data.TotalVoid,
+		"voidCount":          data.VoidCount,
+		"currency":           data.Currency,
 	})
 }
 
@@ -66,12 +86,18 @@ func (h ClosingHandler) create(w http.ResponseWriter, r *http.Request) {
 		Status       string `json:"status"`
 		Catatan      string `json:"catatan"`
 		Fisik        string `json:"fisik"`
+		// CountedCash closes the shift's cash drawer; the server works out the expected cash and variance.
+		CountedCash *int64 `json:"countedCash"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid payload")
 		return
 	}
-	date := time.Now()
+	date, err := h.Settings.Today(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	if req.Tanggal != "" {
 		if t, err := time.Parse("2006-01-02", req.Tanggal); err == nil {
 			date = t
@@ -84,7 +110,7 @@ func (h ClosingHandler) create(w http.ResponseWriter, r *http.Request) {
 	if req.Status == "" {
 		req.Status = "closed"
 	}
-	id, err := h.Repo.Create(r.Context(), ownerID, repository.CreateClosingInput{
+	in := repository.CreateClosingInput{
 		Tanggal:      date,
 		Shift:        req.Shift,
 		Karyawan:     req.Karyawan,
@@ -94,7 +120,37 @@ func (h ClosingHandler) create(w http.ResponseWriter, r *http.Request) {
 		Status:       req.Status,
 		Catatan:      req.Catatan,
 		Fisik:        req.Fisik,
-	})
+	}
+	if req.CountedCash != nil {
+		if shiftID == nil {
+			writeError(w, http.StatusBadRequest, "shiftId is required to count the cash drawer")
+			return
+		}
+		if *req.CountedCash < 0 {
+			writeError(w, http.StatusBadRequest, "countedCash must not be negative")
+			return
+		}
+		id, drawer, totals, err := h.Repo.CreateWithCount(r.Context(), ownerID, in, *req.CountedCash, &user.ID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "no cash drawer was opened for this shift")
+				return
+			}
+			writeCashDrawerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"id":           id,
+			"cashDrawerId": drawer.ID,
+			"expectedCash": drawer.ExpectedCash,
+			"countedCash":  drawer.CountedCash,
+			"variance":     drawer.Variance,
+			"totals":       toCashDrawerTotalsResponse(totals),
+		})
+		return
+	}
+	id, err := h.Repo.Create(r.Context(), ownerID, in)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -138,6 +194,10 @@ func (h ClosingHandler) list(w http.ResponseWriter, r *http.Request) {
 			"catatan":      c.Catatan,
 			"fisik":        c.Fisik,
 			"createdAt":    c.CreatedAt.Format(time.RFC3339),
+			"cashDrawerId": c.CashDrawerID,
+			"expectedCash": c.ExpectedCash,
+			"countedCash":  c.CountedCash,
+			"variance":     c.Variance,
 		})
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"barberpos-backend/internal/domain"
 	"barberpos-backend/internal/repository"
@@ -42,11 +43,14 @@ func (h CustomerHandler) list(w http.ResponseWriter, r *http.Request) {
 	resp := make([]map[string]any, 0, len(items))
 	for _, c := range items {
 		resp = append(resp, map[string]any{
-			"id":      c.ID,
-			"name":    c.Name,
-			"phone":   c.Phone,
-			"email":   c.Email,
-			"address": c.Address,
+			"id":            c.ID,
+			"name":          c.Name,
+			"phone":         c.Phone,
+			"email":         c.Email,
+			"address":       c.Address,
+			"visits":        c.Visits,
+			"lastVisit":     dateOrNil(c.LastVisit),
+			"lifetimeSpend": c.LifetimeSpend.Amount,
 		})
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -94,11 +98,14 @@ func (h CustomerHandler) upsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"id":      saved.ID,
-		"name":    saved.Name,
-		"phone":   saved.Phone,
-		"email":   saved.Email,
-		"address": saved.Address,
+		"id":            saved.ID,
+		"name":          saved.Name,
+		"phone":         saved.Phone,
+		"email":         saved.Email,
+		"address":       saved.Address,
+		"visits":        saved.Visits,
+		"lastVisit":     dateOrNil(saved.LastVisit),
+		"lifetimeSpend": saved.LifetimeSpend.Amount,
 	})
 }
 
@@ -125,3 +132,10 @@ func (h CustomerHandler) delete(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
+
+func dateOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
@@ -28,7 +28,7 @@ func (h DashboardHandler) summary(w http.ResponseWriter, r *http.Request) {
 	}
 	data, err := h.Repo.Summary(r.Context(), user.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeSumError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -39,6 +39,7 @@ func (h DashboardHandler) summary(w http.ResponseWriter, r *http.Request) {
 		"totalRevenue":      data.TotalRevenue,
 		"totalTransactions": data.TotalTransactions,
 		"todayRevenue":      data.TodayRevenue,
+		"currency":          data.Currency,
 	})
 }
 
@@ -50,7 +51,7 @@ func (h DashboardHandler) topServices(w http.ResponseWriter, r *http.Request) {
 	}
 	items, err := h.Repo.TopServices(r.Context(), user.ID, 5)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeSumError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, toDashboardItems(items))
@@ -64,7 +65,7 @@ func (h DashboardHandler) topStaff(w http.ResponseWriter, r *http.Request) {
 	}
 	items, err := h.Repo.TopStaff(r.Context(), user.ID, 5)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeSumError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, toDashboardItems(items))
@@ -88,7 +89,7 @@ func (h DashboardHandler) sales(w http.ResponseWriter, r *http.Request) {
 	}
 	points, err := h.Repo.SalesSeries(r.Context(), user.ID, days)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeSumError(w, err)
 		return
 	}
 	resp := make([]map[string]any, 0, len(points))
@@ -105,10 +106,12 @@ func toDashboardItems(items []repository.DashboardItem) []map[string]any {
 	out := make([]map[string]any, 0, len(items))
 	for _, it := range items {
 		out = append(out, map[string]any{
-			"name":   it.Name,
-			"amount": it.Amount,
-			"qty":    it.Count,
-			"count":  it.Count,
+			"name":     it.Name,
+			"amount":   it.Amount,
+			"gross":    it.Gross,
+			"discount": it.Discount,
+			"qty":      it.Count,
+			"count":    it.Count,
 		})
 	}
 	return out
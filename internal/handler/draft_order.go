@@ -0,0 +1,502 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Drafts are open tickets: services are added while the customer is in the chair, and nothing
+// touches stock, promotions or membership quota until the ticket is paid through placeOrder.
+
+type draftPayload struct {
+	Customer      string      `json:"customer"`
+	CustomerID    *int64      `json:"customerId"`
+	CustomerPhone string      `json:"customerPhone"`
+	Stylist       string      `json:"stylist"`
+	StylistID     *int64      `json:"stylistId"`
+	ShiftID       string      `json:"shiftId"`
+	OperatorName  string      `json:"operatorName"`
+	Note          string      `json:"note"`
+	Items         []orderLine `json:"items"`
+}
+
+// draftPayPayload is the checkout half of orderPayload; the lines and stylist come from the draft.
+type draftPayPayload struct {
+	ClientRef     string      `json:"clientRef"`
+	Total         int64       `json:"total"`
+	Paid          int64       `json:"paid"`
+	Change        int64       `json:"change"`
+	PaymentMethod string      `json:"paymentMethod"`
+	PromoCode     string      `json:"promoCode"`
+	PromoCodes    []string    `json:"promoCodes"`
+	Discount      *discountIn `json:"discount"`
+	Payments      []paymentIn `json:"payments"`
+	Tip           int64       `json:"tip"`
+	Tips          []tipIn     `json:"tips"`
+
+	PaymentIntentID string `json:"paymentIntentId"`
+	// ShiftID and TerminalID are the paying device's; when shiftId is empty the draft's own shift is used.
+	ShiftID    string `json:"shiftId"`
+	TerminalID string `json:"terminalId"`
+}
+
+func (h TransactionHandler) draftOwner(w http.ResponseWriter, r *http.Request) (*authctx.CurrentUser, int64, bool) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return nil, 0, false
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, 0, false
+	}
+	return user, ownerID, true
+}
+
+func draftIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid draft id")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeDraftError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "draft not found")
+	case errors.Is(err, repository.ErrDraftNotOpen):
+		writeError(w, http.StatusConflict, "draft is not open")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// resolveStylist fills the stylist name from the employee record when only the id was sent.
+func (h TransactionHandler) resolveStylist(ctx context.Context, ownerID int64, name string, id *int64) (string, error) {
+	name = strings.TrimSpace(name)
+	if id == nil {
+		return name, nil
+	}
+	emp, err := h.Employees.Get(ctx, ownerID, *id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", &orderError{Status: http.StatusBadRequest, Message: "stylist not found"}
+		}
+		return "", err
+	}
+	if name == "" {
+		name = emp.Name
+	}
+	return name, nil
+}
+
+func toDraftLineInput(l orderLine) (repository.DraftLineInput, string) {
+	if strings.TrimSpace(l.Name) == "" {
+		return repository.DraftLineInput{}, "item name is required"
+	}
+	if l.Qty <= 0 {
+		return repository.DraftLineInput{}, "item qty must be positive"
+	}
+	if l.Price < 0 {
+		return repository.DraftLineInput{}, "item price must not be negative"
+	}
+	in := repository.DraftLineInput{
+		ProductID: l.ProductID,
+		Name:      strings.TrimSpace(l.Name),
+		Category:  l.Category,
+		Price:     l.Price,
+		Qty:       l.Qty,
+		StylistID: l.StylistID,
+		Stylist:   strings.TrimSpace(l.Stylist),
+	}
+	if l.Discount != nil {
+		in.DiscountType = strings.ToLower(strings.TrimSpace(l.Discount.Type))
+		in.DiscountValue = l.Discount.Value
+		in.DiscountNote = l.Discount.Note
+	}
+	return in, ""
+}
+
+func (h TransactionHandler) createDraft(w http.ResponseWriter, r *http.Request) {
+	user, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	var req draftPayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	stylist, err := h.resolveStylist(r.Context(), ownerID, req.Stylist, req.StylistID)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	lines := make([]repository.DraftLineInput, 0, len(req.Items))
+	for _, it := range req.Items {
+		line, msg := toDraftLineInput(it)
+		if msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		lines = append(lines, line)
+	}
+	d, err := h.Drafts.Create(r.Context(), ownerID, repository.CreateDraftInput{
+		CustomerName:  strings.TrimSpace(req.Customer),
+		CustomerID:    req.CustomerID,
+		CustomerPhone: repository.NormalizePhone(req.CustomerPhone),
+		Stylist:       stylist,
+		StylistID:     req.StylistID,
+		ShiftID:       strPtr(req.ShiftID),
+		OperatorName:  strings.TrimSpace(req.OperatorName),
+		Note:          req.Note,
+		CreatedBy:     &user.ID,
+		Lines:         lines,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toDraftResponse(*d))
+}
+
+func (h TransactionHandler) listDrafts(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	drafts, err := h.Drafts.ListOpen(r.Context(), ownerID, strings.TrimSpace(r.URL.Query().Get("shiftId")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(drafts))
+	for _, d := range drafts {
+		resp = append(resp, toDraftResponse(d))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h TransactionHandler) getDraft(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.Drafts.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDraftResponse(*d))
+}
+
+// updateDraft changes the ticket header; fields left out of the body keep their value.
+func (h TransactionHandler) updateDraft(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Customer      *string `json:"customer"`
+		CustomerID    *int64  `json:"customerId"`
+		CustomerPhone *string `json:"customerPhone"`
+		Stylist       *string `json:"stylist"`
+		StylistID     *int64  `json:"stylistId"`
+		Note          *string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	d, err := h.Drafts.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	in := repository.UpdateDraftInput{
+		CustomerName:  d.CustomerName,
+		CustomerID:    d.CustomerID,
+		CustomerPhone: d.CustomerPhone,
+		Stylist:       d.Stylist,
+		StylistID:     d.StylistID,
+		Note:          d.Note,
+	}
+	if req.Customer != nil {
+		in.CustomerName = strings.TrimSpace(*req.Customer)
+	}
+	if req.CustomerID != nil || req.CustomerPhone != nil {
+		// Like the stylist, a new customer link replaces both id and phone.
+		in.CustomerID = req.CustomerID
+		in.CustomerPhone = ""
+		if req.CustomerPhone != nil {
+			in.CustomerPhone = repository.NormalizePhone(*req.CustomerPhone)
+		}
+	}
+	if req.StylistID != nil || req.Stylist != nil {
+		// Assigning a stylist replaces both name and id so they never point at different people.
+		name := ""
+		if req.Stylist != nil {
+			name = *req.Stylist
+		}
+		in.StylistID = req.StylistID
+		in.Stylist, err = h.resolveStylist(r.Context(), ownerID, name, req.StylistID)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+	}
+	if req.Note != nil {
+		in.Note = *req.Note
+	}
+	d, err = h.Drafts.Update(r.Context(), ownerID, id, in)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDraftResponse(*d))
+}
+
+func (h TransactionHandler) addDraftLine(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	var req orderLine
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	line, msg := toDraftLineInput(req)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if _, err := h.Drafts.AddLine(r.Context(), ownerID, id, line); err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	d, err := h.Drafts.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toDraftResponse(*d))
+}
+
+func (h TransactionHandler) removeDraftLine(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	lineID, err := strconv.ParseInt(chi.URLParam(r, "lineId"), 10, 64)
+	if err != nil || lineID <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid line id")
+		return
+	}
+	if err := h.Drafts.RemoveLine(r.Context(), ownerID, id, lineID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "draft line not found")
+			return
+		}
+		writeDraftError(w, err)
+		return
+	}
+	d, err := h.Drafts.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDraftResponse(*d))
+}
+
+func (h TransactionHandler) cancelDraft(w http.ResponseWriter, r *http.Request) {
+	_, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Drafts.Cancel(r.Context(), ownerID, id); err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": strconv.FormatInt(id, 10), "status": string(domain.DraftCancelled)})
+}
+
+// payDraft turns the ticket into a transaction through the regular order path and closes the draft
+// in the same database transaction. Retries reuse the clientRef, so a draft is charged at most once.
+func (h TransactionHandler) payDraft(w http.ResponseWriter, r *http.Request) {
+	user, ownerID, ok := h.draftOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := draftIDParam(w, r)
+	if !ok {
+		return
+	}
+	var pay draftPayPayload
+	if err := json.NewDecoder(r.Body).Decode(&pay); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	d, err := h.Drafts.Get(r.Context(), ownerID, id)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	if d.Status != domain.DraftOpen {
+		writeErrorData(w, http.StatusConflict, "draft is not open", map[string]any{
+			"status":        string(d.Status),
+			"transactionId": d.TransactionID,
+		})
+		return
+	}
+	if len(d.Lines) == 0 {
+		writeError(w, http.StatusBadRequest, "draft has no items")
+		return
+	}
+
+	req := orderPayload{
+		ClientRef:     pay.ClientRef,
+		Total:         pay.Total,
+		Paid:          pay.Paid,
+		Change:        pay.Change,
+		PaymentMethod: pay.PaymentMethod,
+		Stylist:       d.Stylist,
+		StylistID:     d.StylistID,
+		Customer:      d.CustomerName,
+		CustomerID:    d.CustomerID,
+		CustomerPhone: d.CustomerPhone,
+		PromoCode:     pay.PromoCode,
+		PromoCodes:    pay.PromoCodes,
+		Discount:      pay.Discount,
+		Payments:      pay.Payments,
+		Tip:           pay.Tip,
+		Tips:          pay.Tips,
+
+		PaymentIntentID: pay.PaymentIntentID,
+		ShiftID:         strings.TrimSpace(pay.ShiftID),
+		TerminalID:      pay.TerminalID,
+		byManager:       isManager(*user),
+	}
+	if req.ShiftID == "" && d.ShiftID != nil {
+		req.ShiftID = *d.ShiftID
+	}
+	if req.ClientRef == "" {
+		req.ClientRef = "draft:" + strconv.FormatInt(d.ID, 10)
+	}
+	for _, l := range d.Lines {
+		line := orderLine{
+			ProductID: l.ProductID,
+			Name:      l.Name,
+			Category:  l.Category,
+			Price:     l.Price.Amount,
+			Qty:       l.Qty,
+			StylistID: l.StylistID,
+			Stylist:   l.Stylist,
+		}
+		if l.DiscountType != "" {
+			line.Discount = &discountIn{Type: l.DiscountType, Value: l.DiscountValue, Note: l.DiscountNote}
+		}
+		req.Items = append(req.Items, line)
+	}
+
+	tx, created, err := h.placeOrder(r.Context(), ownerID, req, func(ctx context.Context, tx pgx.Tx) error {
+		return h.Drafts.MarkPaidWithTx(ctx, tx, ownerID, d.ID, req.ClientRef)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrDraftNotOpen) {
+			writeError(w, http.StatusConflict, "draft is not open")
+			return
+		}
+		writeOrderError(w, err)
+		return
+	}
+	if !created {
+		// The clientRef belongs to an earlier order; unless that order paid this draft, refuse.
+		if cur, err := h.Drafts.Get(r.Context(), ownerID, d.ID); err == nil && cur.Status == domain.DraftOpen {
+			writeError(w, http.StatusConflict, "clientRef already used by another order")
+			return
+		}
+	}
+	resp := toPlacedOrderResponse(tx, req)
+	resp["draftId"] = strconv.FormatInt(d.ID, 10)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toDraftResponse(d domain.DraftOrder) map[string]any {
+	lines := make([]map[string]any, 0, len(d.Lines))
+	var subtotal int64
+	for _, l := range d.Lines {
+		var discount any
+		if l.DiscountType != "" {
+			discount = map[string]any{"type": l.DiscountType, "value": l.DiscountValue, "note": l.DiscountNote}
+		}
+		lines = append(lines, map[string]any{
+			"id":        strconv.FormatInt(l.ID, 10),
+			"productId": l.ProductID,
+			"name":      l.Name,
+			"category":  l.Category,
+			"price":     l.Price.Amount,
+			"qty":       l.Qty,
+			"discount":  discount,
+			"stylistId": l.StylistID,
+			"stylist":   l.Stylist,
+		})
+		subtotal += l.Price.Amount * int64(l.Qty)
+	}
+	var transactionID any
+	if d.TransactionID != nil {
+		transactionID = strconv.FormatInt(*d.TransactionID, 10)
+	}
+	return map[string]any{
+		"id":            strconv.FormatInt(d.ID, 10),
+		"status":        string(d.Status),
+		"customer":      d.CustomerName,
+		"customerId":    d.CustomerID,
+		"customerPhone": d.CustomerPhone,
+		"stylist":       d.Stylist,
+		"stylistId":     d.StylistID,
+		"shiftId":       d.ShiftID,
+		"operatorName":  d.OperatorName,
+		"note":          d.Note,
+		"items":         lines,
+		"subtotal":      subtotal,
+		"transactionId": transactionID,
+		"paidAt":        d.PaidAt,
+		"createdAt":     d.CreatedAt,
+		"updatedAt":     d.UpdatedAt,
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
 	"barberpos-backend/internal/repository"
 	"barberpos-backend/internal/server/authctx"
 	"github.com/go-chi/chi/v5"
@@ -17,12 +18,15 @@ import (
 )
 
 type FinanceHandler struct {
-	Repo repository.FinanceRepository
+	Repo         repository.FinanceRepository
+	Transactions repository.TransactionRepository
+	Settings     repository.SettingsRepository
 }
 
 func (h FinanceHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/finance", h.list)
 	r.Get("/finance/export", h.export)
+	r.Get("/finance/tips", h.tipPayouts)
 	r.Post("/finance", h.create)
 }
 
@@ -71,6 +75,7 @@ func (h FinanceHandler) list(w http.ResponseWriter, r *http.Request) {
 			"transactionCode": fe.TransactionCode,
 			"staff":           fe.Staff,
 			"service":         fe.Service,
+			"currency":        fe.Amount.Currency,
 		})
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -102,6 +107,21 @@ func (h FinanceHandler) export(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filenameSuffix := time.Now().Format("20060102_150405")
+	if startDate != nil && endDate != nil {
+		filenameSuffix = fmt.Sprintf("%s_%s", startDate.Format("20060102"), endDate.Format("20060102"))
+	}
+
+	switch r.URL.Query().Get("report") {
+	case "", "finance":
+	case "sales":
+		h.exportSales(w, r, user.ID, format, startDate, endDate, filenameSuffix)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "invalid report (use finance or sales)")
+		return
+	}
+
 	var items []domain.FinanceEntry
 	if startDate != nil || endDate != nil {
 		items, err = h.Repo.ListFiltered(r.Context(), user.ID, startDate, endDate)
@@ -113,11 +133,6 @@ func (h FinanceHandler) export(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filenameSuffix := time.Now().Format("20060102_150405")
-	if startDate != nil && endDate != nil {
-		filenameSuffix = fmt.Sprintf("%s_%s", startDate.Format("20060102"), endDate.Format("20060102"))
-	}
-
 	switch format {
 	case "csv":
 		data, err := exportFinanceCSV(items)
@@ -148,7 +163,7 @@ func (h FinanceHandler) export(w http.ResponseWriter, r *http.Request) {
 func exportFinanceCSV(items []domain.FinanceEntry) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	w := csv.NewWriter(buf)
-	_ = w.Write([]string{"id", "title", "amount", "category", "date", "type", "note", "transaction_id", "transaction_code", "staff", "service"})
+	_ = w.Write([]string{"id", "title", "amount", "category", "date", "type", "note", "transaction_id", "transaction_code", "staff", "service", "currency"})
 	for _, fe := range items {
 		var txID string
 		if fe.TransactionID != nil {
@@ -166,6 +181,7 @@ func exportFinanceCSV(items []domain.FinanceEntry) ([]byte, error) {
 			derefString(fe.TransactionCode),
 			derefString(fe.Staff),
 			derefString(fe.Service),
+			fe.Amount.Currency,
 		})
 	}
 	w.Flush()
@@ -182,7 +198,7 @@ func exportFinanceXLSX(items []domain.FinanceEntry) ([]byte, error) {
 	f.DeleteSheet("Sheet1")
 	f.SetActiveSheet(index)
 
-	header := []string{"ID", "Title", "Amount", "Category", "Date", "Type", "Note", "Transaction ID", "Transaction Code", "Staff", "Service"}
+	header := []string{"ID", "Title", "Amount", "Category", "Date", "Type", "Note", "Transaction ID", "Transaction Code", "Staff", "Service", "Currency"}
 	for c, v := range header {
 		cell, _ := excelize.CoordinatesToCellName(c+1, 1)
 		_ = f.SetCellValue(sheet, cell, v)
@@ -207,6 +223,7 @@ func exportFinanceXLSX(items []domain.FinanceEntry) ([]byte, error) {
 			derefString(fe.TransactionCode),
 			derefString(fe.Staff),
 			derefString(fe.Service),
+			fe.Amount.Currency,
 		}
 		for c, v := range values {
 			cell, _ := excelize.CoordinatesToCellName(c+1, row)
@@ -225,12 +242,13 @@ func exportFinanceXLSX(items []domain.FinanceEntry) ([]byte, error) {
 	_ = f.SetColWidth(sheet, "I", "I", 18)
 	_ = f.SetColWidth(sheet, "J", "J", 18)
 	_ = f.SetColWidth(sheet, "K", "K", 18)
+	_ = f.SetColWidth(sheet, "L", "L", 10)
 
 	style, _ := f.NewStyle(&excelize.Style{
 		Font: &excelize.Font{Bold: true},
 		Fill: excelize.Fill{Type: "pattern", Color: []string{"#1F2937"}, Pattern: 1},
 	})
-	_ = f.SetCellStyle(sheet, "A1", "K1", style)
+	_ = f.SetCellStyle(sheet, "A1", "L1", style)
 
 	buf, err := f.WriteToBuffer()
 	if err != nil {
@@ -253,16 +271,17 @@ func (h FinanceHandler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		Title    string  `json:"title"`
-		Amount   int64   `json:"amount"`
-		Category string  `json:"category"`
-		Date     string  `json:"date"`
-		Type     string  `json:"type"`
-		Note     string  `json:"note"`
+		Title           string  `json:"title"`
+		Amount          int64   `json:"amount"`
+		Category        string  `json:"category"`
+		Date            string  `json:"date"`
+		Type            string  `json:"type"`
+		Note            string  `json:"note"`
 		TransactionID   *int64  `json:"transactionId"`
 		TransactionCode *string `json:"transactionCode"`
-		Staff    *string `json:"staff"`
-		Service  *string `json:"service"`
+		Staff           *string `json:"staff"`
+		Service         *string `json:"service"`
+		Currency        string  `json:"currency"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid payload")
@@ -272,6 +291,25 @@ func (h FinanceHandler) create(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "title is required")
 		return
 	}
+	// Entries are kept in the shop's currency; a client that sends one must agree with it.
+	var currency string
+	if req.Currency != "" {
+		c, err := money.Lookup(req.Currency)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "unsupported currency")
+			return
+		}
+		settings, err := h.Settings.Get(r.Context(), user.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if c.Code != money.Normalize(settings.CurrencyCode) {
+			writeError(w, http.StatusBadRequest, "currency must be the shop currency "+money.Normalize(settings.CurrencyCode))
+			return
+		}
+		currency = c.Code
+	}
 	dt := time.Now()
 	if req.Date != "" {
 		if t, err := time.Parse("2006-01-02", req.Date); err == nil {
@@ -279,16 +317,17 @@ func (h FinanceHandler) create(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	fe, err := h.Repo.Create(r.Context(), user.ID, repository.CreateFinanceInput{
-		Title:    req.Title,
-		Amount:   req.Amount,
-		Category: req.Category,
-		Date:     dt,
-		Type:     domain.FinanceEntryType(req.Type),
-		Note:     req.Note,
+		Title:           req.Title,
+		Amount:          req.Amount,
+		Category:        req.Category,
+		Date:            dt,
+		Type:            domain.FinanceEntryType(req.Type),
+		Note:            req.Note,
 		TransactionID:   req.TransactionID,
 		TransactionCode: req.TransactionCode,
-		Staff:    req.Staff,
-		Service:  req.Service,
+		Staff:           req.Staff,
+		Service:         req.Service,
+		Currency:        currency,
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -306,5 +345,6 @@ func (h FinanceHandler) create(w http.ResponseWriter, r *http.Request) {
 		"transactionCode": fe.TransactionCode,
 		"staff":           fe.Staff,
 		"service":         fe.Service,
+		"currency":        fe.Amount.Currency,
 	})
 }
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/server/authctx"
+	"github.com/xuri/excelize/v2"
+)
+
+var salesExportHeader = []string{"Date", "Time", "Code", "Receipt Number", "Status", "Payment Method", "Subtotal", "Discount", "Service Charge", "Tax", "Tax Inclusive", "Total", "Refunded", "Tip", "Rounding", "Currency"}
+
+// tipPayouts reports tips owed per stylist over an optional startDate/endDate range.
+func (h FinanceHandler) tipPayouts(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	startDate, err := parseDateQuery(r, "startDate")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid startDate")
+		return
+	}
+	endDate, err := parseDateQuery(r, "endDate")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid endDate")
+		return
+	}
+	if startDate != nil && endDate != nil && startDate.After(*endDate) {
+		writeError(w, http.StatusBadRequest, "startDate must be before endDate")
+		return
+	}
+	payouts, err := h.Transactions.TipPayouts(r.Context(), user.ID, startDate, endDate)
+	if err != nil {
+		writeSumError(w, err)
+		return
+	}
+	var total int64
+	items := make([]map[string]any, 0, len(payouts))
+	for _, p := range payouts {
+		total += p.Amount
+		items = append(items, map[string]any{
+			"stylistId":    p.StylistID,
+			"stylist":      p.Stylist,
+			"amount":       p.Amount,
+			"transactions": p.Count,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total": total,
+		"items": items,
+	})
+}
+
+// exportSales writes one row per transaction with the subtotal, discount, service charge and tax separated.
+// Rounding is the cash rounding on top of total and tip, so the cash column of a day adds up to the drawer.
+func (h FinanceHandler) exportSales(w http.ResponseWriter, r *http.Request, ownerUserID int64, format string, startDate, endDate *time.Time, filenameSuffix string) {
+	var (
+		items []domain.Transaction
+		err   error
+	)
+	if startDate != nil || endDate != nil {
+		items, err = h.Transactions.ListFiltered(r.Context(), ownerUserID, startDate, endDate)
+	} else {
+		items, err = h.Transactions.List(r.Context(), ownerUserID, 2000)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch format {
+	case "csv":
+		data, err := exportSalesCSV(items)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"sales_%s.csv\"", filenameSuffix))
+		_, _ = w.Write(data)
+	case "xlsx", "excel":
+		data, err := exportSalesXLSX(items)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"sales_%s.xlsx\"", filenameSuffix))
+		_, _ = w.Write(data)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid format (use csv or xlsx)")
+	}
+}
+
+func salesRow(t domain.Transaction) []any {
+	return []any{
+		t.Date.Format("2006-01-02"),
+		t.Time,
+		t.Code,
+		t.ReceiptNumber,
+		string(t.Status),
+		t.PaymentMethod,
+		t.Subtotal.Amount,
+		t.DiscountTotal.Amount,
+		t.ServiceCharge.Amount,
+		t.TaxAmount.Amount,
+		t.TaxInclusive,
+		t.Amount.Amount,
+		t.RefundedAmount.Amount,
+		t.TipTotal.Amount,
+		t.Rounding.Amount,
+		t.Amount.Currency,
+	}
+}
+
+func exportSalesCSV(items []domain.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"date", "time", "code", "receipt_number", "status", "payment_method", "subtotal", "discount", "service_charge", "tax", "tax_inclusive", "total", "refunded", "tip", "rounding", "currency"})
+	for _, t := range items {
+		values := salesRow(t)
+		record := make([]string, len(values))
+		for i, v := range values {
+			switch x := v.(type) {
+			case int64:
+				record[i] = strconv.FormatInt(x, 10)
+			case bool:
+				record[i] = strconv.FormatBool(x)
+			default:
+				record[i] = fmt.Sprint(x)
+			}
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func exportSalesXLSX(items []domain.Transaction) ([]byte, error) {
+	f := excelize.NewFile()
+	sheet := "Sales"
+	index, err := f.NewSheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	f.DeleteSheet("Sheet1")
+	f.SetActiveSheet(index)
+
+	for c, v := range salesExportHeader {
+		cell, _ := excelize.CoordinatesToCellName(c+1, 1)
+		_ = f.SetCellValue(sheet, cell, v)
+	}
+	for r, t := range items {
+		for c, v := range salesRow(t) {
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+2)
+			_ = f.SetCellValue(sheet, cell, v)
+		}
+	}
+
+	_ = f.SetColWidth(sheet, "A", "B", 12)
+	_ = f.SetColWidth(sheet, "C", "D", 22)
+	_ = f.SetColWidth(sheet, "E", "F", 16)
+	_ = f.SetColWidth(sheet, "G", "P", 14)
+
+	style, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#1F2937"}, Pattern: 1},
+	})
+	_ = f.SetCellStyle(sheet, "A1", "P1", style)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
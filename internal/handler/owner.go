@@ -30,3 +30,7 @@ func resolveOwnerID(ctx context.Context, user authctx.CurrentUser, employees rep
 	}
 }
 
+// isManager reports whether user manages the shop rather than working in it.
+func isManager(user authctx.CurrentUser) bool {
+	return user.Role == domain.RoleManager || user.Role == domain.RoleAdmin
+}
@@ -1,50 +1,276 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
-	"time"
 
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/ports"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"barberpos-backend/internal/service"
 	"github.com/go-chi/chi/v5"
 )
 
-type PaymentHandler struct{}
+// PaymentHandler starts and tracks non-cash tenders. The intent id it returns goes into the order's
+// paymentIntentId once the intent is paid.
+type PaymentHandler struct {
+	Service   *service.PaymentService
+	Drafts    repository.DraftOrderRepository
+	Employees repository.EmployeeRepository
+}
+
+// RegisterWebhookRoutes adds the provider callbacks. They carry no user token; each delivery is checked
+// against the provider's signature instead.
+func (h PaymentHandler) RegisterWebhookRoutes(r chi.Router) {
+	r.Post("/payments/webhooks/{provider}", h.webhook)
+}
 
 func (h PaymentHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/payments/qris", h.qris)
 	r.Post("/payments/card", h.card)
+	r.Get("/payments/intents/{id}", h.get)
+	r.Post("/payments/intents/{id}/cancel", h.cancel)
+}
+
+func (h PaymentHandler) RegisterManagerRoutes(r chi.Router) {
+	r.Post("/payments/intents/{id}/refund", h.refund)
+}
+
+// RegisterAdminRoutes adds routes over data of every owner, for the operator of the deployment.
+func (h PaymentHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/payments/webhooks/unmatched", h.unmatchedWebhooks)
 }
 
 func (h PaymentHandler) qris(w http.ResponseWriter, r *http.Request) {
-	amount := parseAmount(r)
-	now := time.Now().UnixMilli()
-	writeJSON(w, http.StatusOK, map[string]any{
-		"id":        "qris-" + strconv.FormatInt(now, 10),
-		"method":    "qris",
-		"qrString":  "00020101021226380011ID.CO.QRIS.WWW01189360091100200010000031303UKE51440014ID.CO.QRIS.WWW0215DUMMYQRISEXAMPLE53033605406" + strconv.FormatInt(amount, 10) + "5802ID5908BARBER6010JAKARTA6304ABCD",
-		"status":    "pending",
-		"expiresAt": time.Now().Add(10 * time.Minute).Format(time.RFC3339),
-		"intentId":  "qris-intent-" + strconv.FormatInt(now, 10),
-	})
+	h.create(w, r, "qris")
 }
 
 func (h PaymentHandler) card(w http.ResponseWriter, r *http.Request) {
-	amount := parseAmount(r)
-	now := time.Now().UnixMilli()
-	writeJSON(w, http.StatusOK, map[string]any{
-		"id":        "card-" + strconv.FormatInt(now, 10),
-		"method":    "card",
-		"status":    "pending",
-		"reference": "REF" + strconv.FormatInt(now, 10),
-		"intentId":  "card-intent-" + strconv.FormatInt(now, 10),
-		"amount":    amount,
+	h.create(w, r, "card")
+}
+
+func (h PaymentHandler) create(w http.ResponseWriter, r *http.Request, method string) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Amount  int64  `json:"amount"`
+		DraftID *int64 `json:"draftId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	// Older clients send the amount as a query parameter.
+	if req.Amount == 0 {
+		req.Amount = parseAmount(r)
+	}
+	if req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	if req.DraftID != nil {
+		if _, err := h.Drafts.Get(r.Context(), ownerID, *req.DraftID); err != nil {
+			writeDraftError(w, err)
+			return
+		}
+	}
+	intent, err := h.Service.Create(r.Context(), ownerID, service.CreatePaymentInput{
+		Method:    method,
+		Amount:    req.Amount,
+		DraftID:   req.DraftID,
+		CreatedBy: &user.ID,
 	})
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPaymentIntentResponse(*intent))
+}
+
+// get returns the intent, checking with the provider first while it is still pending.
+func (h PaymentHandler) get(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	intent, err := h.Service.Refresh(r.Context(), ownerID, chi.URLParam(r, "id"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPaymentIntentResponse(*intent))
+}
+
+func (h PaymentHandler) cancel(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	intent, err := h.Service.Cancel(r.Context(), ownerID, chi.URLParam(r, "id"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPaymentIntentResponse(*intent))
+}
+
+// refund returns money to the customer through the provider. It does not touch the sale: refund the
+// transaction as well when the intent paid for one.
+func (h PaymentHandler) refund(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var req struct {
+		Amount int64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	if req.Amount < 0 {
+		writeError(w, http.StatusBadRequest, "amount must not be negative")
+		return
+	}
+	intent, err := h.Service.Refund(r.Context(), user.ID, chi.URLParam(r, "id"), req.Amount)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPaymentIntentResponse(*intent))
+}
+
+// unmatchedWebhooks lists provider deliveries that matched no payment intent. They belong to no owner and may
+// carry any shop's payment data, so only admins see them; pass the last id as before for the next page.
+func (h PaymentHandler) unmatchedWebhooks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 50
+	if parsed, err := strconv.Atoi(q.Get("limit")); err == nil && parsed > 0 && parsed <= 200 {
+		limit = parsed
+	}
+	var before int64
+	if v := q.Get("before"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "before must be an event id")
+			return
+		}
+		before = parsed
+	}
+	items, err := h.Service.UnmatchedWebhooks(r.Context(), before, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(items))
+	for _, ev := range items {
+		var payload any = ev.Payload
+		if json.Valid([]byte(ev.Payload)) {
+			payload = json.RawMessage(ev.Payload)
+		}
+		resp = append(resp, map[string]any{
+			"id":          ev.ID,
+			"provider":    ev.Provider,
+			"eventId":     ev.EventID,
+			"providerRef": ev.ProviderRef,
+			"intentRef":   ev.IntentRef,
+			"status":      ev.Status,
+			"payload":     payload,
+			"receivedAt":  ev.ReceivedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// webhook applies a provider's payment update. Deliveries that match no intent are acknowledged and kept for
+// review, so the provider stops retrying them; only failures worth a retry answer 5xx.
+func (h PaymentHandler) webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	outcome, err := h.Service.HandleWebhook(r.Context(), chi.URLParam(r, "provider"), r.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWebhookNotSupported):
+			writeError(w, http.StatusNotFound, "unknown payment provider")
+		case errors.Is(err, ports.ErrInvalidWebhookSignature):
+			writeError(w, http.StatusUnauthorized, "invalid signature")
+		case errors.Is(err, ports.ErrWebhookMalformed):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"received": true, "outcome": string(outcome)})
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "payment intent not found")
+	case errors.Is(err, ports.ErrPaymentMethodUnsupported):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrPaymentIntentNotPending),
+		errors.Is(err, service.ErrPaymentIntentNotPaid),
+		errors.Is(err, service.ErrPaymentProviderChanged):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrRefundExceedsPayment):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		// Anything else came from the provider or the database; the client can retry.
+		writeErrorWithErr(w, http.StatusBadGateway, "payment provider error", err)
+	}
+}
+
+func toPaymentIntentResponse(p domain.PaymentIntent) map[string]any {
+	return map[string]any{
+		"id":             p.ID,
+		"intentId":       p.ID,
+		"provider":       p.Provider,
+		"method":         p.Method,
+		"amount":         p.Amount.Amount,
+		"currency":       p.Amount.Currency,
+		"status":         string(p.Status),
+		"qrString":       p.QRString,
+		"reference":      p.Reference,
+		"refundedAmount": p.RefundedAmount.Amount,
+		"draftId":        p.DraftID,
+		"transactionId":  p.TransactionID,
+		"expiresAt":      p.ExpiresAt,
+		"paidAt":         p.PaidAt,
+		"createdAt":      p.CreatedAt,
+	}
 }
 
 func parseAmount(r *http.Request) int64 {
-	// naive parse; rely on client to send correct amount.
-	// In production, validate JSON. Simplified here.
-	// Attempt query param or default 0.
 	q := r.URL.Query().Get("amount")
 	if q == "" {
 		return 0
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/receipt"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"barberpos-backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type PrintJobHandler struct {
+	Service      *service.PrintService
+	Jobs         repository.PrintJobRepository
+	Transactions repository.TransactionRepository
+	Closing      repository.ClosingRepository
+	Employees    repository.EmployeeRepository
+	Settings     repository.SettingsRepository
+}
+
+func (h PrintJobHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/print-jobs", h.list)
+	r.Post("/print-jobs", h.create)
+	r.Post("/print-jobs/{id}/retry", h.retry)
+}
+
+func (h PrintJobHandler) list(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := domain.PrintJobStatus(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status"))))
+	switch status {
+	case "", domain.PrintJobQueued, domain.PrintJobSent, domain.PrintJobFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be queued, sent or failed")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 200 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+	jobs, err := h.Jobs.List(r.Context(), ownerID, status, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(jobs))
+	for _, j := range jobs {
+		resp = append(resp, toPrintJobResponse(j))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// create queues a receipt (by transaction code), the current closing report or a test page.
+func (h PrintJobHandler) create(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Kind         string `json:"kind"`
+		Code         string `json:"code"`
+		Shift        string `json:"shift"`
+		OperatorName string `json:"operatorName"`
+		Fisik        string `json:"fisik"`
+		Catatan      string `json:"catatan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+
+	var job *domain.PrintJob
+	var enqueueErr error
+	switch domain.PrintJobKind(strings.ToLower(strings.TrimSpace(req.Kind))) {
+	case domain.PrintJobReceipt:
+		if req.Code == "" {
+			writeError(w, http.StatusBadRequest, "code is required")
+			return
+		}
+		t, err := h.Transactions.GetByCode(r.Context(), ownerID, req.Code)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "transaction not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		job, enqueueErr = h.Service.EnqueueReceipt(r.Context(), ownerID, *t)
+	case domain.PrintJobClosing:
+		summary, err := h.Closing.Summary(r.Context(), ownerID)
+		if err != nil {
+			writeSumError(w, err)
+			return
+		}
+		today, err := h.Settings.Today(r.Context(), ownerID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		tenders := make([]receipt.Tender, 0, len(summary.Tenders))
+		for _, t := range summary.Tenders {
+			tenders = append(tenders, receipt.Tender{Method: t.Method, Amount: t.Amount, Count: t.Count})
+		}
+		job, enqueueErr = h.Service.EnqueueClosing(r.Context(), ownerID, receipt.ClosingReport{
+			Date:          today,
+			Shift:         req.Shift,
+			Operator:      req.OperatorName,
+			Tenders:       tenders,
+			Discount:      summary.TotalDiscount,
+			ServiceCharge: summary.TotalServiceCharge,
+			Tax:           summary.TotalTax,
+			Tips:          summary.TotalTips,
+			Rounding:      summary.TotalRounding,
+			Currency:      summary.Currency,
+			Refund:        summary.TotalRefund,
+			Void:          summary.TotalVoid,
+			VoidCount:     summary.VoidCount,
+			Counted:       req.Fisik,
+			Note:          req.Catatan,
+		})
+	case domain.PrintJobTest:
+		job, enqueueErr = h.Service.EnqueueTest(r.Context(), ownerID)
+	default:
+		writeError(w, http.StatusBadRequest, "kind must be receipt, closing or test")
+		return
+	}
+	if enqueueErr != nil {
+		if errors.Is(enqueueErr, service.ErrNoNetworkPrinter) {
+			writeError(w, http.StatusConflict, "no network printer configured; set printerType lan and printerHost in settings")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, enqueueErr.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toPrintJobResponse(*job))
+}
+
+func (h PrintJobHandler) retry(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	job, err := h.Jobs.Retry(r.Context(), ownerID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "failed print job not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toPrintJobResponse(*job))
+}
+
+func toPrintJobResponse(j domain.PrintJob) map[string]any {
+	return map[string]any{
+		"id":            j.ID,
+		"kind":          string(j.Kind),
+		"reference":     j.Reference,
+		"printerHost":   j.PrinterHost,
+		"printerPort":   j.PrinterPort,
+		"status":        string(j.Status),
+		"attempts":      j.Attempts,
+		"maxAttempts":   j.MaxAttempts,
+		"lastError":     j.LastError,
+		"nextAttemptAt": j.NextAttemptAt,
+		"sentAt":        j.SentAt,
+		"createdAt":     j.CreatedAt,
+		"updatedAt":     j.UpdatedAt,
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"barberpos-backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type PromotionHandler struct {
+	Repo repository.PromotionRepository
+}
+
+func (h PromotionHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/promotions", h.list)
+	r.Post("/promotions", h.upsert)
+	r.Delete("/promotions/{id}", h.delete)
+}
+
+func (h PromotionHandler) list(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	items, err := h.Repo.List(r.Context(), user.ID, 500)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(items))
+	for _, p := range items {
+		resp = append(resp, toPromotionResponse(p))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h PromotionHandler) upsert(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var req struct {
+		ID          *int64     `json:"id"`
+		Code        string     `json:"code"`
+		Name        string     `json:"name"`
+		Type        string     `json:"type"`
+		Scope       string     `json:"scope"`
+		Value       int64      `json:"value"`
+		ProductID   *int64     `json:"productId"`
+		MinSpend    int64      `json:"minSpend"`
+		MaxDiscount *int64     `json:"maxDiscount"`
+		StartsAt    *time.Time `json:"startsAt"`
+		EndsAt      *time.Time `json:"endsAt"`
+		UsageLimit  *int       `json:"usageLimit"`
+		Active      *bool      `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	p := domain.Promotion{
+		Code:        service.NormalizePromoCode(req.Code),
+		Name:        strings.TrimSpace(req.Name),
+		Kind:        domain.DiscountKind(strings.ToLower(strings.TrimSpace(req.Type))),
+		Scope:       domain.DiscountScope(strings.ToLower(strings.TrimSpace(req.Scope))),
+		Value:       req.Value,
+		ProductID:   req.ProductID,
+		MinSpend:    domain.Money{Amount: req.MinSpend},
+		MaxDiscount: req.MaxDiscount,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		UsageLimit:  req.UsageLimit,
+		Active:      true,
+	}
+	if req.ID != nil {
+		p.ID = *req.ID
+	}
+	if req.Active != nil {
+		p.Active = *req.Active
+	}
+	if p.Scope == "" {
+		p.Scope = domain.DiscountScopeOrder
+	}
+	if msg := validatePromotion(p); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	saved, err := h.Repo.Save(r.Context(), user.ID, p)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "promotion not found")
+			return
+		}
+		if db.IsUniqueViolation(err) {
+			writeError(w, http.StatusConflict, "promo code already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toPromotionResponse(*saved))
+}
+
+func (h PromotionHandler) delete(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := h.Repo.Delete(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "promotion not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func validatePromotion(p domain.Promotion) string {
+	if p.Code == "" {
+		return "code is required"
+	}
+	switch p.Kind {
+	case domain.DiscountPercent:
+		if p.Value <= 0 || p.Value > 100 {
+			return "percent value must be between 1 and 100"
+		}
+	case domain.DiscountFixed:
+		if p.Value <= 0 {
+			return "fixed value must be positive"
+		}
+	default:
+		return "type must be percent or fixed"
+	}
+	if p.Scope != domain.DiscountScopeOrder && p.Scope != domain.DiscountScopeItem {
+		return "scope must be order or item"
+	}
+	if p.MinSpend.Amount < 0 {
+		return "minSpend must not be negative"
+	}
+	if p.MaxDiscount != nil && *p.MaxDiscount <= 0 {
+		return "maxDiscount must be positive"
+	}
+	if p.UsageLimit != nil && *p.UsageLimit <= 0 {
+		return "usageLimit must be positive"
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && p.EndsAt.Before(*p.StartsAt) {
+		return "endsAt must be after startsAt"
+	}
+	return ""
+}
+
+func toPromotionResponse(p domain.Promotion) map[string]any {
+	return map[string]any{
+		"id":          p.ID,
+		"code":        p.Code,
+		"name":        p.Name,
+		"type":        string(p.Kind),
+		"scope":       string(p.Scope),
+		"value":       p.Value,
+		"productId":   p.ProductID,
+		"minSpend":    p.MinSpend.Amount,
+		"maxDiscount": p.MaxDiscount,
+		"startsAt":    p.StartsAt,
+		"endsAt":      p.EndsAt,
+		"usageLimit":  p.UsageLimit,
+		"usedCount":   p.UsedCount,
+		"active":      p.Active,
+	}
+}
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"barberpos-backend/internal/money"
+	"barberpos-backend/internal/qris"
 	"barberpos-backend/internal/repository"
 	"barberpos-backend/internal/server/authctx"
 	"github.com/go-chi/chi/v5"
@@ -17,10 +22,12 @@ type QRISHandler struct {
 
 func (h QRISHandler) RegisterStaffRoutes(r chi.Router) {
 	r.Get("/settings/qris", h.get)
+	r.Get("/settings/qris/dynamic", h.dynamic)
 }
 
 func (h QRISHandler) RegisterManagerRoutes(r chi.Router) {
 	r.Post("/settings/qris", h.upload)
+	r.Put("/settings/qris/payload", h.setPayload)
 	r.Delete("/settings/qris", h.clear)
 }
 
@@ -87,17 +94,30 @@ func (h QRISHandler) upload(w http.ResponseWriter, r *http.Request) {
 	if mime == "image/jpg" {
 		mime = "image/jpeg"
 	}
+	// Only keep images that really are the shop's QRIS, so dynamic codes can be made from them.
+	text, err := qris.Decode(bytes)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	payload, err := qris.Parse(text)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "not a valid QRIS: "+err.Error())
+		return
+	}
 
 	// Ensure settings row exists.
 	if _, err := h.Settings.Get(r.Context(), user.ID); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if err := h.Settings.SetQrisImage(r.Context(), user.ID, bytes, mime); err != nil {
+	if err := h.Settings.SetQrisImage(r.Context(), user.ID, bytes, mime, strings.TrimSpace(text)); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	resp := toQrisMerchantResponse(payload.Merchant())
+	resp["ok"] = true
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (h QRISHandler) clear(w http.ResponseWriter, r *http.Request) {
@@ -110,5 +130,130 @@ func (h QRISHandler) clear(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if err := h.Settings.SetQrisPayload(r.Context(), user.ID, ""); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
+
+// setPayload registers the text of the merchant's static QRIS, as read from the printed code.
+func (h QRISHandler) setPayload(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var req struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	payload := strings.TrimSpace(req.Payload)
+	p, err := qris.Parse(payload)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if _, err := h.Settings.Get(r.Context(), user.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.Settings.SetQrisPayload(r.Context(), user.ID, payload); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toQrisMerchantResponse(p.Merchant()))
+}
+
+// dynamic turns the static QRIS into a one-off code for amount rupiah, so the customer's banking app
+// fills in the amount. format=png returns the QR image instead of the payload text.
+func (h QRISHandler) dynamic(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "png" {
+		writeError(w, http.StatusBadRequest, "format must be json or png")
+		return
+	}
+	amount := parseAmount(r)
+	if amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	settings, err := h.Settings.Get(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if money.Normalize(settings.CurrencyCode) != "IDR" {
+		writeError(w, http.StatusUnprocessableEntity, "QRIS only takes IDR")
+		return
+	}
+	stored, err := h.Settings.QrisPayload(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if stored == "" {
+		writeError(w, http.StatusNotFound, "QRIS belum diatur")
+		return
+	}
+	p, err := qris.Parse(stored)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload, err := p.WithAmount(amount)
+	if err != nil {
+		if errors.Is(err, qris.ErrInvalidPayload) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if format == "png" {
+		size := 512
+		if v, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && v >= 128 && v <= 1024 {
+			size = v
+		}
+		img, err := qris.PNG(payload, size)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(img)
+		return
+	}
+	resp := toQrisMerchantResponse(p.Merchant())
+	resp["payload"] = payload
+	resp["amount"] = amount
+	resp["currency"] = "IDR"
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toQrisMerchantResponse(m qris.Merchant) map[string]any {
+	return map[string]any{
+		"merchantName": m.Name,
+		"merchantCity": m.City,
+		"postalCode":   m.PostalCode,
+		"nmid":         m.NMID,
+	}
+}
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"barberpos-backend/internal/money"
 )
 
 type apiError struct {
@@ -58,6 +61,22 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// writeErrorData writes an error envelope that also carries structured details in `data`.
+func writeErrorData(w http.ResponseWriter, status int, message string, data any) {
+	if status < 400 {
+		status = http.StatusInternalServerError
+	}
+	writeRawJSON(w, status, apiResponse{
+		Status:  "error",
+		Message: message,
+		Data:    data,
+		Error: &apiError{
+			Code:   status,
+			Status: http.StatusText(status),
+		},
+	})
+}
+
 func writeErrorWithErr(w http.ResponseWriter, status int, message string, err error) {
 	if err == nil {
 		writeError(w, status, message)
@@ -69,3 +88,13 @@ func writeErrorWithErr(w http.ResponseWriter, status int, message string, err er
 	}
 	writeError(w, status, message+": "+err.Error())
 }
+
+// writeSumError reports a failed report or total. Amounts recorded in more than one currency
+// cannot be added up, which is the owner's data to sort out rather than a server fault.
+func writeSumError(w http.ResponseWriter, err error) {
+	if errors.Is(err, money.ErrCurrencyMismatch) {
+		writeError(w, http.StatusConflict, "amounts are recorded in more than one currency")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
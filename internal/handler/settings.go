@@ -2,12 +2,20 @@ package handler
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
+	"barberpos-backend/internal/qris"
 	"barberpos-backend/internal/repository"
 	"barberpos-backend/internal/server/authctx"
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type SettingsHandler struct {
@@ -17,6 +25,7 @@ type SettingsHandler struct {
 func (h SettingsHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/settings", h.get)
 	r.Put("/settings", h.save)
+	r.Put("/settings/manager-pin", h.setManagerPin)
 }
 
 func (h SettingsHandler) get(w http.ResponseWriter, r *http.Request) {
@@ -30,8 +39,7 @@ func (h SettingsHandler) get(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	hasQris, _ := h.Repo.HasQrisImage(r.Context(), user.ID)
-	writeJSON(w, http.StatusOK, toSettingsResponse(s, hasQris))
+	writeJSON(w, http.StatusOK, h.settingsResponse(r, user.ID, s))
 }
 
 func (h SettingsHandler) save(w http.ResponseWriter, r *http.Request) {
@@ -40,26 +48,202 @@ func (h SettingsHandler) save(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
 	var req domain.Settings
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var sent settingsFields
+	if json.Unmarshal(body, &req) != nil || json.Unmarshal(body, &sent) != nil {
 		writeError(w, http.StatusBadRequest, "invalid payload")
 		return
 	}
+	if req.TaxRate < 0 || req.TaxRate > 100 {
+		writeError(w, http.StatusBadRequest, "taxRate must be between 0 and 100")
+		return
+	}
+	if req.ServiceChargeRate < 0 || req.ServiceChargeRate > 100 {
+		writeError(w, http.StatusBadRequest, "serviceChargeRate must be between 0 and 100")
+		return
+	}
 	current, err := h.Repo.Get(r.Context(), user.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if req.CurrencyCode == "" {
+	// Clients that predate tax and service charge must not switch them off by saving other settings.
+	if sent.TaxRate == nil {
+		req.TaxRate = current.TaxRate
+	}
+	if sent.TaxInclusive == nil {
+		req.TaxInclusive = current.TaxInclusive
+	}
+	if sent.ServiceChargeRate == nil {
+		req.ServiceChargeRate = current.ServiceChargeRate
+	}
+	if sent.AllowCustomItems == nil {
+		req.AllowCustomItems = current.AllowCustomItems
+	}
+	if sent.AllowManualDiscounts == nil {
+		req.AllowManualDiscounts = current.AllowManualDiscounts
+	}
+	if strings.TrimSpace(req.CurrencyCode) == "" {
 		req.CurrencyCode = current.CurrencyCode
 	}
+	currency, err := money.Lookup(req.CurrencyCode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "currencyCode must be one of "+strings.Join(money.Codes(), ", "))
+		return
+	}
+	req.CurrencyCode = currency.Code
+	if currency.Code != money.Normalize(current.CurrencyCode) {
+		// Sales and finance entries keep the currency they were recorded in; totals cannot mix two.
+		used, err := h.Repo.HasLedger(r.Context(), user.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if used {
+			writeError(w, http.StatusConflict, "currencyCode cannot change once sales or finance entries are recorded")
+			return
+		}
+		if req.RoundingUnit == 0 {
+			req.RoundingUnit = currency.CashUnits[0]
+		}
+	}
+	// Receipt numbering is left as-is unless the client sends it.
+	if sent.ReceiptPrefix == nil {
+		req.ReceiptPrefix = current.ReceiptPrefix
+	}
+	req.ReceiptPrefix = strings.TrimSpace(req.ReceiptPrefix)
+	if req.ReceiptDateFormat == "" {
+		req.ReceiptDateFormat = current.ReceiptDateFormat
+	}
+	if req.ReceiptReset == "" {
+		req.ReceiptReset = current.ReceiptReset
+	}
+	if req.ReceiptDigits == 0 {
+		req.ReceiptDigits = current.ReceiptDigits
+	}
+	if msg := validateReceiptNumbering(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.VoidReasons == nil {
+		req.VoidReasons = current.VoidReasons
+	}
+	reasons, msg := normalizeVoidReasons(req.VoidReasons)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	req.VoidReasons = reasons
+	if req.RoundingUnit == 0 {
+		req.RoundingUnit = current.RoundingUnit
+	}
+	if req.RoundingMode == "" {
+		req.RoundingMode = current.RoundingMode
+	}
+	if msg := validateCashRounding(req, currency); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	if req.Timezone == "" {
+		req.Timezone = current.Timezone
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		writeError(w, http.StatusBadRequest, "timezone must be an IANA name such as Asia/Jakarta, Asia/Makassar or Asia/Jayapura")
+		return
+	}
+	req.ShiftPolicy = domain.ShiftPolicy(strings.ToLower(strings.TrimSpace(string(req.ShiftPolicy))))
+	switch req.ShiftPolicy {
+	case "":
+		req.ShiftPolicy = current.ShiftPolicy
+	case domain.ShiftPolicyOff, domain.ShiftPolicyReject, domain.ShiftPolicyAuto:
+	default:
+		writeError(w, http.StatusBadRequest, "shiftPolicy must be off, reject or auto")
+		return
+	}
 	s, err := h.Repo.Save(r.Context(), user.ID, req)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	hasQris, _ := h.Repo.HasQrisImage(r.Context(), user.ID)
-	writeJSON(w, http.StatusOK, toSettingsResponse(s, hasQris))
+	writeJSON(w, http.StatusOK, h.settingsResponse(r, user.ID, s))
+}
+
+// setManagerPin sets the PIN a manager types on a staff device to approve voids; an empty pin removes it.
+func (h SettingsHandler) setManagerPin(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var req struct {
+		Pin string `json:"pin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	var hash *string
+	if req.Pin != "" {
+		if len(req.Pin) < 4 || len(req.Pin) > 12 || strings.Trim(req.Pin, "0123456789") != "" {
+			writeError(w, http.StatusBadRequest, "pin must be 4 to 12 digits")
+			return
+		}
+		b, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to hash pin")
+			return
+		}
+		hash = ptr(string(b))
+	}
+	if err := h.Repo.SetManagerPinHash(r.Context(), user.ID, hash); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "hasManagerPin": hash != nil})
+}
+
+func (h SettingsHandler) settingsResponse(r *http.Request, ownerUserID int64, s *domain.Settings) map[string]any {
+	hasQris, _ := h.Repo.HasQrisImage(r.Context(), ownerUserID)
+	pinHash, _ := h.Repo.ManagerPinHash(r.Context(), ownerUserID)
+	resp := toSettingsResponse(s, hasQris)
+	resp["hasManagerPin"] = pinHash != nil
+	resp["qrisMerchant"] = nil
+	if stored, _ := h.Repo.QrisPayload(r.Context(), ownerUserID); stored != "" {
+		if p, err := qris.Parse(stored); err == nil {
+			resp["qrisMerchant"] = toQrisMerchantResponse(p.Merchant())
+		}
+	}
+	return resp
+}
+
+// normalizeVoidReasons trims and de-duplicates reason codes; at least one is needed so voids stay possible.
+func normalizeVoidReasons(in []string) ([]string, string) {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]struct{}, len(in))
+	for _, reason := range in {
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			continue
+		}
+		if len(reason) > 40 {
+			return nil, "void reasons must be at most 40 characters"
+		}
+		if _, ok := seen[reason]; ok {
+			continue
+		}
+		seen[reason] = struct{}{}
+		out = append(out, reason)
+	}
+	if len(out) == 0 {
+		return nil, "at least one void reason is required"
+	}
+	return out, ""
 }
 
 func toSettingsResponse(s *domain.Settings, hasQris bool) map[string]any {
@@ -79,9 +263,81 @@ func toSettingsResponse(s *domain.Settings, hasQris bool) map[string]any {
 		"notifications":        s.Notifications,
 		"trackStock":           s.TrackStock,
 		"roundingPrice":        s.RoundingPrice,
+		"roundingUnit":         s.RoundingUnit,
+		"roundingMode":         string(s.RoundingMode),
 		"autoBackup":           s.AutoBackup,
 		"cashierPin":           s.CashierPin,
 		"currencyCode":         s.CurrencyCode,
+		"taxRate":              s.TaxRate,
+		"taxInclusive":         s.TaxInclusive,
+		"serviceChargeRate":    s.ServiceChargeRate,
+		"receiptPrefix":        s.ReceiptPrefix,
+		"receiptDateFormat":    string(s.ReceiptDateFormat),
+		"receiptReset":         string(s.ReceiptReset),
+		"receiptDigits":        s.ReceiptDigits,
+		"voidReasons":          s.VoidReasons,
+		"timezone":             s.Timezone,
+		"shiftPolicy":          string(s.ShiftPolicy),
+		"allowCustomItems":     s.AllowCustomItems,
+		"allowManualDiscounts": s.AllowManualDiscounts,
 		"hasQrisImage":         hasQris,
 	}
 }
+
+// settingsFields tells which settings a save sent, for those whose zero value is a real choice (no tax,
+// tax exclusive, no service charge, no receipt prefix, custom items or manual discounts off): absent or null
+// keeps the current value.
+type settingsFields struct {
+	TaxRate              *float64 `json:"taxRate"`
+	TaxInclusive         *bool    `json:"taxInclusive"`
+	ServiceChargeRate    *float64 `json:"serviceChargeRate"`
+	ReceiptPrefix        *string  `json:"receiptPrefix"`
+	AllowCustomItems     *bool    `json:"allowCustomItems"`
+	AllowManualDiscounts *bool    `json:"allowManualDiscounts"`
+}
+
+// validateCashRounding accepts the coin steps the till can actually give change in, in minor units.
+func validateCashRounding(s domain.Settings, currency money.Currency) string {
+	if !slices.Contains(currency.CashUnits, s.RoundingUnit) {
+		units := make([]string, 0, len(currency.CashUnits))
+		for _, u := range currency.CashUnits {
+			units = append(units, strconv.FormatInt(u, 10))
+		}
+		return "roundingUnit for " + currency.Code + " must be one of " + strings.Join(units, ", ")
+	}
+	switch s.RoundingMode {
+	case domain.RoundingNearest, domain.RoundingUp, domain.RoundingDown:
+	default:
+		return "roundingMode must be nearest, up or down"
+	}
+	return ""
+}
+
+// validateReceiptNumbering rejects formats that would repeat numbers: a daily reset needs the day in the number
+// and a yearly reset needs at least the year.
+func validateReceiptNumbering(s domain.Settings) string {
+	if len(s.ReceiptPrefix) > 16 {
+		return "receiptPrefix must be at most 16 characters"
+	}
+	if s.ReceiptDigits < 1 || s.ReceiptDigits > 10 {
+		return "receiptDigits must be between 1 and 10"
+	}
+	switch s.ReceiptDateFormat {
+	case domain.ReceiptDateNone, domain.ReceiptDateYear, domain.ReceiptDateMonth, domain.ReceiptDateDay:
+	default:
+		return "receiptDateFormat must be none, yyyy, yyyymm or yyyymmdd"
+	}
+	switch s.ReceiptReset {
+	case domain.ReceiptResetDaily:
+		if s.ReceiptDateFormat != domain.ReceiptDateDay {
+			return "daily receipt reset requires receiptDateFormat yyyymmdd"
+		}
+	case domain.ReceiptResetYearly:
+		if s.ReceiptDateFormat == domain.ReceiptDateNone {
+			return "yearly receipt reset requires a date segment in the receipt number"
+		}
+	default:
+		return "receiptReset must be daily or yearly"
+	}
+	return ""
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"github.com/go-chi/chi/v5"
+)
+
+// ShiftHandler opens and closes shifts. The server hands out shift IDs; clients send them as shiftId on
+// orders, drafts, cash drawers and closings.
+type ShiftHandler struct {
+	Repo      repository.ShiftRepository
+	Employees repository.EmployeeRepository
+}
+
+func (h ShiftHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/shifts", h.list)
+	r.Get("/shifts/current", h.current)
+	r.Post("/shifts/open", h.open)
+	r.Get("/shifts/{id}", h.get)
+	r.Post("/shifts/{id}/close", h.close)
+}
+
+func (h ShiftHandler) open(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		TerminalID   string `json:"terminalId"`
+		OperatorName string `json:"operatorName"`
+		Note         string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	req.TerminalID = strings.TrimSpace(req.TerminalID)
+	if req.TerminalID == "" || len(req.TerminalID) > 64 {
+		writeError(w, http.StatusBadRequest, "terminalId is required, at most 64 characters")
+		return
+	}
+	operator := strings.TrimSpace(req.OperatorName)
+	if operator == "" && user.Role == domain.RoleStaff {
+		// Staff open their own shift; their employee name is the operator.
+		if emp, err := h.Employees.GetByEmail(r.Context(), user.Email); err == nil {
+			operator = emp.Name
+		}
+	}
+	s, err := h.Repo.Open(r.Context(), ownerID, repository.OpenShiftInput{
+		TerminalID:   req.TerminalID,
+		OperatorName: operator,
+		Note:         strings.TrimSpace(req.Note),
+		OpenedBy:     &user.ID,
+	})
+	if errors.Is(err, repository.ErrShiftOpen) {
+		// Hand back the open shift so a device that lost it (e.g. after a reinstall) can carry on or close it.
+		if cur, err := h.Repo.Current(r.Context(), ownerID, req.TerminalID); err == nil {
+			writeErrorData(w, http.StatusConflict, repository.ErrShiftOpen.Error(), map[string]any{"shift": toShiftResponse(*cur)})
+			return
+		}
+		writeError(w, http.StatusConflict, repository.ErrShiftOpen.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toShiftResponse(*s))
+}
+
+func (h ShiftHandler) close(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s, err := h.Repo.Close(r.Context(), ownerID, chi.URLParam(r, "id"), &user.ID)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toShiftResponse(*s))
+}
+
+func (h ShiftHandler) get(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s, err := h.Repo.Get(r.Context(), ownerID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toShiftResponse(*s))
+}
+
+// current returns the open shift of the terminal in terminalId.
+func (h ShiftHandler) current(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	terminalID := strings.TrimSpace(r.URL.Query().Get("terminalId"))
+	if terminalID == "" {
+		writeError(w, http.StatusBadRequest, "terminalId is required")
+		return
+	}
+	s, err := h.Repo.Current(r.Context(), ownerID, terminalID)
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "no open shift on this terminal")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toShiftResponse(*s))
+}
+
+// list returns shifts newest first, narrowed by status (open or closed) and terminalId.
+func (h ShiftHandler) list(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	f := repository.ShiftFilter{
+		Status:     strings.ToLower(strings.TrimSpace(q.Get("status"))),
+		TerminalID: strings.TrimSpace(q.Get("terminalId")),
+		Limit:      50,
+	}
+	if f.Status != "" && f.Status != string(domain.ShiftOpen) && f.Status != string(domain.ShiftClosed) {
+		writeError(w, http.StatusBadRequest, "status must be open or closed")
+		return
+	}
+	if parsed, err := strconv.Atoi(q.Get("limit")); err == nil && parsed > 0 && parsed <= 200 {
+		f.Limit = parsed
+	}
+	items, err := h.Repo.List(r.Context(), ownerID, f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := make([]map[string]any, 0, len(items))
+	for _, s := range items {
+		resp = append(resp, toShiftResponse(s))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeShiftError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "shift not found")
+	case errors.Is(err, repository.ErrShiftClosed):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func toShiftResponse(s domain.Shift) map[string]any {
+	return map[string]any{
+		"id":           s.ID,
+		"terminalId":   s.TerminalID,
+		"operatorName": s.OperatorName,
+		"status":       string(s.Status),
+		"note":         s.Note,
+		"openedBy":     s.OpenedBy,
+		"openedAt":     s.OpenedAt,
+		"closedBy":     s.ClosedBy,
+		"closedAt":     s.ClosedAt,
+	}
+}
+
+// orderShift applies the owner's shift policy to an order and returns the shift it is recorded under; nil
+// when the policy is off, in which case shiftId is kept as sent. A named shift must have been open when the
+// order was rung up (now, or transactedAt for offline orders). Without one, the reject policy refuses the
+// order and auto attaches it to the shift open on its terminal, or to the only open shift when the order
+// names no terminal.
+func (h TransactionHandler) orderShift(ctx context.Context, ownerID int64, req orderPayload, policy domain.ShiftPolicy) (*domain.Shift, error) {
+	if policy == "" || policy == domain.ShiftPolicyOff {
+		return nil, nil
+	}
+	if id := strings.TrimSpace(req.ShiftID); id != "" {
+		s, err := h.Shifts.Get(ctx, ownerID, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, &orderError{Status: http.StatusConflict, Message: "shift not found"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !shiftCovers(*s, req.transactedAt) {
+			return nil, &orderError{Status: http.StatusConflict, Message: "shift is closed", Data: map[string]any{
+				"shiftId":  s.ID,
+				"closedAt": s.ClosedAt,
+			}}
+		}
+		return s, nil
+	}
+	if policy == domain.ShiftPolicyReject {
+		return nil, &orderError{Status: http.StatusConflict, Message: "an open shift is required; open one with POST /shifts/open"}
+	}
+	shifts, err := h.Shifts.Covering(ctx, ownerID, strings.TrimSpace(req.TerminalID), req.transactedAt)
+	if err != nil {
+		return nil, err
+	}
+	switch len(shifts) {
+	case 1:
+		return &shifts[0], nil
+	case 0:
+		return nil, &orderError{Status: http.StatusConflict, Message: "no open shift to attach the order to; open one with POST /shifts/open"}
+	default:
+		return nil, &orderError{Status: http.StatusConflict, Message: "several shifts are open; send terminalId or shiftId"}
+	}
+}
+
+// shiftCovers reports whether s was open at at; a zero at means now.
+func shiftCovers(s domain.Shift, at time.Time) bool {
+	if at.IsZero() {
+		return s.Status == domain.ShiftOpen
+	}
+	return !at.Before(s.OpenedAt) && (s.ClosedAt == nil || !at.After(*s.ClosedAt))
+}
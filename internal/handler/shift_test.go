@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestShiftCovers(t *testing.T) {
+	opened := time.Date(2025, 1, 31, 8, 0, 0, 0, time.UTC)
+	closedAt := opened.Add(8 * time.Hour)
+	open := domain.Shift{Status: domain.ShiftOpen, OpenedAt: opened}
+	closed := domain.Shift{Status: domain.ShiftClosed, OpenedAt: opened, ClosedAt: &closedAt}
+
+	tests := []struct {
+		name  string
+		shift domain.Shift
+		at    time.Time
+		want  bool
+	}{
+		{"open shift, now", open, time.Time{}, true},
+		{"closed shift, now", closed, time.Time{}, false},
+		{"before it opened", open, opened.Add(-time.Minute), false},
+		{"as it opened", open, opened, true},
+		{"still open", open, opened.Add(24 * time.Hour), true},
+		{"while it was open", closed, opened.Add(time.Hour), true},
+		{"as it closed", closed, closedAt, true},
+		{"after it closed", closed, closedAt.Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		if got := shiftCovers(tt.shift, tt.at); got != tt.want {
+			t.Errorf("%s: shiftCovers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"barberpos-backend/internal/domain"
@@ -24,14 +25,36 @@ type TransactionHandler struct {
 	Employees  repository.EmployeeRepository
 	Stocks     repository.StockRepository
 	Finance    repository.FinanceRepository
+	Pricing    *service.PricingService
+	Promotions repository.PromotionRepository
+	Settings   repository.SettingsRepository
+	Printer    *service.PrintService
+	Drafts     repository.DraftOrderRepository
+	// Payments checks referenced payment intents with the provider before the order is recorded.
+	Payments *service.PaymentService
+	// Shifts checks or picks the order's shift under the owner's shift policy.
+	Shifts repository.ShiftRepository
+	// ActivityLogs records wrong manager PINs typed to approve a void.
+	ActivityLogs repository.ActivityLogRepository
 }
 
 func (h TransactionHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/orders", h.createOrder)
+	r.Post("/orders/batch", h.createOrderBatch)
 	r.Get("/transactions", h.listTransactions)
 	r.Get("/transactions/{code}", h.getByCode)
+	r.Get("/transactions/{code}/receipt", h.receipt)
 	r.Post("/transactions/{code}/refund", h.refund)
+	r.Post("/transactions/{code}/void", h.void)
 	r.Post("/transactions/{code}/mark-paid", h.markPaid)
+	r.Post("/drafts", h.createDraft)
+	r.Get("/drafts", h.listDrafts)
+	r.Get("/drafts/{id}", h.getDraft)
+	r.Put("/drafts/{id}", h.updateDraft)
+	r.Delete("/drafts/{id}", h.cancelDraft)
+	r.Post("/drafts/{id}/lines", h.addDraftLine)
+	r.Delete("/drafts/{id}/lines/{lineId}", h.removeDraftLine)
+	r.Post("/drafts/{id}/pay", h.payDraft)
 }
 
 type orderPayload struct {
@@ -44,15 +67,71 @@ type orderPayload struct {
 	Stylist       string      `json:"stylist"`
 	StylistID     *int64      `json:"stylistId"`
 	Customer      string      `json:"customer"`
+	CustomerID    *int64      `json:"customerId"`
+	CustomerPhone string      `json:"customerPhone"`
 	ShiftID       string      `json:"shiftId"`
+	PromoCode     string      `json:"promoCode"`
+	PromoCodes    []string    `json:"promoCodes"`
+	Discount      *discountIn `json:"discount"`
+	Payments      []paymentIn `json:"payments"`
+	Tip           int64       `json:"tip"`
+	Tips          []tipIn     `json:"tips"`
+
+	// PaymentIntentID is the paid intent behind a single-tender order; split tenders carry their own.
+	PaymentIntentID string `json:"paymentIntentId"`
+	// TerminalID is the device ringing up the order; under the auto shift policy it picks the shift.
+	TerminalID string `json:"terminalId"`
+
+	// transactedAt is the device time of an order synced from the offline queue; zero means now.
+	transactedAt time.Time
+	// byManager marks orders placed by a manager, who may ring up custom items and give manual discounts
+	// whatever the settings say.
+	byManager bool
+}
+
+// tipIn is one stylist's share of the tip; a bare top-level tip goes to the order's stylist.
+type tipIn struct {
+	StylistID *int64 `json:"stylistId"`
+	Stylist   string `json:"stylist"`
+	Amount    int64  `json:"amount"`
+}
+
+// paymentIn is one tender of a split payment; amounts must add up to the order total.
+type paymentIn struct {
+	Method          string `json:"method"`
+	Amount          int64  `json:"amount"`
+	Reference       string `json:"reference"`
+	PaymentIntentID string `json:"paymentIntentId"`
 }
 
 type orderLine struct {
-	ProductID *int64 `json:"productId"`
-	Name      string `json:"name"`
-	Category  string `json:"category"`
-	Price     int64  `json:"price"`
-	Qty       int    `json:"qty"`
+	ProductID *int64      `json:"productId"`
+	Name      string      `json:"name"`
+	Category  string      `json:"category"`
+	Price     int64       `json:"price"`
+	Qty       int         `json:"qty"`
+	Discount  *discountIn `json:"discount"`
+	// StylistID/Stylist credit this line to another stylist than the order's.
+	StylistID *int64 `json:"stylistId"`
+	Stylist   string `json:"stylist"`
+}
+
+// discountIn is a manual discount entered by the cashier: type is "percent" or "fixed".
+type discountIn struct {
+	Type  string `json:"type"`
+	Value int64  `json:"value"`
+	Note  string `json:"note"`
+}
+
+func (d *discountIn) toManual() *service.ManualDiscount {
+	if d == nil {
+		return nil
+	}
+	return &service.ManualDiscount{
+		Kind:  domain.DiscountKind(strings.ToLower(strings.TrimSpace(d.Type))),
+		Value: d.Value,
+		Note:  d.Note,
+	}
 }
 
 func (h TransactionHandler) createOrder(w http.ResponseWriter, r *http.Request) {
@@ -74,17 +153,55 @@ func (h TransactionHandler) createOrder(w http.ResponseWriter, r *http.Request)
 	if req.ClientRef == "" {
 		req.ClientRef = r.Header.Get("X-Idempotency-Key")
 	}
+	req.byManager = isManager(*user)
 
-	var membershipOwnerID int64
-	if h.Membership != nil {
-		resolved, err := resolveOwnerID(r.Context(), *user, h.Employees)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-		membershipOwnerID = resolved
+	tx, _, err := h.placeOrder(r.Context(), ownerID, req, nil)
+	if err != nil {
+		writeOrderError(w, err)
+		return
 	}
+	writeJSON(w, http.StatusOK, toPlacedOrderResponse(tx, req))
+}
+
+// orderError is a rejected order: the HTTP status, message and optional details for the client.
+type orderError struct {
+	Status  int
+	Message string
+	Data    any
+}
 
+func (e *orderError) Error() string {
+	return e.Message
+}
+
+func writeOrderError(w http.ResponseWriter, err error) {
+	var oe *orderError
+	if !errors.As(err, &oe) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if oe.Data != nil {
+		writeErrorData(w, oe.Status, oe.Message, oe.Data)
+		return
+	}
+	writeError(w, oe.Status, oe.Message)
+}
+
+// placeOrder prices and records one order, consuming promotions, stock and membership quota.
+// after, when set, runs in the same database transaction once the order is inserted.
+// created is false when req.ClientRef was already recorded and that order is returned instead.
+func (h TransactionHandler) placeOrder(ctx context.Context, ownerID int64, req orderPayload, after func(context.Context, pgx.Tx) error) (tx *domain.Transaction, created bool, err error) {
+	// A retry is answered with the recorded order before anything is re-priced or re-checked: prices,
+	// promotions, stock, the shift and payment intents may all have moved on since it was recorded.
+	if req.ClientRef != "" {
+		existing, err := h.Repo.GetByClientRef(ctx, ownerID, req.ClientRef)
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, false, err
+		}
+	}
 	items := make([]repository.CreateTransactionItem, 0, len(req.Items))
 	for _, it := range req.Items {
 		items = append(items, repository.CreateTransactionItem{
@@ -93,7 +210,83 @@ func (h TransactionHandler) createOrder(w http.ResponseWriter, r *http.Request)
 			Category:  it.Category,
 			Price:     it.Price,
 			Qty:       it.Qty,
+			StylistID: it.StylistID,
+			Stylist:   strings.TrimSpace(it.Stylist),
+		})
+	}
+
+	settings, err := h.Settings.Get(ctx, ownerID)
+	if err != nil {
+		return nil, false, err
+	}
+	var operator string
+	shift, err := h.orderShift(ctx, ownerID, req, settings.ShiftPolicy)
+	if err != nil {
+		return nil, false, err
+	}
+	if shift != nil {
+		req.ShiftID, operator = shift.ID, shift.OperatorName
+	}
+
+	// Without a pricing service the client's figures are trusted as-is.
+	priced := &service.PricedOrder{Items: items, Subtotal: req.Total, Total: req.Total}
+	if h.Pricing != nil {
+		lines := make([]service.PriceOrderLine, 0, len(items))
+		for i, it := range items {
+			lines = append(lines, service.PriceOrderLine{Item: it, Discount: req.Items[i].Discount.toManual()})
+		}
+		promoCodes := req.PromoCodes
+		if req.PromoCode != "" {
+			promoCodes = append(promoCodes, req.PromoCode)
+		}
+		priced, err = h.Pricing.PriceOrder(ctx, ownerID, service.PriceOrderInput{
+			Lines:         lines,
+			PromoCodes:    promoCodes,
+			OrderDiscount: req.Discount.toManual(),
+			ClientTotal:   req.Total,
+			Settings:      settings,
+
+			AllowCustomItems:     req.byManager || settings.AllowCustomItems,
+			AllowManualDiscounts: req.byManager || settings.AllowManualDiscounts,
+			At:                   req.transactedAt,
 		})
+		if err != nil {
+			var pe *service.PricingError
+			if errors.As(err, &pe) {
+				return nil, false, &orderError{Status: http.StatusUnprocessableEntity, Message: "order pricing mismatch", Data: map[string]any{
+					"discrepancies": toDiscrepancies(pe.Discrepancies),
+				}}
+			}
+			return nil, false, err
+		}
+	}
+	items = priced.Items
+	discounts := priced.Discounts
+
+	tips, tipTotal, msg := toCreateTips(req)
+	if msg != "" {
+		return nil, false, &orderError{Status: http.StatusBadRequest, Message: msg}
+	}
+
+	rounding := cashRounding(req, priced.Total+tipTotal, settings)
+	payments, paymentMethod, msg := toCreatePayments(req.Payments, req.PaymentMethod, priced.Total+tipTotal+rounding)
+	if msg != "" {
+		return nil, false, &orderError{Status: http.StatusUnprocessableEntity, Message: msg, Data: map[string]any{
+			"expected":           priced.Total + tipTotal + rounding,
+			"actual":             sumPayments(payments),
+			"roundingAdjustment": rounding,
+		}}
+	}
+	tenders := payments
+	if len(tenders) == 0 {
+		tenders = []repository.CreateTransactionPayment{{
+			Method:          strings.ToLower(strings.TrimSpace(paymentMethod)),
+			Amount:          priced.Total + tipTotal + rounding,
+			PaymentIntentID: strPtr(req.PaymentIntentID),
+		}}
+	}
+	if err := h.checkPaymentIntents(ctx, ownerID, tenders); err != nil {
+		return nil, false, err
 	}
 
 	unitsToConsume := countUnits(req.Items)
@@ -102,16 +295,49 @@ func (h TransactionHandler) createOrder(w http.ResponseWriter, r *http.Request)
 	if req.ClientRef != "" {
 		clientRef = &req.ClientRef
 	}
-	tx, err := h.Repo.Create(r.Context(), ownerID, repository.CreateTransactionInput{
-		PaymentMethod: req.PaymentMethod,
-		Stylist:       req.Stylist,
-		StylistID:     req.StylistID,
-		CustomerName:  req.Customer,
-		Amount:        req.Total,
-		Items:         items,
-		ShiftID:       strPtr(req.ShiftID),
-		ClientRef:     clientRef,
+	var customer *repository.CustomerLink
+	if req.CustomerID != nil || strings.TrimSpace(req.CustomerPhone) != "" {
+		customer = &repository.CustomerLink{ID: req.CustomerID, Phone: req.CustomerPhone, Name: req.Customer}
+	}
+	tx, err = h.Repo.Create(ctx, ownerID, repository.CreateTransactionInput{
+		PaymentMethod:     paymentMethod,
+		Stylist:           req.Stylist,
+		StylistID:         req.StylistID,
+		CustomerName:      req.Customer,
+		Amount:            priced.Total,
+		Subtotal:          priced.Subtotal,
+		DiscountTotal:     priced.DiscountTotal,
+		ServiceChargeRate: priced.ServiceChargeRate,
+		ServiceCharge:     priced.ServiceCharge,
+		TaxRate:           priced.TaxRate,
+		TaxInclusive:      priced.TaxInclusive,
+		TaxAmount:         priced.TaxAmount,
+		Items:             items,
+		Discounts:         discounts,
+		Payments:          payments,
+		Tips:              tips,
+		ShiftID:           strPtr(req.ShiftID),
+		OperatorName:      operator,
+		ClientRef:         clientRef,
+		TransactedAt:      req.transactedAt,
+		LinkCustomer:      customer,
+		Rounding:          rounding,
+		PaymentIntentID:   strPtr(req.PaymentIntentID),
 	}, func(ctx context.Context, tx pgx.Tx) error {
+		created = true
+		redeemed := make(map[int64]struct{})
+		for _, d := range discounts {
+			if d.PromotionID == nil {
+				continue
+			}
+			if _, ok := redeemed[*d.PromotionID]; ok {
+				continue
+			}
+			redeemed[*d.PromotionID] = struct{}{}
+			if err := h.Promotions.RedeemWithTx(ctx, tx, ownerID, *d.PromotionID); err != nil {
+				return err
+			}
+		}
 		for _, it := range items {
 			if it.ProductID == nil || it.Qty <= 0 {
 				continue
@@ -119,26 +345,64 @@ func (h TransactionHandler) createOrder(w http.ResponseWriter, r *http.Request)
 			// Best-effort: only affects products that track stock (stocks row exists).
 			_ = h.Stocks.AdjustByProductIDWithTx(ctx, tx, ownerID, *it.ProductID, -it.Qty, "sale", "sale")
 		}
-		if h.Membership == nil {
-			return nil
+		if h.Membership != nil {
+			if _, err := h.Membership.ConsumeWithTx(ctx, tx, ownerID, unitsToConsume); err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			return after(ctx, tx)
 		}
-		_, err := h.Membership.ConsumeWithTx(ctx, tx, membershipOwnerID, unitsToConsume)
-		return err
+		return nil
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
+		if errors.Is(err, repository.ErrPromotionExhausted) {
+			return nil, false, &orderError{Status: http.StatusConflict, Message: "promo code usage limit reached"}
+		}
+		if errors.Is(err, repository.ErrCustomerNotFound) {
+			return nil, false, &orderError{Status: http.StatusBadRequest, Message: "customer not found"}
+		}
+		if errors.Is(err, repository.ErrPaymentIntentUnavailable) {
+			return nil, false, &orderError{Status: http.StatusConflict, Message: "payment intent is not available"}
+		}
+		return nil, false, err
+	}
+	// Orders replayed from the offline queue were already printed on the device.
+	if created && h.Printer != nil && req.transactedAt.IsZero() {
+		// Best-effort: a printer problem must not fail a recorded sale; GET /print-jobs shows it.
+		_ = h.Printer.AutoPrintReceipt(ctx, ownerID, *tx)
 	}
+	return tx, created, nil
+}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"id":            strconv.FormatInt(tx.ID, 10),
-		"code":          tx.Code,
-		"total":         req.Total,
-		"paid":          req.Paid,
-		"change":        req.Change,
-		"paymentMethod": req.PaymentMethod,
-		"items":         toOrderLines(tx.Items),
-	})
+func toPlacedOrderResponse(tx *domain.Transaction, req orderPayload) map[string]any {
+	return map[string]any{
+		"id":                 strconv.FormatInt(tx.ID, 10),
+		"code":               tx.Code,
+		"receiptNumber":      tx.ReceiptNumber,
+		"status":             string(tx.Status),
+		"subtotal":           tx.Subtotal.Amount,
+		"discountTotal":      tx.DiscountTotal.Amount,
+		"discounts":          toDiscountLines(tx.Discounts),
+		"serviceCharge":      tx.ServiceCharge.Amount,
+		"taxAmount":          tx.TaxAmount.Amount,
+		"taxRate":            tx.TaxRate,
+		"taxInclusive":       tx.TaxInclusive,
+		"total":              tx.Amount.Amount,
+		"currency":           tx.Amount.Currency,
+		"paid":               req.Paid,
+		"change":             req.Change,
+		"paymentMethod":      tx.PaymentMethod,
+		"payments":           toPaymentLines(tx.Payments),
+		"paymentIntentId":    tx.PaymentIntentID,
+		"tipTotal":           tx.TipTotal.Amount,
+		"tips":               toTipLines(tx.Tips),
+		"roundingAdjustment": tx.Rounding.Amount,
+		"items":              toOrderLines(tx.Items),
+		"customerId":         tx.CustomerID,
+		"customer":           toCustomerSnapshot(tx),
+		"shiftId":            tx.ShiftID,
+	}
 }
 
 func (h TransactionHandler) listTransactions(w http.ResponseWriter, r *http.Request) {
@@ -152,77 +416,119 @@ func (h TransactionHandler) listTransactions(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	startDate, err := parseDateQuery(r, "startDate")
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid startDate")
-		return
-	}
-	endDate, err := parseDateQuery(r, "endDate")
+	filter, err := parseTransactionFilter(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid endDate")
-		return
-	}
-	if startDate != nil && endDate != nil && startDate.After(*endDate) {
-		writeError(w, http.StatusBadRequest, "startDate must be before endDate")
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
+	// Clients that do not page get a bare array, as before: every row of a date range, otherwise the
+	// newest 200 with X-Truncated and X-Next-Cursor telling them when more rows match.
+	paged := r.URL.Query().Has("cursor") || r.URL.Query().Has("limit")
 	var txs []domain.Transaction
-	if startDate != nil || endDate != nil {
-		txs, err = h.Repo.ListFiltered(r.Context(), ownerID, startDate, endDate)
-	} else {
-		txs, err = h.Repo.List(r.Context(), ownerID, 200)
+	var nextCursor string
+	switch {
+	case paged:
+		txs, nextCursor, err = h.Repo.ListPage(r.Context(), ownerID, filter)
+	case filter.StartDate != nil || filter.EndDate != nil:
+		txs, err = h.Repo.ListAll(r.Context(), ownerID, filter)
+	default:
+		filter.Limit = repository.MaxTransactionPageSize
+		txs, nextCursor, err = h.Repo.ListPage(r.Context(), ownerID, filter)
 	}
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	resp := make([]map[string]any, 0, len(txs))
 	for _, t := range txs {
-		customer := map[string]any{
-			"name":      "",
-			"phone":     "",
-			"email":     "",
-			"address":   "",
-			"visits":    nil,
-			"lastVisit": nil,
-		}
-		if t.Customer != nil {
-			customer["name"] = t.Customer.Name
-			customer["phone"] = t.Customer.Phone
-			customer["email"] = t.Customer.Email
-			customer["address"] = t.Customer.Address
-			customer["visits"] = t.Customer.Visits
-			customer["lastVisit"] = t.Customer.LastVisit
-		}
-
+		customer := toCustomerSnapshot(&t)
 		resp = append(resp, map[string]any{
-			"id":            strconv.FormatInt(t.ID, 10),
-			"code":          t.Code,
-			"date":          t.Date.Format("2006-01-02"),
-			"time":          t.Time,
-			"amount":        t.Amount.Amount,
-			"paymentMethod": t.PaymentMethod,
-			"status":        string(t.Status),
-			"refundedAt":    t.RefundedAt,
-			"refundNote":    t.RefundNote,
-			"stylist":       t.Stylist,
-			"stylistId":     t.StylistID,
-			"items":         toOrderLines(t.Items),
-			"customer":      customer,
+			"id":                 strconv.FormatInt(t.ID, 10),
+			"code":               t.Code,
+			"receiptNumber":      t.ReceiptNumber,
+			"date":               t.Date.Format("2006-01-02"),
+			"time":               t.Time,
+			"amount":             t.Amount.Amount,
+			"currency":           t.Amount.Currency,
+			"subtotal":           t.Subtotal.Amount,
+			"discountTotal":      t.DiscountTotal.Amount,
+			"serviceCharge":      t.ServiceCharge.Amount,
+			"taxAmount":          t.TaxAmount.Amount,
+			"paymentMethod":      t.PaymentMethod,
+			"payments":           toPaymentLines(t.Payments),
+			"paymentIntentId":    t.PaymentIntentID,
+			"tipTotal":           t.TipTotal.Amount,
+			"roundingAdjustment": t.Rounding.Amount,
+			"status":             string(t.Status),
+			"refundedAt":         t.RefundedAt,
+			"refundNote":         t.RefundNote,
+			"refundedAmount":     t.RefundedAmount.Amount,
+			"voidedAt":           t.VoidedAt,
+			"voidReason":         t.VoidReason,
+			"stylist":            t.Stylist,
+			"stylistId":          t.StylistID,
+			"items":              toOrderLines(t.Items),
+			"customer":           customer,
 		})
 	}
-	writeJSON(w, http.StatusOK, resp)
+	if !paged {
+		if nextCursor != "" {
+			w.Header().Set("X-Truncated", "true")
+			w.Header().Set("X-Next-Cursor", nextCursor)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	var next any
+	if nextCursor != "" {
+		next = nextCursor
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"items":      resp,
+		"nextCursor": next,
+	})
+}
+
+// toCustomerSnapshot is the customer as recorded on the transaction; id is set when it is linked to a customer record.
+func toCustomerSnapshot(t *domain.Transaction) map[string]any {
+	customer := map[string]any{
+		"id":        t.CustomerID,
+		"name":      "",
+		"phone":     "",
+		"email":     "",
+		"address":   "",
+		"visits":    nil,
+		"lastVisit": nil,
+	}
+	if t.Customer != nil {
+		customer["name"] = t.Customer.Name
+		customer["phone"] = t.Customer.Phone
+		customer["email"] = t.Customer.Email
+		customer["address"] = t.Customer.Address
+		customer["visits"] = t.Customer.Visits
+		customer["lastVisit"] = t.Customer.LastVisit
+	}
+	return customer
 }
 
 func toOrderLines(items []domain.TransactionItem) []map[string]any {
 	out := make([]map[string]any, 0, len(items))
 	for _, it := range items {
 		m := map[string]any{
-			"name":     it.Name,
-			"category": it.Category,
-			"price":    it.Price.Amount,
-			"qty":      it.Qty,
+			"id":          it.ID,
+			"name":        it.Name,
+			"category":    it.Category,
+			"price":       it.Price.Amount,
+			"qty":         it.Qty,
+			"discount":    it.Discount.Amount,
+			"refundedQty": it.RefundedQty,
+			"stylistId":   it.StylistID,
+			"stylist":     it.Stylist,
+			"custom":      it.Custom,
 		}
 		if it.ProductID != nil {
 			m["productId"] = *it.ProductID
@@ -232,6 +538,203 @@ func toOrderLines(items []domain.TransactionItem) []map[string]any {
 	return out
 }
 
+// checkPaymentIntents makes sure every intent the order references is paid or still payable, unused and matches
+// its tender, asking the provider first about intents still pending. The insert checks again under lock.
+func (h TransactionHandler) checkPaymentIntents(ctx context.Context, ownerID int64, tenders []repository.CreateTransactionPayment) error {
+	if h.Payments == nil {
+		return nil
+	}
+	for _, p := range tenders {
+		if p.PaymentIntentID == nil {
+			continue
+		}
+		intent, err := h.Payments.Refresh(ctx, ownerID, *p.PaymentIntentID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return &orderError{Status: http.StatusBadRequest, Message: "payment intent not found"}
+			}
+			return err
+		}
+		// A pending intent is accepted; the sale is recorded as pending until the provider confirms it.
+		switch {
+		case intent.Status != domain.PaymentIntentPaid && (intent.Status != domain.PaymentIntentPending || time.Now().After(intent.ExpiresAt)):
+			return &orderError{Status: http.StatusConflict, Message: "payment intent is not paid", Data: map[string]any{
+				"paymentIntentId": intent.ID,
+				"status":          string(intent.Status),
+			}}
+		case intent.TransactionID != nil:
+			return &orderError{Status: http.StatusConflict, Message: "payment intent already used", Data: map[string]any{
+				"paymentIntentId": intent.ID,
+				"transactionId":   intent.TransactionID,
+			}}
+		case intent.Method != p.Method || intent.Amount.Amount != p.Amount:
+			return &orderError{Status: http.StatusUnprocessableEntity, Message: "payment intent does not match the tender", Data: map[string]any{
+				"paymentIntentId": intent.ID,
+				"method":          intent.Method,
+				"amount":          intent.Amount.Amount,
+			}}
+		}
+	}
+	return nil
+}
+
+// toCreatePayments validates split tenders against the order total and picks the header payment method:
+// the single method used, or "split" when several are. With no tenders the header method covers the total.
+func toCreatePayments(in []paymentIn, method string, total int64) ([]repository.CreateTransactionPayment, string, string) {
+	if len(in) == 0 {
+		return nil, method, ""
+	}
+	out := make([]repository.CreateTransactionPayment, 0, len(in))
+	methods := make(map[string]struct{}, len(in))
+	for _, p := range in {
+		m := strings.ToLower(strings.TrimSpace(p.Method))
+		if m == "" {
+			return out, "", "payment method is required"
+		}
+		if p.Amount <= 0 {
+			return out, "", "payment amount must be positive"
+		}
+		methods[m] = struct{}{}
+		out = append(out, repository.CreateTransactionPayment{
+			Method:          m,
+			Amount:          p.Amount,
+			Reference:       strPtr(p.Reference),
+			PaymentIntentID: strPtr(p.PaymentIntentID),
+		})
+	}
+	if sumPayments(out) != total {
+		return out, "", "payments do not add up to total"
+	}
+	if len(methods) == 1 {
+		return out, out[0].Method, ""
+	}
+	return out, "split", ""
+}
+
+// cashRounding is the rounding applied to the cash part of an order when the owner rounds cash prices.
+// Non-cash tenders are charged exactly, so only what is left for cash is rounded; the cash tender then has to
+// include the adjustment.
+func cashRounding(req orderPayload, due int64, settings *domain.Settings) int64 {
+	if len(req.Payments) == 0 {
+		if !isCashMethod(req.PaymentMethod) {
+			return 0
+		}
+		return service.CashRounding(due, settings)
+	}
+	hasCash := false
+	for _, p := range req.Payments {
+		if isCashMethod(p.Method) {
+			hasCash = true
+		} else {
+			due -= p.Amount
+		}
+	}
+	if !hasCash {
+		return 0
+	}
+	return service.CashRounding(due, settings)
+}
+
+func isCashMethod(method string) bool {
+	return strings.EqualFold(strings.TrimSpace(method), "cash")
+}
+
+// toCreateTips turns the request's tip split into rows. A plain tip without a split goes to the order's stylist.
+// Tips are not items, so they never count towards membership units.
+func toCreateTips(req orderPayload) ([]repository.CreateTransactionTip, int64, string) {
+	in := req.Tips
+	if len(in) == 0 && req.Tip != 0 {
+		in = []tipIn{{StylistID: req.StylistID, Stylist: req.Stylist, Amount: req.Tip}}
+	}
+	out := make([]repository.CreateTransactionTip, 0, len(in))
+	var total int64
+	for _, t := range in {
+		if t.Amount <= 0 {
+			return nil, 0, "tip amount must be positive"
+		}
+		out = append(out, repository.CreateTransactionTip{
+			StylistID: t.StylistID,
+			Stylist:   strings.TrimSpace(t.Stylist),
+			Amount:    t.Amount,
+		})
+		total += t.Amount
+	}
+	if len(req.Tips) > 0 && req.Tip != 0 && req.Tip != total {
+		return nil, 0, "tip does not match the sum of tips"
+	}
+	return out, total, ""
+}
+
+func toTipLines(tips []domain.TransactionTip) []map[string]any {
+	out := make([]map[string]any, 0, len(tips))
+	for _, t := range tips {
+		out = append(out, map[string]any{
+			"stylistId": t.StylistID,
+			"stylist":   t.Stylist,
+			"amount":    t.Amount.Amount,
+		})
+	}
+	return out
+}
+
+func sumPayments(payments []repository.CreateTransactionPayment) int64 {
+	var sum int64
+	for _, p := range payments {
+		sum += p.Amount
+	}
+	return sum
+}
+
+func toPaymentLines(payments []domain.TransactionPayment) []map[string]any {
+	out := make([]map[string]any, 0, len(payments))
+	for _, p := range payments {
+		out = append(out, map[string]any{
+			"method":          p.Method,
+			"amount":          p.Amount.Amount,
+			"reference":       p.Reference,
+			"paymentIntentId": p.PaymentIntentID,
+		})
+	}
+	return out
+}
+
+func toDiscountLines(items []domain.TransactionDiscount) []map[string]any {
+	out := make([]map[string]any, 0, len(items))
+	for _, d := range items {
+		out = append(out, map[string]any{
+			"code":              d.Code,
+			"type":              string(d.Kind),
+			"scope":             string(d.Scope),
+			"value":             d.Value,
+			"amount":            d.Amount.Amount,
+			"note":              d.Note,
+			"promotionId":       d.PromotionID,
+			"transactionItemId": d.TransactionItemID,
+		})
+	}
+	return out
+}
+
+func toDiscrepancies(items []service.PriceDiscrepancy) []map[string]any {
+	out := make([]map[string]any, 0, len(items))
+	for _, d := range items {
+		m := map[string]any{
+			"field":    d.Field,
+			"expected": d.Expected,
+			"actual":   d.Actual,
+			"message":  d.Message,
+		}
+		if d.Line >= 0 {
+			m["line"] = d.Line
+		}
+		if d.ProductID != nil {
+			m["productId"] = *d.ProductID
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
 func strPtr(s string) *string {
 	if s == "" {
 		return nil
@@ -275,36 +778,43 @@ func (h TransactionHandler) getByCode(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	customer := map[string]any{
-		"name":      "",
-		"phone":     "",
-		"email":     "",
-		"address":   "",
-		"visits":    nil,
-		"lastVisit": nil,
-	}
-	if t.Customer != nil {
-		customer["name"] = t.Customer.Name
-		customer["phone"] = t.Customer.Phone
-		customer["email"] = t.Customer.Email
-		customer["address"] = t.Customer.Address
-		customer["visits"] = t.Customer.Visits
-		customer["lastVisit"] = t.Customer.LastVisit
-	}
+	customer := toCustomerSnapshot(t)
 	writeJSON(w, http.StatusOK, map[string]any{
-		"id":            strconv.FormatInt(t.ID, 10),
-		"code":          t.Code,
-		"date":          t.Date.Format("2006-01-02"),
-		"time":          t.Time,
-		"amount":        t.Amount.Amount,
-		"paymentMethod": t.PaymentMethod,
-		"status":        string(t.Status),
-		"refundedAt":    t.RefundedAt,
-		"refundNote":    t.RefundNote,
-		"stylist":       t.Stylist,
-		"stylistId":     t.StylistID,
-		"items":         toOrderLines(t.Items),
-		"customer":      customer,
+		"id":                 strconv.FormatInt(t.ID, 10),
+		"code":               t.Code,
+		"receiptNumber":      t.ReceiptNumber,
+		"date":               t.Date.Format("2006-01-02"),
+		"time":               t.Time,
+		"amount":             t.Amount.Amount,
+		"currency":           t.Amount.Currency,
+		"subtotal":           t.Subtotal.Amount,
+		"discountTotal":      t.DiscountTotal.Amount,
+		"discounts":          toDiscountLines(t.Discounts),
+		"serviceCharge":      t.ServiceCharge.Amount,
+		"serviceRate":        t.ServiceChargeRate,
+		"taxAmount":          t.TaxAmount.Amount,
+		"taxRate":            t.TaxRate,
+		"taxInclusive":       t.TaxInclusive,
+		"paymentMethod":      t.PaymentMethod,
+		"payments":           toPaymentLines(t.Payments),
+		"paymentIntentId":    t.PaymentIntentID,
+		"tipTotal":           t.TipTotal.Amount,
+		"roundingAdjustment": t.Rounding.Amount,
+		"tips":               toTipLines(t.Tips),
+		"status":             string(t.Status),
+		"refundedAt":         t.RefundedAt,
+		"refundNote":         t.RefundNote,
+		"refundedAmount":     t.RefundedAmount.Amount,
+		"refunds":            toRefundLines(t.Refunds),
+		"voidedAt":           t.VoidedAt,
+		"voidedBy":           t.VoidedBy,
+		"voidApprovedBy":     t.VoidApprovedBy,
+		"voidReason":         t.VoidReason,
+		"voidNote":           t.VoidNote,
+		"stylist":            t.Stylist,
+		"stylistId":          t.StylistID,
+		"items":              toOrderLines(t.Items),
+		"customer":           customer,
 	})
 }
 
@@ -323,6 +833,11 @@ func (h TransactionHandler) refund(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Note   string `json:"note"`
 		Delete *bool  `json:"delete"`
+		Items  []struct {
+			ItemID int64 `json:"itemId"`
+			Qty    int   `json:"qty"`
+		} `json:"items"`
+		Amount int64 `json:"amount"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 		writeError(w, http.StatusBadRequest, "invalid payload")
@@ -343,7 +858,19 @@ func (h TransactionHandler) refund(w http.ResponseWriter, r *http.Request) {
 		membershipOwnerID = resolved
 	}
 
-	err := h.Repo.RefundByCode(
+	lines := make([]repository.RefundLineInput, 0, len(req.Items))
+	for _, it := range req.Items {
+		lines = append(lines, repository.RefundLineInput{ItemID: it.ItemID, Qty: it.Qty})
+	}
+
+	loc, err := h.Settings.Location(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var status domain.TransactionStatus
+	refund, err := h.Repo.RefundByCode(
 		r.Context(),
 		ownerID,
 		repository.RefundTransactionParams{
@@ -351,40 +878,82 @@ func (h TransactionHandler) refund(w http.ResponseWriter, r *http.Request) {
 			Note:       req.Note,
 			Delete:     deleteFlag,
 			RefundedBy: &user.ID,
+			Lines:      lines,
+			Amount:     req.Amount,
 		},
-		func(ctx context.Context, tx pgx.Tx, t domain.Transaction, items []repository.RefundItem, units int) error {
-			for _, it := range items {
+		func(ctx context.Context, tx pgx.Tx, t domain.Transaction, refund domain.Refund) error {
+			status = t.Status
+			for _, it := range refund.Items {
 				if it.ProductID == nil || it.Qty <= 0 {
 					continue
 				}
-				_ = h.Stocks.AdjustByProductIDWithTx(ctx, tx, ownerID, *it.ProductID, it.Qty, "refund", "refund "+code)
+				_ = h.Stocks.AdjustByProductIDWithTx(ctx, tx, ownerID, *it.ProductID, it.Qty, "refund", "refund "+t.Code)
 			}
 			_, _ = h.Finance.CreateWithTx(ctx, tx, user.ID, repository.CreateFinanceInput{
-				Title:           "Refund " + code,
-				Amount:          t.Amount.Amount,
+				Title:           "Refund " + t.Code,
+				Amount:          refund.Amount.Amount,
 				Category:        "Refund",
-				Date:            time.Now(),
+				Date:            time.Now().In(loc),
 				Type:            domain.FinanceExpense,
 				Note:            req.Note,
 				TransactionID:   &t.ID,
-				TransactionCode: &code,
+				TransactionCode: &t.Code,
+				Currency:        t.Amount.Currency,
 			})
 			if h.Membership == nil {
 				return nil
 			}
-			_, err := h.Membership.RefundWithTx(ctx, tx, membershipOwnerID, units)
+			_, err := h.Membership.RefundWithTx(ctx, tx, membershipOwnerID, refund.Units)
 			return err
 		},
 	)
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
+		var invalid *repository.RefundInvalidError
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
 			writeError(w, http.StatusNotFound, "transaction not found")
-			return
+		case errors.Is(err, repository.ErrNothingToRefund):
+			writeError(w, http.StatusConflict, "transaction is already fully refunded")
+		case errors.As(err, &invalid):
+			writeError(w, http.StatusBadRequest, invalid.Message)
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
 		}
-		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	resp := toRefundResponse(*refund)
+	resp["ok"] = true
+	resp["status"] = string(status)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toRefundLines(refunds []domain.Refund) []map[string]any {
+	out := make([]map[string]any, 0, len(refunds))
+	for _, rf := range refunds {
+		out = append(out, toRefundResponse(rf))
+	}
+	return out
+}
+
+func toRefundResponse(rf domain.Refund) map[string]any {
+	items := make([]map[string]any, 0, len(rf.Items))
+	for _, it := range rf.Items {
+		items = append(items, map[string]any{
+			"itemId":    it.TransactionItemID,
+			"productId": it.ProductID,
+			"qty":       it.Qty,
+		})
+	}
+	return map[string]any{
+		"id":         rf.ID,
+		"amount":     rf.Amount.Amount,
+		"units":      rf.Units,
+		"note":       rf.Note,
+		"refundedBy": rf.RefundedBy,
+		"reversedAt": rf.ReversedAt,
+		"createdAt":  rf.CreatedAt,
+		"items":      items,
+	}
 }
 
 func (h TransactionHandler) markPaid(w http.ResponseWriter, r *http.Request) {
@@ -405,18 +974,40 @@ func (h TransactionHandler) markPaid(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback(r.Context())
 
-	transactionID, err := h.Repo.MarkPaidByCodeWithTx(r.Context(), tx, user.ID, code)
+	reversed, err := h.Repo.MarkPaidByCodeWithTx(r.Context(), tx, user.ID, code)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "transaction not found")
 			return
 		}
+		if errors.Is(err, repository.ErrTransactionVoided) {
+			writeError(w, http.StatusConflict, "voided transactions cannot be marked paid")
+			return
+		}
+		if errors.Is(err, repository.ErrTransactionFailed) {
+			writeError(w, http.StatusConflict, "transactions whose payment failed cannot be marked paid")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// The sale stands again, so it takes back what its refunds returned.
+	for _, it := range reversed.Items {
+		if it.ProductID == nil || it.Qty <= 0 {
+			continue
+		}
+		_ = h.Stocks.AdjustByProductIDWithTx(r.Context(), tx, user.ID, *it.ProductID, -it.Qty, "sale", "refund undone "+code)
+	}
+	if h.Membership != nil {
+		if _, err := h.Membership.ConsumeWithTx(r.Context(), tx, user.ID, reversed.Units); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	// Best-effort: remove refund finance entry when undoing refund.
-	_ = h.Finance.DeleteRefundByTransactionIDWithTx(r.Context(), tx, user.ID, transactionID)
+	_ = h.Finance.DeleteRefundByTransactionIDWithTx(r.Context(), tx, user.ID, reversed.TransactionID)
 
 	if err := tx.Commit(r.Context()); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/server/authctx"
+)
+
+const (
+	maxBatchOrders = 100
+	// batchClockSkew is how far ahead of the server a device clock may run before its orders are refused.
+	batchClockSkew = 5 * time.Minute
+)
+
+// batchOrder is one order from the device's offline queue, stamped with the time it was rung up.
+type batchOrder struct {
+	orderPayload
+	TransactedAt string `json:"transactedAt"`
+}
+
+// createOrderBatch replays orders queued while the POS was offline. Each order is recorded in its own
+// database transaction through placeOrder, so one rejected order does not hold back the rest, and
+// clientRef makes a replay of an already-synced order a no-op reported as a duplicate.
+func (h TransactionHandler) createOrderBatch(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Orders []batchOrder `json:"orders"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	if len(req.Orders) == 0 {
+		writeError(w, http.StatusBadRequest, "orders is required")
+		return
+	}
+	if len(req.Orders) > maxBatchOrders {
+		writeError(w, http.StatusBadRequest, "too many orders in one batch (max "+strconv.Itoa(maxBatchOrders)+")")
+		return
+	}
+
+	results := make([]map[string]any, 0, len(req.Orders))
+	counts := map[string]int{"created": 0, "duplicate": 0, "rejected": 0}
+	for i, o := range req.Orders {
+		o.byManager = isManager(*user)
+		res := h.placeBatchOrder(r.Context(), ownerID, o)
+		res["index"] = i
+		res["clientRef"] = o.ClientRef
+		counts[res["status"].(string)]++
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"results":   results,
+		"created":   counts["created"],
+		"duplicate": counts["duplicate"],
+		"rejected":  counts["rejected"],
+	})
+}
+
+func (h TransactionHandler) placeBatchOrder(ctx context.Context, ownerID int64, o batchOrder) map[string]any {
+	o.ClientRef = strings.TrimSpace(o.ClientRef)
+	if o.ClientRef == "" {
+		return batchRejected("clientRef is required", false)
+	}
+	if ts := strings.TrimSpace(o.TransactedAt); ts != "" {
+		at, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return batchRejected("transactedAt must be an RFC 3339 timestamp", false)
+		}
+		if at.After(time.Now().Add(batchClockSkew)) {
+			return batchRejected("transactedAt is in the future", false)
+		}
+		o.transactedAt = at
+	}
+
+	tx, created, err := h.placeOrder(ctx, ownerID, o.orderPayload, nil)
+	if err != nil {
+		var oe *orderError
+		if errors.As(err, &oe) {
+			res := batchRejected(oe.Message, false)
+			if oe.Data != nil {
+				res["details"] = oe.Data
+			}
+			return res
+		}
+		return batchRejected(err.Error(), true)
+	}
+	status := "created"
+	if !created {
+		status = "duplicate"
+	}
+	return batchResult(status, tx.ID, tx.Code, tx.ReceiptNumber)
+}
+
+func batchResult(status string, id int64, code, receiptNumber string) map[string]any {
+	return map[string]any{
+		"status":        status,
+		"id":            strconv.FormatInt(id, 10),
+		"code":          code,
+		"receiptNumber": receiptNumber,
+	}
+}
+
+// batchRejected reports an order that was not recorded; retryable ones failed for reasons other than
+// the order itself and can be sent again unchanged.
+func batchRejected(reason string, retryable bool) map[string]any {
+	return map[string]any{
+		"status":    "rejected",
+		"reason":    reason,
+		"retryable": retryable,
+	}
+}
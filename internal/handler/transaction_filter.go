@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+)
+
+// parseTransactionFilter reads the GET /transactions query string.
+func parseTransactionFilter(r *http.Request) (repository.TransactionFilter, error) {
+	q := r.URL.Query()
+	var f repository.TransactionFilter
+
+	startDate, err := parseDateQuery(r, "startDate")
+	if err != nil {
+		return f, errors.New("invalid startDate")
+	}
+	endDate, err := parseDateQuery(r, "endDate")
+	if err != nil {
+		return f, errors.New("invalid endDate")
+	}
+	if startDate != nil && endDate != nil && startDate.After(*endDate) {
+		return f, errors.New("startDate must be before endDate")
+	}
+	f.StartDate, f.EndDate = startDate, endDate
+
+	if v := strings.TrimSpace(q.Get("stylistId")); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return f, errors.New("invalid stylistId")
+		}
+		f.StylistID = &id
+	}
+	f.Stylist = strings.TrimSpace(q.Get("stylist"))
+	f.PaymentMethod = strings.ToLower(strings.TrimSpace(q.Get("paymentMethod")))
+	f.Operator = strings.TrimSpace(q.Get("operator"))
+	f.ShiftID = strings.TrimSpace(q.Get("shiftId"))
+	f.CustomerPhone = strings.TrimSpace(q.Get("customerPhone"))
+	f.Query = strings.TrimSpace(q.Get("q"))
+
+	if v := strings.TrimSpace(q.Get("status")); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			status := domain.TransactionStatus(strings.TrimSpace(s))
+			switch status {
+			case domain.TransactionPending, domain.TransactionPaid, domain.TransactionPartiallyRefunded, domain.TransactionRefund,
+				domain.TransactionVoid, domain.TransactionFailed:
+				f.Statuses = append(f.Statuses, status)
+			default:
+				return f, errors.New("invalid status")
+			}
+		}
+	}
+
+	if f.MinAmount, err = parseAmountQuery(q.Get("minAmount")); err != nil {
+		return f, errors.New("invalid minAmount")
+	}
+	if f.MaxAmount, err = parseAmountQuery(q.Get("maxAmount")); err != nil {
+		return f, errors.New("invalid maxAmount")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MinAmount > *f.MaxAmount {
+		return f, errors.New("minAmount must not exceed maxAmount")
+	}
+
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > repository.MaxTransactionPageSize {
+			return f, errors.New("limit must be between 1 and " + strconv.Itoa(repository.MaxTransactionPageSize))
+		}
+		f.Limit = limit
+	}
+	f.Cursor = strings.TrimSpace(q.Get("cursor"))
+	return f, nil
+}
+
+func parseAmountQuery(v string) (*int64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return nil, errors.New("invalid amount")
+	}
+	return &n, nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"barberpos-backend/internal/receipt"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"github.com/go-chi/chi/v5"
+)
+
+// receipt renders a transaction's receipt from the owner's settings as html (default), pdf or txt.
+func (h TransactionHandler) receipt(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" && format != "txt" {
+		writeError(w, http.StatusBadRequest, "format must be html, pdf or txt")
+		return
+	}
+
+	t, err := h.Repo.GetByCode(r.Context(), ownerID, code)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "transaction not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	settings, err := h.Settings.Get(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	doc := receipt.Build(*t, *settings)
+
+	name := t.ReceiptNumber
+	if name == "" {
+		name = t.Code
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	switch format {
+	case "txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(receipt.Text(doc)))
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"receipt_%s.pdf\"", name))
+		_, _ = w.Write(receipt.PDF(doc))
+	default:
+		body, err := receipt.HTML(doc)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"barberpos-backend/internal/server/authctx"
+	"barberpos-backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	managerPinAttempts = 5
+	managerPinLockout  = 15 * time.Minute
+)
+
+// void cancels a same-shift sale. Staff need a manager to type the manager PIN on their device;
+// managers void directly. Nothing is paid out, so unlike a refund no finance entry is written.
+func (h TransactionHandler) void(w http.ResponseWriter, r *http.Request) {
+	user := authctx.FromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ownerID, err := resolveOwnerID(r.Context(), *user, h.Employees)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+	var req struct {
+		Reason     string `json:"reason"`
+		Note       string `json:"note"`
+		ManagerPin string `json:"managerPin"`
+		ShiftID    string `json:"shiftId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid payload")
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		writeError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	settings, err := h.Settings.Get(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !containsReason(settings.VoidReasons, reason) {
+		writeErrorData(w, http.StatusBadRequest, "unknown void reason", map[string]any{
+			"reasons": settings.VoidReasons,
+		})
+		return
+	}
+
+	approvedBy := &user.ID
+	if user.Role == domain.RoleStaff {
+		if req.ManagerPin == "" {
+			writeError(w, http.StatusForbidden, "manager approval required")
+			return
+		}
+		hash, err := h.Settings.ManagerPinHash(r.Context(), ownerID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if hash == nil {
+			writeError(w, http.StatusForbidden, "manager PIN is not set; ask a manager to void this transaction")
+			return
+		}
+		if !h.checkManagerPin(w, r, ownerID, *user, *hash, req.ManagerPin, code) {
+			return
+		}
+		approvedBy = &ownerID
+	}
+
+	t, err := h.Repo.VoidByCode(
+		r.Context(),
+		ownerID,
+		repository.VoidTransactionParams{
+			Code:       code,
+			Reason:     reason,
+			Note:       strings.TrimSpace(req.Note),
+			VoidedBy:   &user.ID,
+			ApprovedBy: approvedBy,
+			ShiftID:    strings.TrimSpace(req.ShiftID),
+		},
+		func(ctx context.Context, tx pgx.Tx, t domain.Transaction) error {
+			return service.SaleReleaser{Stocks: h.Stocks, Promotions: h.Promotions, Membership: h.Membership}.ReleaseWithTx(ctx, tx, ownerID, t, "void")
+		},
+	)
+	if err != nil {
+		var rejected *repository.VoidRejectedError
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "transaction not found")
+		case errors.As(err, &rejected):
+			writeError(w, http.StatusConflict, rejected.Message)
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":             true,
+		"code":           t.Code,
+		"status":         string(t.Status),
+		"voidReason":     t.VoidReason,
+		"voidNote":       t.VoidNote,
+		"voidedAt":       t.VoidedAt,
+		"voidedBy":       t.VoidedBy,
+		"voidApprovedBy": t.VoidApprovedBy,
+	})
+}
+
+// checkManagerPin verifies a manager PIN typed on a staff device, answering the request itself when the PIN
+// is wrong or PIN entry is locked. After managerPinAttempts wrong PINs in a row, PIN entry is locked for
+// managerPinLockout: a 4-digit PIN would otherwise fall to a script in minutes. Wrong PINs go to the
+// activity log so the owner sees who was guessing.
+func (h TransactionHandler) checkManagerPin(w http.ResponseWriter, r *http.Request, ownerID int64, user authctx.CurrentUser, hash, pin, code string) bool {
+	attempt, lockedUntil, err := h.Settings.ClaimManagerPinAttempt(r.Context(), ownerID, managerPinAttempts, managerPinLockout)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if attempt > managerPinAttempts && lockedUntil != nil {
+		writeManagerPinLocked(w, *lockedUntil)
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pin)) == nil {
+		if err := h.Settings.ClearManagerPinAttempts(r.Context(), ownerID); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		return true
+	}
+	logType, message := domain.LogWarning, fmt.Sprintf("Void of %s refused: wrong manager PIN (attempt %d of %d).", code, attempt, managerPinAttempts)
+	if lockedUntil != nil {
+		logType = domain.LogError
+		message += fmt.Sprintf(" Manager PIN entry is locked until %s.", lockedUntil.Format(time.RFC3339))
+	}
+	// Best-effort: a failed log write must not turn a wrong PIN into a server error.
+	_, _ = h.ActivityLogs.Create(r.Context(), ownerID, repository.CreateActivityLogInput{
+		Title:     "Wrong manager PIN",
+		Message:   message,
+		Actor:     user.Email,
+		Type:      logType,
+		Timestamp: time.Now(),
+	})
+	if lockedUntil != nil {
+		writeManagerPinLocked(w, *lockedUntil)
+		return false
+	}
+	writeErrorData(w, http.StatusForbidden, "invalid manager PIN", map[string]any{
+		"attemptsLeft": managerPinAttempts - attempt,
+	})
+	return false
+}
+
+func writeManagerPinLocked(w http.ResponseWriter, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+	writeErrorData(w, http.StatusTooManyRequests, "too many wrong manager PINs; try again later", map[string]any{
+		"lockedUntil": until,
+	})
+}
+
+func containsReason(reasons []string, reason string) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
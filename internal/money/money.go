@@ -0,0 +1,158 @@
+// Package money handles amounts in the currency they were recorded in. Amounts are whole minor units
+// (rupiah for IDR, sen for MYR), so arithmetic stays exact; only formatting deals with decimals.
+package money
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+)
+
+// DefaultCode is the currency of owners and records that never set one.
+const DefaultCode = "IDR"
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+)
+
+// Currency describes how one ISO 4217 currency is stored and shown.
+type Currency struct {
+	Code       string
+	MinorUnits int    // digits after the decimal point; amounts are stored in these units
+	Symbol     string // printed before the amount
+	Thousands  string
+	Decimal    string
+	// CashUnits are the steps cash can be rounded to, in minor units; the first is the default.
+	CashUnits []int64
+}
+
+var currencies = map[string]Currency{
+	"IDR": {Code: "IDR", MinorUnits: 0, Symbol: "Rp", Thousands: ".", Decimal: ",", CashUnits: []int64{100, 500, 1000}},
+	"MYR": {Code: "MYR", MinorUnits: 2, Symbol: "RM", Thousands: ",", Decimal: ".", CashUnits: []int64{5, 10, 50, 100}},
+	"SGD": {Code: "SGD", MinorUnits: 2, Symbol: "S$", Thousands: ",", Decimal: ".", CashUnits: []int64{5, 10, 50, 100}},
+	"USD": {Code: "USD", MinorUnits: 2, Symbol: "$", Thousands: ",", Decimal: ".", CashUnits: []int64{1, 5, 10, 25, 100}},
+}
+
+// Normalize returns code in the upper-case form currencies are stored in; empty means DefaultCode.
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCode
+	}
+	return code
+}
+
+// Lookup returns a supported currency by code, case-insensitively.
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[Normalize(code)]
+	if !ok {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return c, nil
+}
+
+// Codes lists the supported currency codes, sorted.
+func Codes() []string {
+	out := make([]string, 0, len(currencies))
+	for code := range currencies {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// New returns amount minor units of the given currency.
+func New(amount int64, code string) domain.Money {
+	return domain.Money{Amount: amount, Currency: Normalize(code)}
+}
+
+// Add returns a+b. A zero amount without a currency adds to anything; otherwise both must share one.
+func Add(a, b domain.Money) (domain.Money, error) {
+	code, err := common(a, b)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return domain.Money{Amount: a.Amount + b.Amount, Currency: code}, nil
+}
+
+// Sub returns a-b under the same rules as Add.
+func Sub(a, b domain.Money) (domain.Money, error) {
+	code, err := common(a, b)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return domain.Money{Amount: a.Amount - b.Amount, Currency: code}, nil
+}
+
+// Sum adds up amounts that must all be in one currency.
+func Sum(amounts ...domain.Money) (domain.Money, error) {
+	var total domain.Money
+	for _, m := range amounts {
+		var err error
+		if total, err = Add(total, m); err != nil {
+			return domain.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func common(a, b domain.Money) (string, error) {
+	switch {
+	case a.Currency == "" && a.Amount == 0:
+		return b.Currency, nil
+	case b.Currency == "" && b.Amount == 0:
+		return a.Currency, nil
+	case Normalize(a.Currency) != Normalize(b.Currency):
+		return "", ErrCurrencyMismatch
+	}
+	return Normalize(a.Currency), nil
+}
+
+// Format writes m the way the currency is written locally: "Rp 25.000", "RM 1,250.50".
+func Format(m domain.Money) string {
+	return FormatAmount(m.Amount, m.Currency)
+}
+
+// FormatAmount formats minor units of code. Unknown codes are printed as whole units after the code.
+func FormatAmount(amount int64, code string) string {
+	c, err := Lookup(code)
+	if err != nil {
+		c = Currency{Code: Normalize(code), Symbol: Normalize(code), Thousands: ",", Decimal: "."}
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	major, minor := split(amount, c.MinorUnits)
+	out := sign + c.Symbol + " " + group(major, c.Thousands)
+	if c.MinorUnits > 0 {
+		frac := strconv.FormatInt(minor, 10)
+		out += c.Decimal + strings.Repeat("0", c.MinorUnits-len(frac)) + frac
+	}
+	return out
+}
+
+func split(amount int64, minorUnits int) (int64, int64) {
+	scale := int64(1)
+	for i := 0; i < minorUnits; i++ {
+		scale *= 10
+	}
+	return amount / scale, amount % scale
+}
+
+func group(v int64, sep string) string {
+	digits := strconv.FormatInt(v, 10)
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
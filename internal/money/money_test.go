@@ -0,0 +1,93 @@
+package money
+
+import (
+	"errors"
+	"testing"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount int64
+		code   string
+		want   string
+	}{
+		{0, "IDR", "Rp 0"},
+		{25000, "IDR", "Rp 25.000"},
+		{1250000, "idr", "Rp 1.250.000"},
+		{-5000, "IDR", "-Rp 5.000"},
+		{125050, "MYR", "RM 1,250.50"},
+		{5, "MYR", "RM 0.05"},
+		{100, "SGD", "S$ 1.00"},
+		{123456789, "USD", "$ 1,234,567.89"},
+		{999, "", "Rp 999"},
+		{1500, "JPY", "JPY 1,500"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(tt.amount, tt.code); got != tt.want {
+			t.Errorf("FormatAmount(%d, %q) = %q, want %q", tt.amount, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr error
+	}{
+		{"IDR", "IDR", nil},
+		{" myr ", "MYR", nil},
+		{"", DefaultCode, nil},
+		{"EUR", "", ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		c, err := Lookup(tt.code)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Lookup(%q) err = %v, want %v", tt.code, err, tt.wantErr)
+			continue
+		}
+		if c.Code != tt.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.code, c.Code, tt.want)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    domain.Money
+		sum     domain.Money
+		diff    domain.Money
+		wantErr error
+	}{
+		{"same currency", New(500, "IDR"), New(200, "idr"), New(700, "IDR"), New(300, "IDR"), nil},
+		{"zero without currency", domain.Money{}, New(200, "MYR"), New(200, "MYR"), New(-200, "MYR"), nil},
+		{"zero on the right", New(200, "MYR"), domain.Money{}, New(200, "MYR"), New(200, "MYR"), nil},
+		{"mismatch", New(500, "IDR"), New(200, "MYR"), domain.Money{}, domain.Money{}, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		sum, err := Add(tt.a, tt.b)
+		if !errors.Is(err, tt.wantErr) || sum != tt.sum {
+			t.Errorf("%s: Add = %v, %v; want %v, %v", tt.name, sum, err, tt.sum, tt.wantErr)
+		}
+		diff, err := Sub(tt.a, tt.b)
+		if !errors.Is(err, tt.wantErr) || diff != tt.diff {
+			t.Errorf("%s: Sub = %v, %v; want %v, %v", tt.name, diff, err, tt.diff, tt.wantErr)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	got, err := Sum(New(100, "MYR"), New(250, "MYR"), domain.Money{}, New(5, "myr"))
+	if err != nil || got != New(355, "MYR") {
+		t.Errorf("Sum = %v, %v; want MYR 355", got, err)
+	}
+	if _, err := Sum(New(100, "MYR"), New(100, "IDR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum of mixed currencies err = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if got, err := Sum(); err != nil || got != (domain.Money{}) {
+		t.Errorf("Sum() = %v, %v; want zero", got, err)
+	}
+}
@@ -0,0 +1,206 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/ports"
+)
+
+// ErrFakePaymentNotFound is returned for a ref the fake provider did not issue.
+var ErrFakePaymentNotFound = errors.New("payment not found")
+
+// Fake is a deterministic provider for development and tests; nothing leaves the process. Like gateway test
+// cards, the outcome follows the last two digits of the amount in minor units: 01 fails, 02 stays pending
+// until the intent expires, and anything else is paid the first time its status is checked.
+//
+// Webhooks are JSON bodies signed with HMAC-SHA256 of the body under WebhookSecret, hex-encoded in the
+// X-Signature header; Sign produces the header for local testing.
+type Fake struct {
+	WebhookSecret string
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+	now      func() time.Time
+}
+
+type fakePayment struct {
+	req   ports.PaymentRequest
+	state ports.PaymentState
+}
+
+func NewFake() *Fake {
+	return &Fake{payments: make(map[string]*fakePayment), now: time.Now}
+}
+
+func newFake(cfg Config) (ports.PaymentProvider, error) {
+	f := NewFake()
+	f.WebhookSecret = cfg.WebhookSecret
+	return f, nil
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) CreateIntent(_ context.Context, req ports.PaymentRequest) (ports.PaymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The amount is part of the ref so a restarted process still knows how the payment ends.
+	ref := "fake_" + req.IntentID + "_" + strconv.FormatInt(req.Amount, 10)
+	if p, ok := f.payments[ref]; ok {
+		return p.state, nil
+	}
+	state := ports.PaymentState{ProviderRef: ref, Status: domain.PaymentIntentPending}
+	switch req.Method {
+	case "qris":
+		state.QRString = fmt.Sprintf("FAKEQRIS|%s|%d|%s", req.IntentID, req.Amount, req.Currency)
+	case "card":
+		state.Reference = "FAKE-" + strings.ToUpper(strings.TrimPrefix(req.IntentID, "pi_"))
+	default:
+		return ports.PaymentState{}, ports.ErrPaymentMethodUnsupported
+	}
+	f.payments[ref] = &fakePayment{req: req, state: state}
+	return state, nil
+}
+
+func (f *Fake) GetStatus(_ context.Context, ref string) (ports.PaymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.lookup(ref)
+	if err != nil {
+		return ports.PaymentState{}, err
+	}
+	if p.state.Status != domain.PaymentIntentPending {
+		return p.state, nil
+	}
+	now := f.now()
+	switch p.req.Amount % 100 {
+	case 1:
+		p.state.Status = domain.PaymentIntentFailed
+	case 2:
+		if !p.req.ExpiresAt.IsZero() && now.After(p.req.ExpiresAt) {
+			p.state.Status = domain.PaymentIntentExpired
+		}
+	default:
+		p.state.Status = domain.PaymentIntentPaid
+		p.state.PaidAt = &now
+	}
+	return p.state, nil
+}
+
+func (f *Fake) Cancel(_ context.Context, ref string) (ports.PaymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.lookup(ref)
+	if err != nil {
+		return ports.PaymentState{}, err
+	}
+	if p.state.Status == domain.PaymentIntentPending {
+		p.state.Status = domain.PaymentIntentCancelled
+	}
+	return p.state, nil
+}
+
+func (f *Fake) Refund(_ context.Context, ref string, amount int64) (ports.PaymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.lookup(ref)
+	if err != nil {
+		return ports.PaymentState{}, err
+	}
+	if p.state.Status != domain.PaymentIntentPaid {
+		return p.state, nil
+	}
+	p.state.RefundedAmount += amount
+	if p.state.RefundedAmount >= p.req.Amount {
+		p.state.Status = domain.PaymentIntentRefunded
+	}
+	return p.state, nil
+}
+
+// lookup finds a payment, rebuilding it from the ref when it was created before a restart.
+func (f *Fake) lookup(ref string) (*fakePayment, error) {
+	if p, ok := f.payments[ref]; ok {
+		return p, nil
+	}
+	rest, ok := strings.CutPrefix(ref, "fake_")
+	if !ok {
+		return nil, ErrFakePaymentNotFound
+	}
+	i := strings.LastIndex(rest, "_")
+	if i < 0 {
+		return nil, ErrFakePaymentNotFound
+	}
+	amount, err := strconv.ParseInt(rest[i+1:], 10, 64)
+	if err != nil {
+		return nil, ErrFakePaymentNotFound
+	}
+	p := &fakePayment{
+		req:   ports.PaymentRequest{IntentID: rest[:i], Amount: amount},
+		state: ports.PaymentState{ProviderRef: ref, Status: domain.PaymentIntentPending},
+	}
+	f.payments[ref] = p
+	return p, nil
+}
+
+// fakeWebhook is the body of a fake webhook delivery.
+type fakeWebhook struct {
+	EventID        string     `json:"eventId"`
+	IntentID       string     `json:"intentId"`
+	ProviderRef    string     `json:"providerRef"`
+	Status         string     `json:"status"`
+	RefundedAmount int64      `json:"refundedAmount"`
+	PaidAt         *time.Time `json:"paidAt"`
+}
+
+// Sign returns the X-Signature value for body.
+func (f *Fake) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) ParseWebhook(header http.Header, body []byte) (ports.PaymentEvent, error) {
+	got, err := hex.DecodeString(header.Get("X-Signature"))
+	if f.WebhookSecret == "" || err != nil {
+		return ports.PaymentEvent{}, ports.ErrInvalidWebhookSignature
+	}
+	want, _ := hex.DecodeString(f.Sign(body))
+	if !hmac.Equal(got, want) {
+		return ports.PaymentEvent{}, ports.ErrInvalidWebhookSignature
+	}
+	var in fakeWebhook
+	if err := json.Unmarshal(body, &in); err != nil {
+		return ports.PaymentEvent{}, fmt.Errorf("%w: %v", ports.ErrWebhookMalformed, err)
+	}
+	if in.EventID == "" {
+		return ports.PaymentEvent{}, fmt.Errorf("%w: eventId is required", ports.ErrWebhookMalformed)
+	}
+	status := domain.PaymentIntentStatus(in.Status)
+	switch status {
+	case domain.PaymentIntentPending, domain.PaymentIntentPaid, domain.PaymentIntentFailed,
+		domain.PaymentIntentCancelled, domain.PaymentIntentExpired, domain.PaymentIntentRefunded:
+	default:
+		return ports.PaymentEvent{}, fmt.Errorf("%w: unknown status %q", ports.ErrWebhookMalformed, in.Status)
+	}
+	return ports.PaymentEvent{
+		EventID:  in.EventID,
+		IntentID: in.IntentID,
+		State: ports.PaymentState{
+			ProviderRef:    in.ProviderRef,
+			Status:         status,
+			RefundedAmount: in.RefundedAmount,
+			PaidAt:         in.PaidAt,
+		},
+	}, nil
+}
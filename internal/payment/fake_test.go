@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/ports"
+)
+
+func TestFakeParseWebhook(t *testing.T) {
+	f := NewFake()
+	f.WebhookSecret = "whsec_test"
+	other := NewFake()
+	other.WebhookSecret = "whsec_other"
+
+	paid := []byte(`{"eventId":"ev_1","intentId":"pi_1","providerRef":"fake_pi_1_25000","status":"paid"}`)
+	signed := func(sig string) http.Header {
+		h := http.Header{}
+		if sig != "" {
+			h.Set("X-Signature", sig)
+		}
+		return h
+	}
+	tests := []struct {
+		name    string
+		secret  string
+		header  http.Header
+		body    []byte
+		wantErr error
+	}{
+		{"valid", f.WebhookSecret, signed(f.Sign(paid)), paid, nil},
+		{"upper-case hex", f.WebhookSecret, signed(strings.ToUpper(f.Sign(paid))), paid, nil},
+		{"no signature", f.WebhookSecret, signed(""), paid, ports.ErrInvalidWebhookSignature},
+		{"not hex", f.WebhookSecret, signed("not-a-signature"), paid, ports.ErrInvalidWebhookSignature},
+		{"other secret", f.WebhookSecret, signed(other.Sign(paid)), paid, ports.ErrInvalidWebhookSignature},
+		{"tampered body", f.WebhookSecret, signed(f.Sign(paid)), []byte(`{"eventId":"ev_1","intentId":"pi_1","providerRef":"fake_pi_1_25000","status":"failed"}`), ports.ErrInvalidWebhookSignature},
+		{"no secret configured", "", signed(NewFake().Sign(paid)), paid, ports.ErrInvalidWebhookSignature},
+		{"not json", f.WebhookSecret, signed(f.Sign([]byte("paid"))), []byte("paid"), ports.ErrWebhookMalformed},
+		{"no event id", f.WebhookSecret, signed(f.Sign([]byte(`{"status":"paid"}`))), []byte(`{"status":"paid"}`), ports.ErrWebhookMalformed},
+		{"unknown status", f.WebhookSecret, signed(f.Sign([]byte(`{"eventId":"ev_2","status":"settled"}`))), []byte(`{"eventId":"ev_2","status":"settled"}`), ports.ErrWebhookMalformed},
+	}
+	for _, tt := range tests {
+		p := NewFake()
+		p.WebhookSecret = tt.secret
+		ev, err := p.ParseWebhook(tt.header, tt.body)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ParseWebhook err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if ev.EventID != "ev_1" || ev.IntentID != "pi_1" || ev.State.ProviderRef != "fake_pi_1_25000" || ev.State.Status != domain.PaymentIntentPaid {
+			t.Errorf("%s: ParseWebhook = %+v", tt.name, ev)
+		}
+	}
+}
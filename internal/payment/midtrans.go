@@ -0,0 +1,168 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/ports"
+)
+
+const midtransSandboxURL = "https://api.sandbox.midtrans.com"
+
+// Midtrans collects QRIS through the Midtrans Core API. Card tenders go through the shop's EDC terminal,
+// so they are not supported here. Midtrans settles in rupiah only.
+type Midtrans struct {
+	ServerKey string
+	BaseURL   string
+	Client    *http.Client
+}
+
+func newMidtrans(cfg Config) (ports.PaymentProvider, error) {
+	if cfg.ServerKey == "" {
+		return nil, errors.New("midtrans: PAYMENT_SERVER_KEY is required")
+	}
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = midtransSandboxURL
+	}
+	return &Midtrans{ServerKey: cfg.ServerKey, BaseURL: base, Client: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+func (m *Midtrans) Name() string { return "midtrans" }
+
+// midtransResponse is the part of a Core API transaction response the adapter reads.
+type midtransResponse struct {
+	StatusCode        string `json:"status_code"`
+	StatusMessage     string `json:"status_message"`
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	QRString          string `json:"qr_string"`
+	RefundAmount      string `json:"refund_amount"`
+	SettlementTime    string `json:"settlement_time"`
+	OrderID           string `json:"order_id"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+}
+
+func (m *Midtrans) CreateIntent(ctx context.Context, req ports.PaymentRequest) (ports.PaymentState, error) {
+	if req.Method != "qris" || !strings.EqualFold(req.Currency, "IDR") {
+		return ports.PaymentState{}, ports.ErrPaymentMethodUnsupported
+	}
+	body := map[string]any{
+		"payment_type": "qris",
+		"transaction_details": map[string]any{
+			"order_id":     req.IntentID,
+			"gross_amount": req.Amount,
+		},
+	}
+	if !req.ExpiresAt.IsZero() {
+		minutes := int(math.Ceil(time.Until(req.ExpiresAt).Minutes()))
+		body["custom_expiry"] = map[string]any{"expiry_duration": max(minutes, 1), "unit": "minute"}
+	}
+	return m.call(ctx, http.MethodPost, "/v2/charge", body)
+}
+
+func (m *Midtrans) GetStatus(ctx context.Context, ref string) (ports.PaymentState, error) {
+	return m.call(ctx, http.MethodGet, "/v2/"+ref+"/status", nil)
+}
+
+func (m *Midtrans) Cancel(ctx context.Context, ref string) (ports.PaymentState, error) {
+	return m.call(ctx, http.MethodPost, "/v2/"+ref+"/cancel", nil)
+}
+
+func (m *Midtrans) Refund(ctx context.Context, ref string, amount int64) (ports.PaymentState, error) {
+	return m.call(ctx, http.MethodPost, "/v2/"+ref+"/refund", map[string]any{
+		"refund_key": fmt.Sprintf("%s-%d-%d", ref, amount, time.Now().Unix()),
+		"amount":     amount,
+	})
+}
+
+func (m *Midtrans) call(ctx context.Context, method, path string, body any) (ports.PaymentState, error) {
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return ports.PaymentState{}, err
+		}
+		payload = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.BaseURL+path, payload)
+	if err != nil {
+		return ports.PaymentState{}, err
+	}
+	req.SetBasicAuth(m.ServerKey, "")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return ports.PaymentState{}, err
+	}
+	defer resp.Body.Close()
+	var out midtransResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return ports.PaymentState{}, fmt.Errorf("midtrans: %s: %w", resp.Status, err)
+	}
+	// Errors come back as HTTP 200 with the real status in the body.
+	if code, _ := strconv.Atoi(out.StatusCode); resp.StatusCode >= 300 || code >= 300 {
+		return ports.PaymentState{}, fmt.Errorf("midtrans: %s %s", out.StatusCode, out.StatusMessage)
+	}
+	return out.state(), nil
+}
+
+func (r midtransResponse) state() ports.PaymentState {
+	s := ports.PaymentState{ProviderRef: r.TransactionID, QRString: r.QRString, Reference: r.TransactionID}
+	switch r.TransactionStatus {
+	case "settlement", "capture", "partial_refund":
+		s.Status = domain.PaymentIntentPaid
+	case "refund":
+		s.Status = domain.PaymentIntentRefunded
+	case "deny", "failure":
+		s.Status = domain.PaymentIntentFailed
+	case "cancel":
+		s.Status = domain.PaymentIntentCancelled
+	case "expire":
+		s.Status = domain.PaymentIntentExpired
+	default:
+		s.Status = domain.PaymentIntentPending
+	}
+	if v, err := strconv.ParseFloat(r.RefundAmount, 64); err == nil {
+		s.RefundedAmount = int64(math.Round(v))
+	}
+	// Settlement times are Jakarta wall-clock time.
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", r.SettlementTime, time.FixedZone("WIB", 7*3600)); err == nil {
+		s.PaidAt = &t
+	}
+	return s
+}
+
+// ParseWebhook reads an HTTP notification. Midtrans signs it in the body: signature_key is the SHA-512 of
+// order_id, status_code, gross_amount and the server key. Notifications carry no event id, so a transaction
+// and status pair identifies one.
+func (m *Midtrans) ParseWebhook(_ http.Header, body []byte) (ports.PaymentEvent, error) {
+	var n midtransResponse
+	if err := json.Unmarshal(body, &n); err != nil {
+		return ports.PaymentEvent{}, fmt.Errorf("%w: %v", ports.ErrWebhookMalformed, err)
+	}
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + m.ServerKey))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(n.SignatureKey))) != 1 {
+		return ports.PaymentEvent{}, ports.ErrInvalidWebhookSignature
+	}
+	return ports.PaymentEvent{
+		EventID:  n.TransactionID + ":" + n.TransactionStatus + ":" + n.StatusCode,
+		IntentID: n.OrderID,
+		State:    n.state(),
+	}, nil
+}
@@ -0,0 +1,59 @@
+// Package payment holds the payment provider adapters and picks one from configuration. Each adapter
+// implements ports.PaymentProvider; new gateways register a Factory under the name PAYMENT_PROVIDER selects.
+package payment
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"barberpos-backend/internal/ports"
+)
+
+// ErrUnknownProvider is returned by New for a provider name nothing registered.
+var ErrUnknownProvider = errors.New("unknown payment provider")
+
+// Config is the provider part of the application config.
+type Config struct {
+	Provider  string
+	ServerKey string
+	BaseURL   string // empty means the adapter's sandbox endpoint
+	// WebhookSecret signs webhooks for providers that do not sign with ServerKey.
+	WebhookSecret string
+}
+
+// Factory builds a provider from config; it should fail when required credentials are missing.
+type Factory func(Config) (ports.PaymentProvider, error)
+
+var factories = map[string]Factory{
+	"fake":     newFake,
+	"midtrans": newMidtrans,
+}
+
+// Register makes an adapter available under name. It is meant to be called from init.
+func Register(name string, f Factory) {
+	factories[strings.ToLower(name)] = f
+}
+
+// Names lists the registered providers, sorted.
+func Names() []string {
+	out := make([]string, 0, len(factories))
+	for name := range factories {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// New builds the provider cfg.Provider names; empty means the fake provider.
+func New(cfg Config) (ports.PaymentProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if name == "" {
+		name = "fake"
+	}
+	f, ok := factories[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return f(cfg)
+}
@@ -0,0 +1,62 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+var (
+	// ErrPaymentMethodUnsupported is returned by a provider that cannot collect the requested method.
+	ErrPaymentMethodUnsupported = errors.New("payment method not supported by provider")
+	// ErrInvalidWebhookSignature is returned for webhook deliveries that were not signed by the provider.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	// ErrWebhookMalformed is returned for signed webhook deliveries the adapter cannot read.
+	ErrWebhookMalformed = errors.New("malformed webhook")
+)
+
+// PaymentProvider is a payment gateway that collects non-cash tenders. Amounts are minor units of the
+// request's currency; refs are the provider's own ids, as returned in PaymentState.ProviderRef.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req PaymentRequest) (PaymentState, error)
+	GetStatus(ctx context.Context, ref string) (PaymentState, error)
+	Cancel(ctx context.Context, ref string) (PaymentState, error)
+	Refund(ctx context.Context, ref string, amount int64) (PaymentState, error)
+}
+
+// PaymentRequest asks a provider to collect one tender. IntentID is ours and doubles as the idempotency key.
+type PaymentRequest struct {
+	IntentID  string
+	Method    string // qris or card
+	Amount    int64
+	Currency  string
+	ExpiresAt time.Time
+}
+
+// PaymentState is what a provider reports about one payment.
+type PaymentState struct {
+	ProviderRef    string
+	Status         domain.PaymentIntentStatus
+	QRString       string // QRIS payload to render, for qris
+	Reference      string // shown to the customer or printed by the terminal
+	RefundedAmount int64
+	PaidAt         *time.Time
+}
+
+// PaymentWebhookParser is implemented by providers that push payment updates. ParseWebhook checks the
+// delivery's signature before trusting anything in it.
+type PaymentWebhookParser interface {
+	ParseWebhook(header http.Header, body []byte) (PaymentEvent, error)
+}
+
+// PaymentEvent is one authentic webhook delivery. EventID is unique per provider and repeats when the
+// provider redelivers; IntentID is ours when the provider echoes it back.
+type PaymentEvent struct {
+	EventID  string
+	IntentID string
+	State    PaymentState
+}
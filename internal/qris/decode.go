@@ -0,0 +1,37 @@
+package qris
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg" // register decoders for uploaded photos and screenshots
+	_ "image/png"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var (
+	ErrUnreadableImage = errors.New("image could not be read")
+	ErrNoQRCode        = errors.New("no QR code found in the image")
+)
+
+// Decode finds the QR code in a PNG or JPEG and returns its text. It does not check that the text is QRIS;
+// pass it to Parse for that.
+func Decode(img []byte) (string, error) {
+	src, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return "", ErrUnreadableImage
+	}
+	bmp, err := gozxing.NewBinaryBitmapFromImage(src)
+	if err != nil {
+		return "", ErrUnreadableImage
+	}
+	// Photos of printed stands are often skewed or small in frame; let the reader work harder at them.
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	res, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		return "", ErrNoQRCode
+	}
+	return res.GetText(), nil
+}
@@ -0,0 +1,219 @@
+// Package qris reads and writes QRIS payloads, Indonesia's profile of the EMVCo merchant-presented QR
+// code. A payload is a flat list of ID-length-value fields; some fields are templates holding their own
+// fields, and the last one (63) is a CRC16 over everything before its value.
+package qris
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Field IDs used here.
+const (
+	IDPayloadFormat   = "00"
+	IDInitiation      = "01"
+	IDCurrency        = "53"
+	IDAmount          = "54"
+	IDCountry         = "58"
+	IDMerchantName    = "59"
+	IDMerchantCity    = "60"
+	IDPostalCode      = "61"
+	IDCRC             = "63"
+	initiationDynamic = "12"
+	currencyIDR       = "360"
+	// qrisGUI names the national QRIS template, whose field 02 is the merchant's NMID.
+	qrisGUI = "ID.CO.QRIS.WWW"
+)
+
+var (
+	ErrInvalidPayload = errors.New("invalid QRIS payload")
+	ErrChecksum       = errors.New("QRIS checksum does not match")
+)
+
+// Field is one ID-length-value entry.
+type Field struct {
+	ID    string
+	Value string
+}
+
+// Payload is a parsed QRIS code, fields in the order they were read.
+type Payload struct {
+	Fields []Field
+}
+
+// Merchant is what a payload says about who gets paid.
+type Merchant struct {
+	Name       string
+	City       string
+	PostalCode string
+	NMID       string // national merchant id from the QRIS template
+	Dynamic    bool   // true when the code is for one payment
+	Amount     string // fixed amount, dynamic codes only
+}
+
+// Parse reads a QRIS payload and checks its structure, checksum and the merchant fields QRIS requires.
+func Parse(s string) (Payload, error) {
+	s = strings.TrimSpace(s)
+	fields, err := parseFields(s)
+	if err != nil {
+		return Payload{}, err
+	}
+	if len(fields) < 2 || fields[0].ID != IDPayloadFormat || fields[0].Value != "01" {
+		return Payload{}, fmt.Errorf("%w: must start with payload format 01", ErrInvalidPayload)
+	}
+	last := fields[len(fields)-1]
+	if last.ID != IDCRC || len(last.Value) != 4 {
+		return Payload{}, fmt.Errorf("%w: must end with a CRC", ErrInvalidPayload)
+	}
+	if want := CRC16(s[:len(s)-4]); !strings.EqualFold(last.Value, want) {
+		return Payload{}, ErrChecksum
+	}
+	p := Payload{Fields: fields}
+	if v, _ := p.Get(IDCurrency); v != currencyIDR {
+		return Payload{}, fmt.Errorf("%w: currency must be IDR (360)", ErrInvalidPayload)
+	}
+	m := p.Merchant()
+	switch {
+	case m.Name == "":
+		return Payload{}, fmt.Errorf("%w: merchant name is missing", ErrInvalidPayload)
+	case m.City == "":
+		return Payload{}, fmt.Errorf("%w: merchant city is missing", ErrInvalidPayload)
+	case m.NMID == "":
+		return Payload{}, fmt.Errorf("%w: NMID is missing", ErrInvalidPayload)
+	}
+	return p, nil
+}
+
+func parseFields(s string) ([]Field, error) {
+	var fields []Field
+	for i := 0; i < len(s); {
+		if i+4 > len(s) {
+			return nil, fmt.Errorf("%w: truncated field at %d", ErrInvalidPayload, i)
+		}
+		id, length := s[i:i+2], s[i+2:i+4]
+		// Atoi alone would take "-1" or "+1"; both header fields are plain digits.
+		if !isDigits(id) || !isDigits(length) {
+			return nil, fmt.Errorf("%w: bad field header at %d", ErrInvalidPayload, i)
+		}
+		n, err := strconv.Atoi(length)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad field header at %d", ErrInvalidPayload, i)
+		}
+		i += 4
+		if i+n > len(s) {
+			return nil, fmt.Errorf("%w: field %s runs past the end", ErrInvalidPayload, id)
+		}
+		fields = append(fields, Field{ID: id, Value: s[i : i+n]})
+		i += n
+	}
+	return fields, nil
+}
+
+// Get returns the value of the first field with the given id.
+func (p Payload) Get(id string) (string, bool) {
+	for _, f := range p.Fields {
+		if f.ID == id {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Merchant reads the merchant details. The NMID comes from the QRIS template (any of 26-51), falling back to
+// the first merchant account template that carries one.
+func (p Payload) Merchant() Merchant {
+	m := Merchant{}
+	m.Name, _ = p.Get(IDMerchantName)
+	m.City, _ = p.Get(IDMerchantCity)
+	m.PostalCode, _ = p.Get(IDPostalCode)
+	initiation, _ := p.Get(IDInitiation)
+	m.Dynamic = initiation == initiationDynamic
+	m.Amount, _ = p.Get(IDAmount)
+	for _, f := range p.Fields {
+		if f.ID < "26" || f.ID > "51" {
+			continue
+		}
+		sub, err := parseFields(f.Value)
+		if err != nil {
+			continue
+		}
+		tmpl := Payload{Fields: sub}
+		nmid, _ := tmpl.Get("02")
+		if gui, _ := tmpl.Get("00"); strings.EqualFold(gui, qrisGUI) && nmid != "" {
+			m.NMID = nmid
+			break
+		}
+		if m.NMID == "" && strings.HasPrefix(nmid, "ID") {
+			m.NMID = nmid
+		}
+	}
+	return m
+}
+
+// WithAmount returns the dynamic code for one payment of amount rupiah: initiation becomes 12, field 54
+// carries the amount and the CRC is recomputed. Works on static and dynamic codes alike.
+func (p Payload) WithAmount(amount int64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("%w: amount must be positive", ErrInvalidPayload)
+	}
+	value := strconv.FormatInt(amount, 10)
+	if len(value) > 13 {
+		return "", fmt.Errorf("%w: amount is too large", ErrInvalidPayload)
+	}
+	fields := make([]Field, 0, len(p.Fields)+1)
+	for _, f := range p.Fields {
+		if f.ID == IDInitiation || f.ID == IDAmount || f.ID == IDCRC {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	fields = append(fields, Field{ID: IDInitiation, Value: initiationDynamic}, Field{ID: IDAmount, Value: value})
+	// Fields are written in id order, as the specification lists them; the CRC always goes last.
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	return encode(fields), nil
+}
+
+func encode(fields []Field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s%02d%s", f.ID, len(f.Value), f.Value)
+	}
+	b.WriteString(IDCRC + "04")
+	return b.String() + CRC16(b.String())
+}
+
+// CRC16 is the CRC-16/CCITT-FALSE checksum QRIS uses, as four upper-case hex digits. data covers the whole
+// payload up to and including the "6304" header of the CRC field.
+func CRC16(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+// PNG renders payload as a QR code image size pixels square.
+func PNG(payload string, size int) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, size)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package qris
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// staticCode builds a static QRIS code whose fields can be changed before the CRC is added.
+func staticCode(edit func([]Field) []Field) string {
+	fields := []Field{
+		{IDPayloadFormat, "01"},
+		{IDInitiation, "11"},
+		{"26", encodeFields([]Field{{"00", "ID.CO.QRIS.WWW"}, {"02", "ID1020304050607"}, {"03", "UMI"}})},
+		{"52", "7230"},
+		{IDCurrency, currencyIDR},
+		{IDCountry, "ID"},
+		{IDMerchantName, "BARBER KING"},
+		{IDMerchantCity, "JAKARTA"},
+		{IDPostalCode, "12345"},
+	}
+	if edit != nil {
+		fields = edit(fields)
+	}
+	return encode(fields)
+}
+
+func encodeFields(fields []Field) string {
+	s := encode(fields)
+	return s[:len(s)-8] // drop the CRC field
+}
+
+func without(id string) func([]Field) []Field {
+	return func(fields []Field) []Field {
+		out := fields[:0]
+		for _, f := range fields {
+			if f.ID != id {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", "FFFF"},
+		{"123456789", "29B1"}, // the CRC-16/CCITT-FALSE check value
+		{"A", "B915"},
+	}
+	for _, tt := range tests {
+		if got := CRC16(tt.data); got != tt.want {
+			t.Errorf("CRC16(%q) = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := staticCode(nil)
+	tests := []struct {
+		name    string
+		in      string
+		wantErr error
+	}{
+		{"valid", valid, nil},
+		{"surrounding space", "  " + valid + "\n", nil},
+		{"lower-case crc", valid[:len(valid)-4] + strings.ToLower(valid[len(valid)-4:]), nil},
+		{"bad checksum", valid[:len(valid)-4] + "0000", ErrChecksum},
+		{"tampered", strings.Replace(valid, "BARBER KING", "BARBER QUIN", 1), ErrChecksum},
+		{"truncated", valid[:len(valid)-2], ErrInvalidPayload},
+		{"no crc", valid[:len(valid)-8], ErrInvalidPayload},
+		{"wrong format", staticCode(func(f []Field) []Field { f[0].Value = "02"; return f }), ErrInvalidPayload},
+		{"not IDR", staticCode(func(f []Field) []Field { f[4].Value = "458"; return f }), ErrInvalidPayload},
+		{"no merchant name", staticCode(without(IDMerchantName)), ErrInvalidPayload},
+		{"no merchant city", staticCode(without(IDMerchantCity)), ErrInvalidPayload},
+		{"no NMID", staticCode(without("26")), ErrInvalidPayload},
+		{"bad header", "0x02" + valid[4:], ErrInvalidPayload},
+		{"negative length", "00-1", ErrInvalidPayload},
+		{"signed length", "00+1" + valid[4:], ErrInvalidPayload},
+		{"negative length in a template", staticCode(func(f []Field) []Field { f[2].Value = "00-1"; return f }), ErrInvalidPayload},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Parse err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMerchant(t *testing.T) {
+	p, err := Parse(staticCode(nil))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Merchant{Name: "BARBER KING", City: "JAKARTA", PostalCode: "12345", NMID: "ID1020304050607"}
+	if got := p.Merchant(); got != want {
+		t.Errorf("Merchant = %+v, want %+v", got, want)
+	}
+}
+
+func TestWithAmount(t *testing.T) {
+	static, err := Parse(staticCode(nil))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tests := []struct {
+		name       string
+		amount     int64
+		wantAmount string
+		wantErr    error
+	}{
+		{"whole rupiah", 25000, "25000", nil},
+		{"one rupiah", 1, "1", nil},
+		{"largest", 9999999999999, "9999999999999", nil},
+		{"zero", 0, "", ErrInvalidPayload},
+		{"negative", -100, "", ErrInvalidPayload},
+		{"too large", 10000000000000, "", ErrInvalidPayload},
+	}
+	for _, tt := range tests {
+		code, err := static.WithAmount(tt.amount)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: WithAmount err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		dynamic, err := Parse(code)
+		if err != nil {
+			t.Errorf("%s: dynamic code does not parse: %v", tt.name, err)
+			continue
+		}
+		m := dynamic.Merchant()
+		if !m.Dynamic || m.Amount != tt.wantAmount || m.NMID != "ID1020304050607" {
+			t.Errorf("%s: Merchant = %+v, want dynamic with amount %s", tt.name, m, tt.wantAmount)
+		}
+		// Charging a dynamic code again replaces its amount rather than adding a second one.
+		again, err := dynamic.WithAmount(5000)
+		if err != nil {
+			t.Fatalf("%s: WithAmount on a dynamic code: %v", tt.name, err)
+		}
+		recharged, err := Parse(again)
+		if err != nil {
+			t.Fatalf("%s: recharged code does not parse: %v", tt.name, err)
+		}
+		var amounts []string
+		for _, f := range recharged.Fields {
+			if f.ID == IDAmount {
+				amounts = append(amounts, f.Value)
+			}
+		}
+		if len(amounts) != 1 || amounts[0] != "5000" {
+			t.Errorf("%s: recharged code carries amounts %v, want [5000]", tt.name, amounts)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package receipt
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+// ClosingReport is the end-of-shift summary printed when a cashier closes.
+type ClosingReport struct {
+	Date          time.Time
+	Shift         string
+	Operator      string
+	Tenders       []Tender
+	Discount      int64
+	ServiceCharge int64
+	Tax           int64
+	Tips          int64
+	Rounding      int64
+	Refund        int64
+	Void          int64
+	VoidCount     int64
+	Counted       string // physical count as entered by the cashier, printed verbatim
+	Note          string
+	Currency      string // currency of the amounts; empty means the settings currency
+}
+
+type Tender struct {
+	Method string
+	Amount int64
+	Count  int
+}
+
+// BuildClosing lays out a closing report with the same header and paper width as receipts.
+func BuildClosing(c ClosingReport, s domain.Settings) Document {
+	doc := newDocument(s)
+	currency := s.CurrencyCode
+	if c.Currency != "" {
+		currency = c.Currency
+	}
+	money := func(v int64) string { return FormatMoney(v, currency) }
+
+	doc.Banner = "CLOSING"
+	doc.Meta = []Row{{Label: "Date", Value: c.Date.Format("2006-01-02")}}
+	if c.Shift != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Shift", Value: c.Shift})
+	}
+	if c.Operator != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Cashier", Value: c.Operator})
+	}
+	doc.Meta = append(doc.Meta, Row{Label: "Printed", Value: time.Now().In(c.Date.Location()).Format("2006-01-02 15:04")})
+
+	var total int64
+	for _, t := range c.Tenders {
+		total += t.Amount
+		doc.Totals = append(doc.Totals, Row{Label: paymentLabel(t.Method) + " (" + strconv.Itoa(t.Count) + ")", Value: money(t.Amount)})
+	}
+	doc.Totals = append(doc.Totals, Row{Label: "TOTAL", Value: money(total), Bold: true})
+	for _, r := range []struct {
+		label  string
+		amount int64
+	}{
+		{"Discount", c.Discount},
+		{"Service charge", c.ServiceCharge},
+		{"Tax", c.Tax},
+		{"Tips", c.Tips},
+		{"Rounding", c.Rounding},
+		{"Refunds", c.Refund},
+	} {
+		if r.amount != 0 {
+			doc.Totals = append(doc.Totals, Row{Label: r.label, Value: money(r.amount)})
+		}
+	}
+	if c.VoidCount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Voids (" + strconv.FormatInt(c.VoidCount, 10) + ")", Value: money(c.Void)})
+	}
+	if c.Counted != "" {
+		doc.Totals = append(doc.Totals, Row{Label: "Counted", Value: c.Counted})
+	}
+	if note := strings.TrimSpace(c.Note); note != "" {
+		doc.Footer = strings.Split(note, "\n")
+	}
+	return doc
+}
@@ -0,0 +1,45 @@
+package receipt
+
+import (
+	"bytes"
+)
+
+// ESC/POS command bytes used by the renderer.
+var (
+	escInit      = []byte{0x1B, 0x40}             // ESC @: reset
+	escBoldOn    = []byte{0x1B, 0x45, 0x01}       // ESC E 1
+	escBoldOff   = []byte{0x1B, 0x45, 0x00}       // ESC E 0
+	escFeedLines = []byte{0x1B, 0x64, 0x04}       // ESC d 4: feed past the cutter
+	escCut       = []byte{0x1D, 0x56, 0x42, 0x00} // GS V B 0: partial cut
+)
+
+// ESCPOS renders the receipt as a byte stream for ESC/POS thermal printers using the standard font,
+// whose width (32 or 48 columns) matches Document.Width. Characters outside ASCII are printed as '?'
+// because the printer's code page is unknown.
+func ESCPOS(doc Document) []byte {
+	var buf bytes.Buffer
+	buf.Write(escInit)
+	for _, l := range layout(doc) {
+		if l.Bold {
+			buf.Write(escBoldOn)
+		}
+		writeASCII(&buf, l.Text)
+		buf.WriteByte('\n')
+		if l.Bold {
+			buf.Write(escBoldOff)
+		}
+	}
+	buf.Write(escFeedLines)
+	buf.Write(escCut)
+	return buf.Bytes()
+}
+
+func writeASCII(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			buf.WriteByte(byte(r))
+		} else {
+			buf.WriteByte('?')
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package receipt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestESCPOS(t *testing.T) {
+	doc := Document{
+		Width:  32,
+		Header: []string{"Barber", "Jl. Melati"},
+		Lines:  []Line{{Name: "Potong rambut é", Qty: 1, Price: "50.000", Total: "50.000"}},
+	}
+	out := ESCPOS(doc)
+
+	if !bytes.HasPrefix(out, escInit) {
+		t.Errorf("stream does not start with ESC @")
+	}
+	if !bytes.HasSuffix(out, append(append([]byte{}, escFeedLines...), escCut...)) {
+		t.Errorf("stream does not end with feed and cut")
+	}
+	// The first header line is bold, the second is not.
+	bold := append(append([]byte{}, escBoldOn...), []byte("             Barber\n")...)
+	bold = append(bold, escBoldOff...)
+	if !bytes.Contains(out, bold) {
+		t.Errorf("business name is not printed bold and centered:\n%q", out)
+	}
+	if bytes.Count(out, escBoldOn) != bytes.Count(out, escBoldOff) {
+		t.Errorf("bold is not switched off after every bold line")
+	}
+	if !bytes.Contains(out, []byte("Potong rambut ?")) {
+		t.Errorf("non-ASCII characters are not replaced with '?':\n%q", out)
+	}
+	for _, b := range out {
+		if b >= 0x80 {
+			t.Fatalf("stream contains byte %#x outside ASCII", b)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package receipt
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Receipt {{.Number}}</title>
+<style>
+@page { size: {{.Doc.PaperMM}}mm auto; margin: 0; }
+body { margin: 0; background: #fff; color: #000; }
+.receipt { width: {{.Doc.PaperMM}}mm; box-sizing: border-box; padding: 3mm; font: 12px/1.35 "Courier New", monospace; }
+.center { text-align: center; }
+.banner { text-align: center; font-weight: bold; margin: 4px 0; }
+.rule { border-top: 1px dashed #000; margin: 4px 0; }
+.row { display: flex; justify-content: space-between; gap: 8px; }
+.row span:last-child { text-align: right; white-space: nowrap; }
+.sub { padding-left: 8px; }
+.bold { font-weight: bold; }
+</style>
+</head>
+<body>
+<div class="receipt">
+{{range .Doc.Header}}<div class="center">{{.}}</div>
+{{end}}{{with .Doc.Banner}}<div class="banner">*** {{.}} ***</div>
+{{end}}<div class="rule"></div>
+{{range .Doc.Meta}}<div class="row"><span>{{.Label}}</span><span>{{.Value}}</span></div>
+{{end}}<div class="rule"></div>
+{{range .Doc.Lines}}<div>{{.Name}}</div>
+<div class="row sub"><span>{{.Qty}} x {{.Price}}</span><span>{{.Total}}</span></div>
+{{with .Discount}}<div class="row sub"><span>Discount</span><span>{{.}}</span></div>
+{{end}}{{if .Refunded}}<div class="sub">Refunded {{.Refunded}}</div>
+{{end}}{{end}}<div class="rule"></div>
+{{range .Doc.Totals}}<div class="row{{if .Bold}} bold{{end}}"><span>{{.Label}}</span><span>{{.Value}}</span></div>
+{{end}}{{if .Doc.Footer}}<div class="rule"></div>
+{{range .Doc.Footer}}<div class="center">{{.}}</div>
+{{end}}{{end}}</div>
+</body>
+</html>
+`))
+
+// HTML renders the receipt as a standalone page sized to the paper width.
+func HTML(doc Document) ([]byte, error) {
+	number := ""
+	if len(doc.Meta) > 0 {
+		number = doc.Meta[0].Value
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, struct {
+		Doc    Document
+		Number string
+	}{doc, number}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
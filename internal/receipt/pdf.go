@@ -0,0 +1,79 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pointsPerMM  = 72 / 25.4
+	pdfMarginPt  = 8.0
+	courierWidth = 0.6 // Courier glyph advance as a fraction of the font size
+)
+
+// PDF renders the text layout on a single page as wide as the paper and as long as the receipt.
+// It only needs the built-in Courier font, so no font files or PDF library are involved.
+func PDF(doc Document) []byte {
+	lines := TextLines(doc)
+	w := doc.Width
+	if w <= 0 {
+		w = Width80mm
+	}
+	pageW := float64(doc.PaperMM) * pointsPerMM
+	if pageW <= 0 {
+		pageW = 80 * pointsPerMM
+	}
+	fontSize := (pageW - 2*pdfMarginPt) / (float64(w) * courierWidth)
+	leading := fontSize * 1.25
+	pageH := 2*pdfMarginPt + leading*float64(len(lines))
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %.2f Tf\n%.2f TL\n%.2f %.2f Td\n", fontSize, leading, pdfMarginPt, pageH-pdfMarginPt-fontSize)
+	for _, l := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(l))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageW, pageH),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape writes s as a WinAnsi string literal body; characters outside Latin-1 become '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+// Package receipt lays out a transaction receipt once and renders it as plain text, HTML or PDF,
+// so every client prints the same thing.
+package receipt
+
+import (
+	"strconv"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
+)
+
+// Paper widths in characters of the printer's standard font.
+const (
+	Width58mm = 32
+	Width80mm = 48
+)
+
+// Document is a receipt laid out independently of the output format.
+type Document struct {
+	PaperMM int
+	Width   int // characters per line
+	Header  []string
+	Banner  string // e.g. VOID; empty for a normal sale
+	Meta    []Row
+	Lines   []Line
+	Totals  []Row
+	Footer  []string
+}
+
+// Row is a label on the left and a value on the right.
+type Row struct {
+	Label string
+	Value string
+	Bold  bool
+}
+
+type Line struct {
+	Name     string
+	Qty      int
+	Price    string
+	Total    string
+	Discount string // empty when the line has no discount
+	Refunded int
+}
+
+// PaperMM reads Settings.PaperSize ("58mm", "80mm"); anything else is treated as 80mm.
+func PaperMM(paperSize string) int {
+	if strings.HasPrefix(strings.TrimSpace(paperSize), "58") {
+		return 58
+	}
+	return 80
+}
+
+// Build lays out the receipt for t using the business details in s.
+func Build(t domain.Transaction, s domain.Settings) Document {
+	doc := newDocument(s)
+	// A transaction keeps the currency it was sold in, even if the shop's setting changed since.
+	currency := s.CurrencyCode
+	if t.Amount.Currency != "" {
+		currency = t.Amount.Currency
+	}
+	money := func(v int64) string { return FormatMoney(v, currency) }
+
+	switch t.Status {
+	case domain.TransactionVoid:
+		doc.Banner = "VOID"
+	case domain.TransactionRefund:
+		doc.Banner = "REFUNDED"
+	}
+
+	number := t.ReceiptNumber
+	if number == "" {
+		number = t.Code
+	}
+	doc.Meta = append(doc.Meta,
+		Row{Label: "Receipt", Value: number},
+		Row{Label: "Date", Value: strings.TrimSpace(t.Date.Format("2006-01-02") + " " + t.Time)},
+	)
+	if t.OperatorName != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Cashier", Value: t.OperatorName})
+	}
+	if t.Stylist != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Stylist", Value: t.Stylist})
+	}
+	if t.Customer != nil && t.Customer.Name != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Customer", Value: t.Customer.Name})
+		if t.Customer.Visits != nil {
+			doc.Meta = append(doc.Meta, Row{Label: "Visit", Value: "#" + strconv.Itoa(*t.Customer.Visits)})
+		}
+	}
+
+	for _, it := range t.Items {
+		l := Line{
+			Name:     it.Name,
+			Qty:      it.Qty,
+			Price:    money(it.Price.Amount),
+			Total:    money(it.Price.Amount*int64(it.Qty) - it.Discount.Amount),
+			Refunded: it.RefundedQty,
+		}
+		if it.Discount.Amount > 0 {
+			l.Discount = "-" + money(it.Discount.Amount)
+		}
+		doc.Lines = append(doc.Lines, l)
+	}
+
+	doc.Totals = append(doc.Totals, Row{Label: "Subtotal", Value: money(t.Subtotal.Amount)})
+	if t.DiscountTotal.Amount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Discount", Value: "-" + money(t.DiscountTotal.Amount)})
+	}
+	if t.ServiceCharge.Amount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Service " + formatRate(t.ServiceChargeRate), Value: money(t.ServiceCharge.Amount)})
+	}
+	if t.TaxAmount.Amount > 0 {
+		label := "Tax " + formatRate(t.TaxRate)
+		if t.TaxInclusive {
+			label += " (incl.)"
+		}
+		doc.Totals = append(doc.Totals, Row{Label: label, Value: money(t.TaxAmount.Amount)})
+	}
+	doc.Totals = append(doc.Totals, Row{Label: "TOTAL", Value: money(t.Amount.Amount), Bold: true})
+	if t.TipTotal.Amount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Tip", Value: money(t.TipTotal.Amount)})
+	}
+	if t.Rounding.Amount != 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Rounding", Value: money(t.Rounding.Amount)})
+	}
+	if len(t.Payments) > 0 {
+		for _, p := range t.Payments {
+			doc.Totals = append(doc.Totals, Row{Label: paymentLabel(p.Method), Value: money(p.Amount.Amount)})
+		}
+	} else if t.PaymentMethod != "" {
+		doc.Totals = append(doc.Totals, Row{Label: paymentLabel(t.PaymentMethod), Value: money(t.Amount.Amount + t.TipTotal.Amount + t.Rounding.Amount)})
+	}
+	if t.RefundedAmount.Amount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Refunded", Value: "-" + money(t.RefundedAmount.Amount)})
+	}
+
+	if f := strings.TrimSpace(s.ReceiptFooter); f != "" {
+		doc.Footer = strings.Split(f, "\n")
+	}
+	return doc
+}
+
+// newDocument sets the paper width and business header shared by every printout.
+func newDocument(s domain.Settings) Document {
+	doc := Document{PaperMM: PaperMM(s.PaperSize), Width: Width80mm}
+	if doc.PaperMM == 58 {
+		doc.Width = Width58mm
+	}
+	for _, h := range []string{s.BusinessName, s.BusinessAddress, s.BusinessPhone} {
+		if h = strings.TrimSpace(h); h != "" {
+			doc.Header = append(doc.Header, h)
+		}
+	}
+	return doc
+}
+
+// FormatMoney formats minor units in the currency's local style: "Rp 25.000" for IDR, "RM 25.00" for MYR.
+func FormatMoney(amount int64, currency string) string {
+	return money.FormatAmount(amount, currency)
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
+
+func paymentLabel(method string) string {
+	method = strings.TrimSpace(method)
+	switch strings.ToLower(method) {
+	case "cash":
+		return "Cash"
+	case "card":
+		return "Card"
+	case "qris":
+		return "QRIS"
+	case "":
+		return "Payment"
+	}
+	return strings.ToUpper(method[:1]) + method[1:]
+}
+
+// BuildTest lays out a short page for checking that the printer is reachable and the paper width is right.
+func BuildTest(s domain.Settings) Document {
+	doc := newDocument(s)
+	doc.Banner = "TEST"
+	doc.Meta = []Row{
+		{Label: "Paper", Value: strconv.Itoa(doc.PaperMM) + "mm"},
+		{Label: "Columns", Value: strconv.Itoa(doc.Width)},
+	}
+	return doc
+}
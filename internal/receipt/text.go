@@ -0,0 +1,152 @@
+package receipt
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Text renders the receipt as fixed-width lines, the same layout thermal printers use.
+func Text(doc Document) string {
+	var b strings.Builder
+	for _, l := range TextLines(doc) {
+		b.WriteString(l)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// TextLines returns the fixed-width lines of the receipt without trailing newlines.
+func TextLines(doc Document) []string {
+	styled := layout(doc)
+	out := make([]string, 0, len(styled))
+	for _, l := range styled {
+		out = append(out, l.Text)
+	}
+	return out
+}
+
+// textLine is one printed line; Bold is honoured by renderers that can emphasise (ESC/POS).
+type textLine struct {
+	Text string
+	Bold bool
+}
+
+func layout(doc Document) []textLine {
+	w := doc.Width
+	if w <= 0 {
+		w = Width80mm
+	}
+	var out []textLine
+	add := func(text string, bold bool) { out = append(out, textLine{Text: text, Bold: bold}) }
+	rule := strings.Repeat("-", w)
+
+	for i, h := range doc.Header {
+		for _, l := range wrap(h, w) {
+			add(center(l, w), i == 0)
+		}
+	}
+	if doc.Banner != "" {
+		add(center("*** "+doc.Banner+" ***", w), true)
+	}
+	add(rule, false)
+	for _, m := range doc.Meta {
+		add(leftRight(m.Label, m.Value, w), m.Bold)
+	}
+	if len(doc.Lines) > 0 {
+		add(rule, false)
+	}
+	for _, l := range doc.Lines {
+		for _, name := range wrap(l.Name, w) {
+			add(name, false)
+		}
+		add(leftRight("  "+strconv.Itoa(l.Qty)+" x "+l.Price, l.Total, w), false)
+		if l.Discount != "" {
+			add(leftRight("  Discount", l.Discount, w), false)
+		}
+		if l.Refunded > 0 {
+			add("  Refunded "+strconv.Itoa(l.Refunded), false)
+		}
+	}
+	if len(doc.Lines) > 0 || len(doc.Totals) > 0 {
+		add(rule, false)
+	}
+	for _, t := range doc.Totals {
+		add(leftRight(t.Label, t.Value, w), t.Bold)
+	}
+	if len(doc.Footer) > 0 {
+		add(rule, false)
+		for _, f := range doc.Footer {
+			for _, l := range wrap(f, w) {
+				add(center(l, w), false)
+			}
+		}
+	}
+	return out
+}
+
+func leftRight(left, right string, w int) string {
+	gap := w - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		// Too long for one line: keep the value intact and cut the label.
+		keep := w - utf8.RuneCountInString(right) - 1
+		if keep < 0 {
+			keep = 0
+		}
+		left = truncate(left, keep)
+		gap = w - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+		if gap < 1 {
+			gap = 1
+		}
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+func center(s string, w int) string {
+	pad := (w - utf8.RuneCountInString(s)) / 2
+	if pad <= 0 {
+		return s
+	}
+	return strings.Repeat(" ", pad) + s
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// wrap breaks s into lines of at most w runes, on spaces where possible.
+func wrap(s string, w int) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var lines []string
+	var cur []rune
+	for _, word := range strings.Fields(s) {
+		r := []rune(word)
+		for len(r) > w {
+			if len(cur) > 0 {
+				lines = append(lines, string(cur))
+				cur = nil
+			}
+			lines = append(lines, string(r[:w]))
+			r = r[w:]
+		}
+		switch {
+		case len(cur) == 0:
+			cur = r
+		case len(cur)+1+len(r) <= w:
+			cur = append(append(cur, ' '), r...)
+		default:
+			lines = append(lines, string(cur))
+			cur = r
+		}
+	}
+	if len(cur) > 0 {
+		lines = append(lines, string(cur))
+	}
+	return lines
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrCashDrawerExists is returned when opening a drawer for a shift that already has one.
+	ErrCashDrawerExists = errors.New("shift already has a cash drawer")
+	// ErrCashDrawerClosed is returned when moving cash in or out of, or closing, a drawer that was closed.
+	ErrCashDrawerClosed = errors.New("cash drawer is closed")
+)
+
+type CashDrawerRepository struct {
+	DB              *db.Postgres
+	DefaultCurrency string // CURRENCY_CODE, for owners who have not saved settings
+}
+
+const cashDrawerColumns = `id, shift_id, opening_float, currency, status, note, opened_by, opened_at, closed_by, closed_at,
+		       expected_cash, counted_cash, variance, created_at, updated_at`
+
+const cashMovementColumns = `id, session_id, kind, amount, reason, note, created_by, created_at`
+
+func scanCashDrawer(row pgx.Row) (*domain.CashDrawerSession, error) {
+	var s domain.CashDrawerSession
+	var status string
+	if err := row.Scan(
+		&s.ID, &s.ShiftID, &s.OpeningFloat.Amount, &s.OpeningFloat.Currency, &status, &s.Note, &s.OpenedBy, &s.OpenedAt, &s.ClosedBy, &s.ClosedAt,
+		&s.ExpectedCash, &s.CountedCash, &s.Variance, &s.CreatedAt, &s.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	s.Status = domain.CashDrawerStatus(status)
+	return &s, nil
+}
+
+// CashDrawerTotals breaks down the cash that should be in a drawer:
+// Expected = OpeningFloat + CashSales - CashRefunds + PayIns - PayOuts.
+type CashDrawerTotals struct {
+	OpeningFloat int64
+	CashSales    int64 // cash tenders of the shift's sales, i.e. what was handed over less change; voided sales excluded
+	CashRefunds  int64 // refunds of the shift's cash-only sales paid out while the drawer was open
+	PayIns       int64
+	PayOuts      int64
+	Expected     int64
+	Currency     string
+}
+
+type OpenCashDrawerInput struct {
+	ShiftID      string
+	OpeningFloat int64
+	Note         string
+	OpenedBy     *int64
+}
+
+// Open starts the drawer for a shift in the owner's currency. A shift has one drawer.
+func (r CashDrawerRepository) Open(ctx context.Context, ownerUserID int64, in OpenCashDrawerInput) (*domain.CashDrawerSession, error) {
+	currency, err := ownerCurrency(ctx, r.DB.Pool, ownerUserID, r.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	s, err := scanCashDrawer(r.DB.Pool.QueryRow(ctx, `
+		INSERT INTO cash_drawer_sessions (owner_user_id, shift_id, opening_float, currency, note, opened_by)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (owner_user_id, shift_id) DO NOTHING
+		RETURNING `+cashDrawerColumns,
+		ownerUserID, in.ShiftID, in.OpeningFloat, currency, in.Note, in.OpenedBy))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrCashDrawerExists
+	}
+	return s, err
+}
+
+// Get returns a drawer with its movements.
+func (r CashDrawerRepository) Get(ctx context.Context, ownerUserID, id int64) (*domain.CashDrawerSession, error) {
+	s, err := scanCashDrawer(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+cashDrawerColumns+`
+		FROM cash_drawer_sessions
+		WHERE id=$1 AND owner_user_id=$2
+	`, id, ownerUserID))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadMovements(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetByShift returns the drawer of a shift with its movements.
+func (r CashDrawerRepository) GetByShift(ctx context.Context, ownerUserID int64, shiftID string) (*domain.CashDrawerSession, error) {
+	s, err := scanCashDrawer(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+cashDrawerColumns+`
+		FROM cash_drawer_sessions
+		WHERE owner_user_id=$1 AND shift_id=$2
+	`, ownerUserID, shiftID))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadMovements(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns drawers newest first, optionally only those with status.
+func (r CashDrawerRepository) List(ctx context.Context, ownerUserID int64, status string, limit int) ([]domain.CashDrawerSession, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+cashDrawerColumns+`
+		FROM cash_drawer_sessions
+		WHERE owner_user_id=$1 AND ($2 = '' OR status=$2)
+		ORDER BY opened_at DESC, id DESC
+		LIMIT $3
+	`, ownerUserID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []domain.CashDrawerSession
+	for rows.Next() {
+		s, err := scanCashDrawer(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *s)
+	}
+	return items, rows.Err()
+}
+
+func (r CashDrawerRepository) loadMovements(ctx context.Context, s *domain.CashDrawerSession) error {
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+cashMovementColumns+`
+		FROM cash_drawer_movements
+		WHERE session_id=$1
+		ORDER BY created_at, id
+	`, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	s.Movements = []domain.CashMovement{}
+	for rows.Next() {
+		var m domain.CashMovement
+		var kind string
+		if err := rows.Scan(&m.ID, &m.SessionID, &kind, &m.Amount.Amount, &m.Reason, &m.Note, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return err
+		}
+		m.Kind = domain.CashMovementKind(kind)
+		m.Amount.Currency = s.OpeningFloat.Currency
+		s.Movements = append(s.Movements, m)
+	}
+	return rows.Err()
+}
+
+type CashMovementInput struct {
+	Kind      domain.CashMovementKind
+	Amount    int64
+	Reason    string
+	Note      string
+	CreatedBy *int64
+}
+
+// AddMovement records cash put into or taken out of an open drawer.
+func (r CashDrawerRepository) AddMovement(ctx context.Context, ownerUserID, sessionID int64, in CashMovementInput) (*domain.CashMovement, error) {
+	var m domain.CashMovement
+	var kind string
+	err := r.DB.Pool.QueryRow(ctx, `
+		INSERT INTO cash_drawer_movements (session_id, kind, amount, reason, note, created_by)
+		SELECT id, $3, $4, $5, $6, $7
+		FROM cash_drawer_sessions
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+		RETURNING `+cashMovementColumns+`, (SELECT currency FROM cash_drawer_sessions s WHERE s.id = session_id)`,
+		sessionID, ownerUserID, string(in.Kind), in.Amount, in.Reason, in.Note, in.CreatedBy,
+	).Scan(&m.ID, &m.SessionID, &kind, &m.Amount.Amount, &m.Reason, &m.Note, &m.CreatedBy, &m.CreatedAt, &m.Amount.Currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := r.Get(ctx, ownerUserID, sessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrCashDrawerClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Kind = domain.CashMovementKind(kind)
+	return &m, nil
+}
+
+// Totals computes what should be in the drawer now, or at closing for a closed one.
+func (r CashDrawerRepository) Totals(ctx context.Context, ownerUserID int64, s domain.CashDrawerSession) (CashDrawerTotals, error) {
+	return cashDrawerTotals(ctx, r.DB.Pool, ownerUserID, s)
+}
+
+// cashDrawerTotals counts sales by the drawer's shift, including sales deleted by a full refund, whose cash
+// came in all the same. Refunds count against the drawer of the sale's shift while it was open; the sale must
+// have been paid in cash only for its refund to come from the till.
+func cashDrawerTotals(ctx context.Context, q pgxQuerier, ownerUserID int64, s domain.CashDrawerSession) (CashDrawerTotals, error) {
+	t := CashDrawerTotals{OpeningFloat: s.OpeningFloat.Amount, Currency: s.OpeningFloat.Currency}
+	until := time.Now()
+	if s.ClosedAt != nil {
+		until = *s.ClosedAt
+	}
+	err := q.QueryRow(ctx, `
+		SELECT
+			COALESCE((
+				SELECT SUM(p.amount)
+				FROM transaction_payments p
+				JOIN transactions t ON t.id = p.transaction_id
+				WHERE t.owner_user_id=$1 AND t.shift_id=$2 AND (t.deleted_at IS NULL OR t.status='refund') AND lower(p.method)='cash'
+				  AND t.status NOT IN ('pending','failed','void')
+			),0),
+			COALESCE((
+				SELECT SUM(rf.amount)
+				FROM transaction_refunds rf
+				JOIN transactions t ON t.id = rf.transaction_id
+				WHERE t.owner_user_id=$1 AND t.shift_id=$2 AND rf.reversed_at IS NULL AND rf.created_at >= $4 AND rf.created_at < $5
+				  AND EXISTS (SELECT 1 FROM transaction_payments p WHERE p.transaction_id = t.id)
+				  AND NOT EXISTS (SELECT 1 FROM transaction_payments p WHERE p.transaction_id = t.id AND lower(p.method) <> 'cash')
+			),0),
+			COALESCE((SELECT SUM(amount) FROM cash_drawer_movements WHERE session_id=$3 AND kind='pay_in'),0),
+			COALESCE((SELECT SUM(amount) FROM cash_drawer_movements WHERE session_id=$3 AND kind='pay_out'),0)
+	`, ownerUserID, s.ShiftID, s.ID, s.OpenedAt, until).Scan(&t.CashSales, &t.CashRefunds, &t.PayIns, &t.PayOuts)
+	if err != nil {
+		return t, err
+	}
+	t.Expected = t.OpeningFloat + t.CashSales - t.CashRefunds + t.PayIns - t.PayOuts
+	return t, nil
+}
+
+// closeCashDrawerWithTx closes the shift's drawer against the counted cash, fixing the expected figure and
+// the variance at this moment.
+func closeCashDrawerWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, shiftID string, counted int64, closedBy *int64) (*domain.CashDrawerSession, CashDrawerTotals, error) {
+	s, err := scanCashDrawer(tx.QueryRow(ctx, `
+		SELECT `+cashDrawerColumns+`
+		FROM cash_drawer_sessions
+		WHERE owner_user_id=$1 AND shift_id=$2
+		FOR UPDATE
+	`, ownerUserID, shiftID))
+	if err != nil {
+		return nil, CashDrawerTotals{}, err
+	}
+	if s.Status != domain.CashDrawerOpen {
+		return nil, CashDrawerTotals{}, ErrCashDrawerClosed
+	}
+	now := time.Now()
+	s.ClosedAt = &now
+	totals, err := cashDrawerTotals(ctx, tx, ownerUserID, *s)
+	if err != nil {
+		return nil, CashDrawerTotals{}, err
+	}
+	s, err = scanCashDrawer(tx.QueryRow(ctx, `
+		UPDATE cash_drawer_sessions
+		SET status='closed', closed_by=$2, closed_at=$3, expected_cash=$4, counted_cash=$5, variance=$5 - $4, updated_at=now()
+		WHERE id=$1
+		RETURNING `+cashDrawerColumns,
+		s.ID, closedBy, now, totals.Expected, counted))
+	if err != nil {
+		return nil, CashDrawerTotals{}, err
+	}
+	return s, totals, nil
+}
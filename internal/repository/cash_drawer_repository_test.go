@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCashDrawerTotalsRefundedCashSale(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	owner := testOwner(t, pg)
+	drawers := CashDrawerRepository{DB: pg}
+	txs := TransactionRepository{DB: pg}
+
+	sell := func(shiftID string, amount int64) string {
+		t.Helper()
+		time.Sleep(2 * time.Millisecond) // codes are minted from the clock in milliseconds
+		sale, err := txs.Create(ctx, owner, CreateTransactionInput{
+			PaymentMethod: "cash",
+			ShiftID:       &shiftID,
+			Amount:        amount,
+			Subtotal:      amount,
+			Items:         []CreateTransactionItem{{Name: "Haircut", Price: amount, Qty: 1}},
+		}, nil)
+		if err != nil {
+			t.Fatalf("create sale: %v", err)
+		}
+		return sale.Code
+	}
+	expected := func(shiftID string) CashDrawerTotals {
+		t.Helper()
+		s, err := drawers.GetByShift(ctx, owner, shiftID)
+		if err != nil {
+			t.Fatalf("get drawer: %v", err)
+		}
+		totals, err := drawers.Totals(ctx, owner, *s)
+		if err != nil {
+			t.Fatalf("totals: %v", err)
+		}
+		return totals
+	}
+
+	for _, shift := range []string{"sh_a", "sh_b"} {
+		if _, err := drawers.Open(ctx, owner, OpenCashDrawerInput{ShiftID: shift, OpeningFloat: 100000}); err != nil {
+			t.Fatalf("open drawer: %v", err)
+		}
+	}
+	full := sell("sh_a", 50000)
+	part := sell("sh_a", 40000)
+	other := sell("sh_b", 30000)
+
+	if got := expected("sh_a").Expected; got != 190000 {
+		t.Fatalf("expected before refunds = %d, want 190000", got)
+	}
+	// Refund & delete, the default from the app.
+	if _, err := txs.RefundByCode(ctx, owner, RefundTransactionParams{Code: full, Delete: true}, nil); err != nil {
+		t.Fatalf("full refund: %v", err)
+	}
+	if _, err := txs.RefundByCode(ctx, owner, RefundTransactionParams{Code: part, Amount: 15000}, nil); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	// A refund on another terminal's sale comes out of that terminal's drawer.
+	if _, err := txs.RefundByCode(ctx, owner, RefundTransactionParams{Code: other, Delete: true}, nil); err != nil {
+		t.Fatalf("other refund: %v", err)
+	}
+
+	a := expected("sh_a")
+	if a.CashSales != 90000 || a.CashRefunds != 65000 || a.Expected != 125000 {
+		t.Errorf("sh_a totals = sales %d, refunds %d, expected %d; want 90000, 65000, 125000", a.CashSales, a.CashRefunds, a.Expected)
+	}
+	b := expected("sh_b")
+	if b.CashSales != 30000 || b.CashRefunds != 30000 || b.Expected != 100000 {
+		t.Errorf("sh_b totals = sales %d, refunds %d, expected %d; want 30000, 30000, 100000", b.CashSales, b.CashRefunds, b.Expected)
+	}
+}
@@ -5,16 +5,35 @@ import (
 	"time"
 
 	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
 )
 
 type ClosingRepository struct {
-	DB *db.Postgres
+	DB              *db.Postgres
+	DefaultCurrency string // CURRENCY_CODE, for owners who have not saved settings
 }
 
 type ClosingSummary struct {
-	TotalCash    int64
-	TotalNonCash int64
-	TotalCard    int64
+	TotalCash          int64
+	TotalNonCash       int64
+	TotalCard          int64
+	TotalDiscount      int64
+	TotalServiceCharge int64
+	TotalTax           int64
+	TotalRefund        int64
+	TotalTips          int64
+	TotalRounding      int64 // cash rounding; TotalCash = cash sales + cash tips + TotalRounding
+	TotalVoid          int64
+	VoidCount          int64
+	Tenders            []TenderTotal
+	Currency           string
+}
+
+// TenderTotal is today's paid amount for one payment method.
+type TenderTotal struct {
+	Method string
+	Amount int64
+	Count  int
 }
 
 type ClosingHistory struct {
@@ -29,19 +48,87 @@ type ClosingHistory struct {
 	Catatan      string
 	Fisik        string
 	CreatedAt    time.Time
+	// Set when the closing counted the shift's cash drawer.
+	CashDrawerID *int64
+	ExpectedCash *int64
+	CountedCash  *int64
+	Variance     *int64
 }
 
-// Summary aggregates today's transactions by payment method.
+// Summary aggregates today's paid tenders by payment method (a split payment counts towards each of its methods),
+// plus the discount, service charge, tax, tips and cash rounding collected, the refunds paid out and the sales voided today.
+// Tenders include tips, since tips are paid through the same tenders as the sale.
+// "Today" is the owner's business day in their settings timezone.
 func (r ClosingRepository) Summary(ctx context.Context, ownerUserID int64) (ClosingSummary, error) {
 	var s ClosingSummary
-	err := r.DB.Pool.QueryRow(ctx, `
+	start, err := businessToday(ctx, r.DB.Pool, ownerUserID)
+	if err != nil {
+		return s, err
+	}
+	if s.Currency, err = salesCurrency(ctx, r.DB.Pool, ownerUserID, &start, &start, r.DefaultCurrency); err != nil {
+		return s, err
+	}
+	today := start.Format("2006-01-02")
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT lower(p.method) AS method, COALESCE(SUM(p.amount),0), COUNT(*)
+		FROM transaction_payments p
+		JOIN transactions t ON t.id = p.transaction_id
+		WHERE t.deleted_at IS NULL AND t.owner_user_id=$1 AND t.status IN ('paid','partially_refunded') AND t.transacted_date = $2::date
+		GROUP BY lower(p.method)
+		ORDER BY method
+	`, ownerUserID, today)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TenderTotal
+		if err := rows.Scan(&t.Method, &t.Amount, &t.Count); err != nil {
+			return s, err
+		}
+		switch t.Method {
+		case "cash":
+			s.TotalCash += t.Amount
+		case "card":
+			s.TotalCard += t.Amount
+		default:
+			s.TotalNonCash += t.Amount
+		}
+		s.Tenders = append(s.Tenders, t)
+	}
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+
+	err = r.DB.Pool.QueryRow(ctx, `
 		SELECT
-			COALESCE(SUM(amount) FILTER (WHERE lower(payment_method) = 'cash' AND status='paid' AND transacted_date = CURRENT_DATE),0) AS cash,
-			COALESCE(SUM(amount) FILTER (WHERE lower(payment_method) <> 'cash' AND lower(payment_method) <> 'card' AND status='paid' AND transacted_date = CURRENT_DATE),0) AS noncash,
-			COALESCE(SUM(amount) FILTER (WHERE lower(payment_method) = 'card' AND status='paid' AND transacted_date = CURRENT_DATE),0) AS card
+			COALESCE(SUM(discount_total),0) AS discount,
+			COALESCE(SUM(service_charge),0) AS service_charge,
+			COALESCE(SUM(tax_amount),0) AS tax,
+			COALESCE(SUM(tip_total),0) AS tips,
+			COALESCE(SUM(rounding_adjustment),0) AS rounding
 		FROM transactions
-		WHERE deleted_at IS NULL AND owner_user_id=$1
-	`, ownerUserID).Scan(&s.TotalCash, &s.TotalNonCash, &s.TotalCard)
+		WHERE deleted_at IS NULL AND owner_user_id=$1 AND status IN ('paid','partially_refunded') AND transacted_date = $2::date
+	`, ownerUserID, today).Scan(&s.TotalDiscount, &s.TotalServiceCharge, &s.TotalTax, &s.TotalTips, &s.TotalRounding)
+	if err != nil {
+		return s, err
+	}
+
+	err = r.DB.Pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount),0), COUNT(*)
+		FROM transactions
+		WHERE deleted_at IS NULL AND owner_user_id=$1 AND status = 'void' AND transacted_date = $2::date
+	`, ownerUserID, today).Scan(&s.TotalVoid, &s.VoidCount)
+	if err != nil {
+		return s, err
+	}
+
+	err = r.DB.Pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(rf.amount),0)
+		FROM transaction_refunds rf
+		JOIN transactions t ON t.id = rf.transaction_id
+		WHERE t.owner_user_id=$1 AND rf.reversed_at IS NULL AND rf.created_at >= $2 AND rf.created_at < $3
+	`, ownerUserID, start, start.AddDate(0, 0, 1)).Scan(&s.TotalRefund)
 	return s, err
 }
 
@@ -67,12 +154,46 @@ func (r ClosingRepository) Create(ctx context.Context, ownerUserID int64, in Cre
 	return id, err
 }
 
+// CreateWithCount records a closing that counts the shift's cash drawer: the drawer is closed and the
+// closing keeps the expected cash, the counted cash and the variance, all in one database transaction.
+func (r ClosingRepository) CreateWithCount(ctx context.Context, ownerUserID int64, in CreateClosingInput, counted int64, closedBy *int64) (int64, *domain.CashDrawerSession, CashDrawerTotals, error) {
+	var totals CashDrawerTotals
+	if in.ShiftID == nil || *in.ShiftID == "" {
+		return 0, nil, totals, ErrNotFound
+	}
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return 0, nil, totals, err
+	}
+	defer tx.Rollback(ctx)
+	drawer, totals, err := closeCashDrawerWithTx(ctx, tx, ownerUserID, *in.ShiftID, counted, closedBy)
+	if err != nil {
+		return 0, nil, totals, err
+	}
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO closing_history (owner_user_id, tanggal, shift, karyawan, shift_id, operator_name, total, status, catatan, fisik,
+		                             cash_drawer_session_id, expected_cash, counted_cash, variance, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14, now(), now())
+		RETURNING id
+	`, ownerUserID, in.Tanggal.Format("2006-01-02"), in.Shift, in.Karyawan, in.ShiftID, in.OperatorName, in.Total, in.Status, in.Catatan, in.Fisik,
+		drawer.ID, drawer.ExpectedCash, drawer.CountedCash, drawer.Variance).Scan(&id)
+	if err != nil {
+		return 0, nil, totals, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, totals, err
+	}
+	return id, drawer, totals, nil
+}
+
 func (r ClosingRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]ClosingHistory, error) {
 	if limit <= 0 {
 		limit = 50
 	}
 	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT id, tanggal, shift, karyawan, shift_id, operator_name, total, status, catatan, fisik, created_at
+		SELECT id, tanggal, shift, karyawan, shift_id, operator_name, total, status, catatan, fisik, created_at,
+		       cash_drawer_session_id, expected_cash, counted_cash, variance
 		FROM closing_history
 		WHERE deleted_at IS NULL AND owner_user_id=$1
 		ORDER BY tanggal DESC, id DESC
@@ -98,6 +219,10 @@ func (r ClosingRepository) List(ctx context.Context, ownerUserID int64, limit in
 			&c.Catatan,
 			&c.Fisik,
 			&c.CreatedAt,
+			&c.CashDrawerID,
+			&c.ExpectedCash,
+			&c.CountedCash,
+			&c.Variance,
 		); err != nil {
 			return nil, err
 		}
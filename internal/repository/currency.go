@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/money"
+	"github.com/jackc/pgx/v5"
+)
+
+// ownerCurrency returns the currency the owner records amounts in without creating a settings row:
+// fallback, the configured default, when they have none.
+func ownerCurrency(ctx context.Context, q pgxQuerier, ownerUserID int64, fallback string) (string, error) {
+	var code string
+	err := q.QueryRow(ctx, `SELECT currency_code FROM settings WHERE owner_user_id=$1`, ownerUserID).Scan(&code)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	if code == "" {
+		code = fallback
+	}
+	return money.Normalize(code), nil
+}
+
+// salesCurrency is the one currency of the owner's transactions dated from start to end (either may be nil),
+// so sums over them mean something. It fails with money.ErrCurrencyMismatch when they span currencies,
+// and falls back to ownerCurrency when there are none.
+func salesCurrency(ctx context.Context, q pgxQuerier, ownerUserID int64, start, end *time.Time, fallback string) (string, error) {
+	var startDate, endDate *string
+	if start != nil {
+		s := start.Format("2006-01-02")
+		startDate = &s
+	}
+	if end != nil {
+		e := end.Format("2006-01-02")
+		endDate = &e
+	}
+	var codes []string
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT currency), '{}')
+		FROM transactions
+		WHERE deleted_at IS NULL AND owner_user_id=$1
+		  AND ($2::date IS NULL OR transacted_date >= $2::date)
+		  AND ($3::date IS NULL OR transacted_date <= $3::date)
+	`, ownerUserID, startDate, endDate).Scan(&codes)
+	if err != nil {
+		return "", err
+	}
+	switch len(codes) {
+	case 0:
+		return ownerCurrency(ctx, q, ownerUserID, fallback)
+	case 1:
+		return codes[0], nil
+	}
+	return "", money.ErrCurrencyMismatch
+}
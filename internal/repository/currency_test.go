@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOwnerCurrencyFallsBackToConfiguredDefault(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	owner := testOwner(t, pg)
+
+	got, err := ownerCurrency(ctx, pg.Pool, owner, "usd")
+	if err != nil {
+		t.Fatalf("ownerCurrency: %v", err)
+	}
+	if got != "USD" {
+		t.Errorf("without settings: currency = %q, want the configured USD", got)
+	}
+
+	settings := SettingsRepository{DB: pg, DefaultCurrency: "USD"}
+	s, err := settings.Get(ctx, owner)
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	s.CurrencyCode = "SGD"
+	if _, err := settings.Save(ctx, owner, *s); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	if got, err := salesCurrency(ctx, pg.Pool, owner, nil, nil, "USD"); err != nil || got != "SGD" {
+		t.Errorf("with settings: currency = %q (%v), want the saved SGD", got, err)
+	}
+}
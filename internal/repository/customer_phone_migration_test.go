@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNormalizeCustomerPhonesMigration(t *testing.T) {
+	const migration = "0047_normalize_customer_phones.sql"
+	pg := testDBBefore(t, migration)
+	ctx := context.Background()
+	txs := TransactionRepository{DB: pg}
+	shop, other := testOwner(t, pg), testOwner(t, pg)
+
+	customer := func(owner int64, phone string, ageHours int, deleted bool) int64 {
+		t.Helper()
+		var id int64
+		err := pg.Pool.QueryRow(ctx, `
+			INSERT INTO customers (owner_user_id, name, phone, created_at, deleted_at)
+			VALUES ($1, 'Customer', $2, now() - make_interval(hours => $3), CASE WHEN $4 THEN now() END)
+			RETURNING id
+		`, owner, phone, ageHours, deleted).Scan(&id)
+		if err != nil {
+			t.Fatalf("insert customer %q: %v", phone, err)
+		}
+		return id
+	}
+	sell := func(customerID, amount int64) {
+		t.Helper()
+		time.Sleep(2 * time.Millisecond) // codes are minted from the clock in milliseconds
+		sale, err := txs.Create(ctx, shop, CreateTransactionInput{
+			PaymentMethod: "cash",
+			Amount:        amount,
+			Subtotal:      amount,
+			Items:         []CreateTransactionItem{{Name: "Haircut", Price: amount, Qty: 1}},
+		}, nil)
+		if err != nil {
+			t.Fatalf("create sale: %v", err)
+		}
+		if _, err := pg.Pool.Exec(ctx, `UPDATE transactions SET customer_id=$1 WHERE id=$2`, customerID, sale.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted := customer(shop, " (0812)3456789", 72, true)
+	first := customer(shop, "0812-3456-789", 48, false)
+	second := customer(shop, "0812 3456 789", 24, false)
+	alone := customer(shop, "0899.1", 1, false)
+	otherShop := customer(other, "0812-3456-789", 1, false)
+	sell(first, 30000)
+	sell(second, 50000)
+
+	migrateTestDB(t, pg, migration)
+
+	phones := map[int64]string{}
+	rows, err := pg.Pool.Query(ctx, `SELECT id, phone FROM customers`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		var phone string
+		if err := rows.Scan(&id, &phone); err != nil {
+			t.Fatal(err)
+		}
+		phones[id] = phone
+	}
+	rows.Close()
+
+	want := map[int64]string{first: "08123456789", alone: "08991", otherShop: "08123456789"}
+	if len(phones) != len(want) {
+		t.Errorf("customers after migration = %v, want %v (ids %d and %d merged into %d)", phones, want, deleted, second, first)
+	}
+	for id, phone := range want {
+		if phones[id] != phone {
+			t.Errorf("customer %d phone = %q, want %q", id, phones[id], phone)
+		}
+	}
+
+	var visits int
+	var spend int64
+	if err := pg.Pool.QueryRow(ctx, `SELECT visit_count, lifetime_spend FROM customers WHERE id=$1`, first).Scan(&visits, &spend); err != nil {
+		t.Fatal(err)
+	}
+	if visits != 2 || spend != 80000 {
+		t.Errorf("merged customer has %d visits, spend %d; want 2, 80000", visits, spend)
+	}
+}
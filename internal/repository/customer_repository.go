@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"barberpos-backend/internal/db"
 	"barberpos-backend/internal/domain"
@@ -12,9 +14,22 @@ type CustomerRepository struct {
 	DB *db.Postgres
 }
 
+// ErrCustomerNotFound is returned when an order names a customer id the owner does not have.
+var ErrCustomerNotFound = errors.New("customer not found")
+
+const customerColumns = `id, name, phone, email, address, visit_count, last_visit, lifetime_spend, created_at, updated_at`
+
+func scanCustomer(row pgx.Row) (*domain.Customer, error) {
+	var c domain.Customer
+	if err := row.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Address, &c.Visits, &c.LastVisit, &c.LifetimeSpend.Amount, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 func (r CustomerRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]domain.Customer, error) {
 	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT id, name, phone, email, address, created_at, updated_at
+		SELECT `+customerColumns+`
 		FROM customers
 		WHERE deleted_at IS NULL AND owner_user_id=$1
 		ORDER BY name ASC
@@ -26,24 +41,22 @@ func (r CustomerRepository) List(ctx context.Context, ownerUserID int64, limit i
 	defer rows.Close()
 	var items []domain.Customer
 	for rows.Next() {
-		var c domain.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Address, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		c, err := scanCustomer(rows)
+		if err != nil {
 			return nil, err
 		}
-		items = append(items, c)
+		items = append(items, *c)
 	}
 	return items, rows.Err()
 }
 
 func (r CustomerRepository) Upsert(ctx context.Context, ownerUserID int64, c domain.Customer) (*domain.Customer, error) {
-	var out domain.Customer
-	err := r.DB.Pool.QueryRow(ctx, `
+	return scanCustomer(r.DB.Pool.QueryRow(ctx, `
 		INSERT INTO customers (id, owner_user_id, name, phone, email, address, created_at, updated_at)
 		VALUES (COALESCE($1, nextval('customers_id_seq')), $2, $3, $4, $5, $6, now(), now())
 		ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name, phone=EXCLUDED.phone, email=EXCLUDED.email, address=EXCLUDED.address, updated_at=now(), deleted_at=NULL
-		RETURNING id, name, phone, email, address, created_at, updated_at
-	`, nullableID(c.ID), ownerUserID, c.Name, c.Phone, c.Email, c.Address).Scan(&out.ID, &out.Name, &out.Phone, &out.Email, &out.Address, &out.CreatedAt, &out.UpdatedAt)
-	return &out, err
+		RETURNING `+customerColumns,
+		nullableID(c.ID), ownerUserID, c.Name, NormalizePhone(c.Phone), c.Email, c.Address))
 }
 
 func (r CustomerRepository) Delete(ctx context.Context, ownerUserID int64, id int64) error {
@@ -52,19 +65,101 @@ func (r CustomerRepository) Delete(ctx context.Context, ownerUserID int64, id in
 }
 
 func (r CustomerRepository) Get(ctx context.Context, ownerUserID int64, id int64) (*domain.Customer, error) {
-	row := r.DB.Pool.QueryRow(ctx, `
-		SELECT id, name, phone, email, address, created_at, updated_at
+	c, err := scanCustomer(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+customerColumns+`
 		FROM customers
 		WHERE id=$1 AND owner_user_id=$2 AND deleted_at IS NULL
-	`, id, ownerUserID)
-	var c domain.Customer
-	if err := row.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Address, &c.CreatedAt, &c.UpdatedAt); err != nil {
+	`, id, ownerUserID))
+	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	return &c, nil
+	return c, nil
+}
+
+// CustomerLink identifies the customer an order is for: an existing id, or a phone number that is
+// matched against the owner's customers and registered under Name when it is new.
+type CustomerLink struct {
+	ID    *int64
+	Phone string
+	Name  string
+}
+
+// resolveCustomerWithTx finds or creates the linked customer and locks the row until tx ends, so
+// concurrent orders for one customer update the visit statistics one after the other.
+func resolveCustomerWithTx(ctx context.Context, q pgxQuerier, ownerUserID int64, link CustomerLink) (*domain.Customer, error) {
+	if link.ID != nil {
+		c, err := scanCustomer(q.QueryRow(ctx, `
+			SELECT `+customerColumns+`
+			FROM customers
+			WHERE id=$1 AND owner_user_id=$2 AND deleted_at IS NULL
+			FOR UPDATE
+		`, *link.ID, ownerUserID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCustomerNotFound
+		}
+		return c, err
+	}
+	phone := NormalizePhone(link.Phone)
+	if phone == "" {
+		return nil, nil
+	}
+	name := strings.TrimSpace(link.Name)
+	if name == "" {
+		name = phone
+	}
+	// A deleted customer coming back is restored rather than duplicated; a known name is never overwritten.
+	return scanCustomer(q.QueryRow(ctx, `
+		INSERT INTO customers (owner_user_id, name, phone, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+		ON CONFLICT (owner_user_id, phone) DO UPDATE
+		SET name = CASE WHEN customers.name = '' OR customers.name = customers.phone THEN EXCLUDED.name ELSE customers.name END,
+		    deleted_at = NULL,
+		    updated_at = now()
+		RETURNING `+customerColumns,
+		ownerUserID, name, phone))
+}
+
+// refreshCustomerStatsWithTx recomputes visit count, last visit and lifetime spend from the customer's
+// settled, non-void transactions. Spend is what was charged net of refunds, without tips.
+func refreshCustomerStatsWithTx(ctx context.Context, q pgxQuerier, customerID int64) error {
+	_, err := q.Exec(ctx, `
+		UPDATE customers c
+		SET visit_count = s.visits, last_visit = s.last_visit, lifetime_spend = s.spend, updated_at = now()
+		FROM (
+			SELECT COUNT(*) AS visits, MAX(transacted_date) AS last_visit, COALESCE(SUM(amount - refunded_amount), 0) AS spend
+			FROM transactions
+			WHERE customer_id = $1 AND status NOT IN ('pending','void','failed')
+		) s
+		WHERE c.id = $1
+	`, customerID)
+	return err
+}
+
+// refreshTransactionCustomerWithTx refreshes the statistics of the customer a transaction is linked to, if any.
+func refreshTransactionCustomerWithTx(ctx context.Context, q pgxQuerier, transactionID int64) error {
+	var customerID *int64
+	if err := q.QueryRow(ctx, `SELECT customer_id FROM transactions WHERE id=$1`, transactionID).Scan(&customerID); err != nil {
+		return err
+	}
+	if customerID == nil {
+		return nil
+	}
+	return refreshCustomerStatsWithTx(ctx, q, *customerID)
+}
+
+// NormalizePhone strips the spaces, dashes, dots and brackets people type into phone numbers,
+// so the same number always matches the same customer.
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
 }
 
 func nullableID(id int64) *int64 {
@@ -8,7 +8,8 @@ import (
 )
 
 type DashboardRepository struct {
-	DB *db.Postgres
+	DB              *db.Postgres
+	DefaultCurrency string // CURRENCY_CODE, for owners who have not saved settings
 }
 
 type DashboardSummary struct {
@@ -18,12 +19,15 @@ type DashboardSummary struct {
 	TodayTransactions int64
 	TodayCustomers    int64
 	ServicesSold      int64
+	Currency          string
 }
 
 type DashboardItem struct {
-	Name   string
-	Amount int64
-	Count  int64
+	Name     string
+	Amount   int64 // net of discounts
+	Gross    int64 // at list price
+	Discount int64
+	Count    int64
 }
 
 type SalesPoint struct {
@@ -31,37 +35,53 @@ type SalesPoint struct {
 	Amount int64
 }
 
+// Summary counts paid and partially refunded transactions, net of what has been refunded.
+// "Today" is the owner's business day in their settings timezone.
 func (r DashboardRepository) Summary(ctx context.Context, ownerUserID int64) (DashboardSummary, error) {
 	var s DashboardSummary
-	err := r.DB.Pool.QueryRow(ctx, `
+	today, err := businessToday(ctx, r.DB.Pool, ownerUserID)
+	if err != nil {
+		return s, err
+	}
+	if s.Currency, err = salesCurrency(ctx, r.DB.Pool, ownerUserID, nil, nil, r.DefaultCurrency); err != nil {
+		return s, err
+	}
+	err = r.DB.Pool.QueryRow(ctx, `
 		SELECT
-			COALESCE(SUM(amount) FILTER (WHERE status = 'paid'),0) AS total_revenue,
-			COUNT(*) FILTER (WHERE status = 'paid') AS total_tx,
-			COALESCE(SUM(amount) FILTER (WHERE status = 'paid' AND transacted_date = CURRENT_DATE),0) AS today_revenue,
-			COUNT(*) FILTER (WHERE status = 'paid' AND transacted_date = CURRENT_DATE) AS today_tx,
+			COALESCE(SUM(amount - refunded_amount) FILTER (WHERE status IN ('paid','partially_refunded')),0) AS total_revenue,
+			COUNT(*) FILTER (WHERE status IN ('paid','partially_refunded')) AS total_tx,
+			COALESCE(SUM(amount - refunded_amount) FILTER (WHERE status IN ('paid','partially_refunded') AND transacted_date = $2::date),0) AS today_revenue,
+			COUNT(*) FILTER (WHERE status IN ('paid','partially_refunded') AND transacted_date = $2::date) AS today_tx,
 			COALESCE((
 				SELECT COUNT(DISTINCT NULLIF(customer_name, ''))
 				FROM transactions
-				WHERE deleted_at IS NULL AND status = 'paid' AND transacted_date = CURRENT_DATE AND owner_user_id=$1
+				WHERE deleted_at IS NULL AND status IN ('paid','partially_refunded') AND transacted_date = $2::date AND owner_user_id=$1
 			),0) AS today_customers,
 			COALESCE((
-				SELECT SUM(ti.qty)
+				SELECT SUM(ti.qty - ti.refunded_qty)
 				FROM transaction_items ti
 				JOIN transactions t ON t.id = ti.transaction_id
-				WHERE t.deleted_at IS NULL AND t.status = 'paid' AND t.transacted_date = CURRENT_DATE AND t.owner_user_id=$1
+				WHERE t.deleted_at IS NULL AND t.status IN ('paid','partially_refunded') AND t.transacted_date = $2::date AND t.owner_user_id=$1
 			),0) AS services_sold
 		FROM transactions
 		WHERE deleted_at IS NULL AND owner_user_id=$1
-	`, ownerUserID).Scan(&s.TotalRevenue, &s.TotalTransactions, &s.TodayRevenue, &s.TodayTransactions, &s.TodayCustomers, &s.ServicesSold)
+	`, ownerUserID, today.Format("2006-01-02")).Scan(&s.TotalRevenue, &s.TotalTransactions, &s.TodayRevenue, &s.TodayTransactions, &s.TodayCustomers, &s.ServicesSold)
 	return s, err
 }
 
+// TopServices ranks services by revenue over paid and partially refunded sales, leaving out refunded units;
+// a line's discount is spread evenly over its units.
 func (r DashboardRepository) TopServices(ctx context.Context, ownerUserID int64, limit int) ([]DashboardItem, error) {
+	if _, err := salesCurrency(ctx, r.DB.Pool, ownerUserID, nil, nil, r.DefaultCurrency); err != nil {
+		return nil, err
+	}
 	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT name, COALESCE(SUM(price*qty),0) AS amount, SUM(qty) AS qty
+		SELECT name, COALESCE(SUM((price*qty - discount) * (qty - refunded_qty) / qty),0) AS amount,
+		       COALESCE(SUM(price * (qty - refunded_qty)),0) AS gross,
+		       COALESCE(SUM(discount * (qty - refunded_qty) / qty),0) AS discount, SUM(qty - refunded_qty) AS qty
 		FROM transaction_items
-		WHERE transaction_id IN (
-			SELECT id FROM transactions WHERE deleted_at IS NULL AND owner_user_id=$1
+		WHERE refunded_qty < qty AND transaction_id IN (
+			SELECT id FROM transactions WHERE deleted_at IS NULL AND status IN ('paid','partially_refunded') AND owner_user_id=$1
 		)
 		GROUP BY name
 		ORDER BY amount DESC
@@ -74,7 +94,7 @@ func (r DashboardRepository) TopServices(ctx context.Context, ownerUserID int64,
 	var items []DashboardItem
 	for rows.Next() {
 		var it DashboardItem
-		if err := rows.Scan(&it.Name, &it.Amount, &it.Count); err != nil {
+		if err := rows.Scan(&it.Name, &it.Amount, &it.Gross, &it.Discount, &it.Count); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -82,12 +102,27 @@ func (r DashboardRepository) TopServices(ctx context.Context, ownerUserID int64,
 	return items, rows.Err()
 }
 
+// TopStaff credits each line to the stylist who did it, so a visit split between two barbers counts
+// for both. Amounts are line revenue net of discounts, before service charge and tax, over paid and partially
+// refunded sales without the refunded units; Count is visits.
 func (r DashboardRepository) TopStaff(ctx context.Context, ownerUserID int64, limit int) ([]DashboardItem, error) {
+	if _, err := salesCurrency(ctx, r.DB.Pool, ownerUserID, nil, nil, r.DefaultCurrency); err != nil {
+		return nil, err
+	}
 	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT stylist, COALESCE(SUM(amount),0) AS amount, COUNT(*) AS cnt
-		FROM transactions
-		WHERE stylist <> '' AND deleted_at IS NULL AND owner_user_id=$1
-		GROUP BY stylist
+		SELECT s.stylist, COALESCE(SUM((s.price*s.qty - s.discount) * s.kept / s.qty),0) AS amount,
+		       COALESCE(SUM(s.price * s.kept),0) AS gross,
+		       COALESCE(SUM(s.discount * s.kept / s.qty),0) AS discount, COUNT(DISTINCT s.transaction_id) AS cnt
+		FROM (
+			SELECT ti.transaction_id, ti.price, ti.qty, ti.qty - ti.refunded_qty AS kept, ti.discount,
+			       COALESCE(NULLIF(ti.stylist, ''), t.stylist) AS stylist
+			FROM transaction_items ti
+			JOIN transactions t ON t.id = ti.transaction_id
+			WHERE ti.deleted_at IS NULL AND ti.refunded_qty < ti.qty
+			  AND t.deleted_at IS NULL AND t.status IN ('paid','partially_refunded') AND t.owner_user_id=$1
+		) s
+		WHERE s.stylist <> ''
+		GROUP BY s.stylist
 		ORDER BY amount DESC
 		LIMIT $2
 	`, ownerUserID, limit)
@@ -98,7 +133,7 @@ func (r DashboardRepository) TopStaff(ctx context.Context, ownerUserID int64, li
 	var items []DashboardItem
 	for rows.Next() {
 		var it DashboardItem
-		if err := rows.Scan(&it.Name, &it.Amount, &it.Count); err != nil {
+		if err := rows.Scan(&it.Name, &it.Amount, &it.Gross, &it.Discount, &it.Count); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -107,11 +142,20 @@ func (r DashboardRepository) TopStaff(ctx context.Context, ownerUserID int64, li
 }
 
 func (r DashboardRepository) SalesSeries(ctx context.Context, ownerUserID int64, days int) ([]SalesPoint, error) {
-	start := time.Now().AddDate(0, 0, -days+1).Format("2006-01-02")
+	today, err := businessToday(ctx, r.DB.Pool, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	from := today.AddDate(0, 0, -days+1)
+	if _, err := salesCurrency(ctx, r.DB.Pool, ownerUserID, &from, nil, r.DefaultCurrency); err != nil {
+		return nil, err
+	}
+	start := from.Format("2006-01-02")
 	rows, err := r.DB.Pool.Query(ctx, `
 		SELECT transacted_date, COALESCE(SUM(amount),0) AS amount
 		FROM transactions
 		WHERE deleted_at IS NULL
+		  AND status NOT IN ('pending','void','failed')
 		  AND owner_user_id=$2
 		  AND transacted_date >= $1::date
 		GROUP BY transacted_date
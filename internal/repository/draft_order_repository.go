@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrDraftNotOpen is returned when a paid or cancelled draft is changed.
+var ErrDraftNotOpen = errors.New("draft is not open")
+
+type DraftOrderRepository struct {
+	DB *db.Postgres
+}
+
+const draftColumns = `id, status, customer_name, customer_id, customer_phone, stylist, stylist_id, shift_id, operator_name, note,
+		       created_by, transaction_id, paid_at, created_at, updated_at`
+
+const draftLineColumns = `id, draft_id, product_id, name, category, price, qty,
+		       discount_type, discount_value, discount_note, stylist_id, stylist, created_at`
+
+func scanDraft(row pgx.Row) (*domain.DraftOrder, error) {
+	var d domain.DraftOrder
+	var status string
+	if err := row.Scan(
+		&d.ID, &status, &d.CustomerName, &d.CustomerID, &d.CustomerPhone, &d.Stylist, &d.StylistID, &d.ShiftID, &d.OperatorName, &d.Note,
+		&d.CreatedBy, &d.TransactionID, &d.PaidAt, &d.CreatedAt, &d.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	d.Status = domain.DraftStatus(status)
+	return &d, nil
+}
+
+func scanDraftLine(row pgx.Row) (*domain.DraftOrderLine, error) {
+	var l domain.DraftOrderLine
+	if err := row.Scan(
+		&l.ID, &l.DraftID, &l.ProductID, &l.Name, &l.Category, &l.Price.Amount, &l.Qty,
+		&l.DiscountType, &l.DiscountValue, &l.DiscountNote, &l.StylistID, &l.Stylist, &l.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+type DraftLineInput struct {
+	ProductID     *int64
+	Name          string
+	Category      string
+	Price         int64
+	Qty           int
+	DiscountType  string
+	DiscountValue int64
+	DiscountNote  string
+	StylistID     *int64
+	Stylist       string
+}
+
+type CreateDraftInput struct {
+	CustomerName  string
+	CustomerID    *int64
+	CustomerPhone string
+	Stylist       string
+	StylistID     *int64
+	ShiftID       *string
+	OperatorName  string
+	Note          string
+	CreatedBy     *int64
+	Lines         []DraftLineInput
+}
+
+// UpdateDraftInput replaces the ticket header; lines are changed with AddLine and RemoveLine.
+type UpdateDraftInput struct {
+	CustomerName  string
+	CustomerID    *int64
+	CustomerPhone string
+	Stylist       string
+	StylistID     *int64
+	Note          string
+}
+
+func (r DraftOrderRepository) Create(ctx context.Context, ownerUserID int64, in CreateDraftInput) (*domain.DraftOrder, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := scanDraft(tx.QueryRow(ctx, `
+		INSERT INTO draft_orders (owner_user_id, customer_name, customer_id, customer_phone, stylist, stylist_id, shift_id, operator_name, note, created_by)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		RETURNING `+draftColumns,
+		ownerUserID, in.CustomerName, in.CustomerID, in.CustomerPhone, in.Stylist, in.StylistID, in.ShiftID, in.OperatorName, in.Note, in.CreatedBy))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range in.Lines {
+		line, err := insertDraftLine(ctx, tx, d.ID, l)
+		if err != nil {
+			return nil, err
+		}
+		d.Lines = append(d.Lines, *line)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func insertDraftLine(ctx context.Context, q pgxQuerier, draftID int64, l DraftLineInput) (*domain.DraftOrderLine, error) {
+	return scanDraftLine(q.QueryRow(ctx, `
+		INSERT INTO draft_order_lines (draft_id, product_id, name, category, price, qty, discount_type, discount_value, discount_note, stylist_id, stylist)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING `+draftLineColumns,
+		draftID, l.ProductID, l.Name, l.Category, l.Price, l.Qty, l.DiscountType, l.DiscountValue, l.DiscountNote, l.StylistID, l.Stylist))
+}
+
+// Get returns a draft of any status with its lines.
+func (r DraftOrderRepository) Get(ctx context.Context, ownerUserID, id int64) (*domain.DraftOrder, error) {
+	d, err := scanDraft(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+draftColumns+`
+		FROM draft_orders
+		WHERE id=$1 AND owner_user_id=$2
+	`, id, ownerUserID))
+	if err != nil {
+		return nil, err
+	}
+	drafts := []domain.DraftOrder{*d}
+	if err := r.loadLines(ctx, drafts); err != nil {
+		return nil, err
+	}
+	return &drafts[0], nil
+}
+
+// ListOpen returns the open tickets, oldest first; shiftID narrows them to one till.
+func (r DraftOrderRepository) ListOpen(ctx context.Context, ownerUserID int64, shiftID string) ([]domain.DraftOrder, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+draftColumns+`
+		FROM draft_orders
+		WHERE owner_user_id=$1 AND status='open' AND ($2 = '' OR shift_id = $2)
+		ORDER BY created_at ASC, id ASC
+	`, ownerUserID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var drafts []domain.DraftOrder
+	for rows.Next() {
+		d, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadLines(ctx, drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+func (r DraftOrderRepository) loadLines(ctx context.Context, drafts []domain.DraftOrder) error {
+	if len(drafts) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(drafts))
+	index := make(map[int64]int, len(drafts))
+	for i, d := range drafts {
+		ids = append(ids, d.ID)
+		index[d.ID] = i
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+draftLineColumns+`
+		FROM draft_order_lines
+		WHERE draft_id = ANY($1)
+		ORDER BY id ASC
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		l, err := scanDraftLine(rows)
+		if err != nil {
+			return err
+		}
+		i := index[l.DraftID]
+		drafts[i].Lines = append(drafts[i].Lines, *l)
+	}
+	return rows.Err()
+}
+
+func (r DraftOrderRepository) Update(ctx context.Context, ownerUserID, id int64, in UpdateDraftInput) (*domain.DraftOrder, error) {
+	tag, err := r.DB.Pool.Exec(ctx, `
+		UPDATE draft_orders
+		SET customer_name=$3, customer_id=$4, customer_phone=$5, stylist=$6, stylist_id=$7, note=$8, updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+	`, id, ownerUserID, in.CustomerName, in.CustomerID, in.CustomerPhone, in.Stylist, in.StylistID, in.Note)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, r.notOpen(ctx, ownerUserID, id)
+	}
+	return r.Get(ctx, ownerUserID, id)
+}
+
+func (r DraftOrderRepository) AddLine(ctx context.Context, ownerUserID, draftID int64, in DraftLineInput) (*domain.DraftOrderLine, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchOpenDraft(ctx, tx, ownerUserID, draftID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, r.notOpen(ctx, ownerUserID, draftID)
+		}
+		return nil, err
+	}
+	line, err := insertDraftLine(ctx, tx, draftID, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+func (r DraftOrderRepository) RemoveLine(ctx context.Context, ownerUserID, draftID, lineID int64) error {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchOpenDraft(ctx, tx, ownerUserID, draftID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return r.notOpen(ctx, ownerUserID, draftID)
+		}
+		return err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM draft_order_lines WHERE id=$1 AND draft_id=$2`, lineID, draftID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+// touchOpenDraft locks an open draft for the rest of tx and bumps its updated_at.
+func touchOpenDraft(ctx context.Context, tx pgx.Tx, ownerUserID, draftID int64) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE draft_orders SET updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+	`, draftID, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r DraftOrderRepository) Cancel(ctx context.Context, ownerUserID, id int64) error {
+	tag, err := r.DB.Pool.Exec(ctx, `
+		UPDATE draft_orders
+		SET status='cancelled', updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+	`, id, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return r.notOpen(ctx, ownerUserID, id)
+	}
+	return nil
+}
+
+// MarkPaidWithTx closes an open draft against the transaction recorded under clientRef in the same tx.
+func (r DraftOrderRepository) MarkPaidWithTx(ctx context.Context, tx pgx.Tx, ownerUserID, id int64, clientRef string) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE draft_orders
+		SET status='paid', paid_at=now(), updated_at=now(),
+		    transaction_id=(SELECT t.id FROM transactions t WHERE t.owner_user_id=$2 AND t.client_ref=$3)
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+	`, id, ownerUserID, clientRef)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDraftNotOpen
+	}
+	return nil
+}
+
+// notOpen tells a missing draft apart from one that was already paid or cancelled.
+func (r DraftOrderRepository) notOpen(ctx context.Context, ownerUserID, id int64) error {
+	var exists bool
+	if err := r.DB.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM draft_orders WHERE id=$1 AND owner_user_id=$2)
+	`, id, ownerUserID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrDraftNotOpen
+}
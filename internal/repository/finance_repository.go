@@ -15,28 +15,33 @@ type FinanceRepository struct {
 	DB *db.Postgres
 }
 
+const financeColumns = `id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, currency, created_at`
+
 type CreateFinanceInput struct {
-	Title    string
-	Amount   int64
-	Category string
-	Date     time.Time
-	Type     domain.FinanceEntryType
-	Note     string
+	Title           string
+	Amount          int64
+	Category        string
+	Date            time.Time
+	Type            domain.FinanceEntryType
+	Note            string
 	TransactionID   *int64
 	TransactionCode *string
-	Staff    *string
-	Service  *string
+	Staff           *string
+	Service         *string
+	// Currency of Amount; empty means the owner's settings currency.
+	Currency string
 }
 
 func (r FinanceRepository) Create(ctx context.Context, ownerUserID int64, in CreateFinanceInput) (*domain.FinanceEntry, error) {
 	var fe domain.FinanceEntry
 	var transactionID pgtype.Int8
 	err := r.DB.Pool.QueryRow(ctx, `
-		INSERT INTO finance_entries (owner_user_id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11, now())
-		RETURNING id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at
-	`, ownerUserID, in.Title, in.Amount, in.Category, in.Date.Format("2006-01-02"), string(in.Type), in.Note, in.TransactionID, in.TransactionCode, in.Staff, in.Service).Scan(
-		&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, (*string)(&fe.Type), &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.CreatedAt,
+		INSERT INTO finance_entries (owner_user_id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, currency, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,
+		        COALESCE(NULLIF($12, ''), (SELECT currency_code FROM settings WHERE owner_user_id=$1), 'IDR'), now())
+		RETURNING `+financeColumns+`
+	`, ownerUserID, in.Title, in.Amount, in.Category, in.Date.Format("2006-01-02"), string(in.Type), in.Note, in.TransactionID, in.TransactionCode, in.Staff, in.Service, in.Currency).Scan(
+		&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, (*string)(&fe.Type), &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.Amount.Currency, &fe.CreatedAt,
 	)
 	if transactionID.Valid {
 		v := transactionID.Int64
@@ -49,11 +54,12 @@ func (r FinanceRepository) CreateWithTx(ctx context.Context, tx pgx.Tx, ownerUse
 	var fe domain.FinanceEntry
 	var transactionID pgtype.Int8
 	err := tx.QueryRow(ctx, `
-		INSERT INTO finance_entries (owner_user_id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11, now())
-		RETURNING id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at
-	`, ownerUserID, in.Title, in.Amount, in.Category, in.Date.Format("2006-01-02"), string(in.Type), in.Note, in.TransactionID, in.TransactionCode, in.Staff, in.Service).Scan(
-		&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, (*string)(&fe.Type), &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.CreatedAt,
+		INSERT INTO finance_entries (owner_user_id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, currency, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,
+		        COALESCE(NULLIF($12, ''), (SELECT currency_code FROM settings WHERE owner_user_id=$1), 'IDR'), now())
+		RETURNING `+financeColumns+`
+	`, ownerUserID, in.Title, in.Amount, in.Category, in.Date.Format("2006-01-02"), string(in.Type), in.Note, in.TransactionID, in.TransactionCode, in.Staff, in.Service, in.Currency).Scan(
+		&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, (*string)(&fe.Type), &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.Amount.Currency, &fe.CreatedAt,
 	)
 	if transactionID.Valid {
 		v := transactionID.Int64
@@ -64,7 +70,7 @@ func (r FinanceRepository) CreateWithTx(ctx context.Context, tx pgx.Tx, ownerUse
 
 func (r FinanceRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]domain.FinanceEntry, error) {
 	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at
+		SELECT `+financeColumns+`
 		FROM finance_entries
 		WHERE deleted_at IS NULL AND owner_user_id=$1
 		ORDER BY entry_date DESC, id DESC
@@ -79,7 +85,7 @@ func (r FinanceRepository) List(ctx context.Context, ownerUserID int64, limit in
 		var fe domain.FinanceEntry
 		var t string
 		var transactionID pgtype.Int8
-		if err := rows.Scan(&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, &t, &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.CreatedAt); err != nil {
+		if err := rows.Scan(&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, &t, &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.Amount.Currency, &fe.CreatedAt); err != nil {
 			return nil, err
 		}
 		fe.Type = domain.FinanceEntryType(t)
@@ -94,7 +100,7 @@ func (r FinanceRepository) List(ctx context.Context, ownerUserID int64, limit in
 
 func (r FinanceRepository) ListFiltered(ctx context.Context, ownerUserID int64, startDate, endDate *time.Time) ([]domain.FinanceEntry, error) {
 	query := `
-		SELECT id, title, amount, category, entry_date, type, note, transaction_id, transaction_code, staff, service, created_at
+		SELECT ` + financeColumns + `
 		FROM finance_entries
 		WHERE deleted_at IS NULL AND owner_user_id = $1
 	`
@@ -120,7 +126,7 @@ func (r FinanceRepository) ListFiltered(ctx context.Context, ownerUserID int64,
 		var fe domain.FinanceEntry
 		var t string
 		var transactionID pgtype.Int8
-		if err := rows.Scan(&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, &t, &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.CreatedAt); err != nil {
+		if err := rows.Scan(&fe.ID, &fe.Title, &fe.Amount.Amount, &fe.Category, &fe.Date, &t, &fe.Note, &transactionID, &fe.TransactionCode, &fe.Staff, &fe.Service, &fe.Amount.Currency, &fe.CreatedAt); err != nil {
 			return nil, err
 		}
 		fe.Type = domain.FinanceEntryType(t)
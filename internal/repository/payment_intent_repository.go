@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPaymentIntentUnavailable is returned when an order references a payment intent that is neither paid nor
+// pending, does not match the tender, or already pays for another transaction.
+var ErrPaymentIntentUnavailable = errors.New("payment intent is not available")
+
+type PaymentIntentRepository struct {
+	DB              *db.Postgres
+	DefaultCurrency string // CURRENCY_CODE, for owners who have not saved settings
+}
+
+const paymentIntentColumns = `id, provider, provider_ref, method, amount, currency, status, qr_string, reference,
+		       refunded_amount, draft_id, transaction_id, created_by, expires_at, paid_at, created_at, updated_at`
+
+// scanPaymentIntent reads paymentIntentColumns; lead receives any columns selected before them.
+func scanPaymentIntent(row pgx.Row, lead ...any) (*domain.PaymentIntent, error) {
+	var p domain.PaymentIntent
+	var status string
+	if err := row.Scan(append(lead,
+		&p.ID, &p.Provider, &p.ProviderRef, &p.Method, &p.Amount.Amount, &p.Amount.Currency, &status, &p.QRString, &p.Reference,
+		&p.RefundedAmount.Amount, &p.DraftID, &p.TransactionID, &p.CreatedBy, &p.ExpiresAt, &p.PaidAt, &p.CreatedAt, &p.UpdatedAt,
+	)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	p.Status = domain.PaymentIntentStatus(status)
+	p.RefundedAmount.Currency = p.Amount.Currency
+	return &p, nil
+}
+
+type CreatePaymentIntentInput struct {
+	Provider  string
+	Method    string
+	Amount    int64
+	Currency  string // empty means the owner's settings currency
+	DraftID   *int64
+	CreatedBy *int64
+	ExpiresAt time.Time
+}
+
+// Create records a pending intent before the provider is asked for it, so its id can be the provider's
+// idempotency key.
+func (r PaymentIntentRepository) Create(ctx context.Context, ownerUserID int64, in CreatePaymentIntentInput) (*domain.PaymentIntent, error) {
+	if in.Currency == "" {
+		code, err := ownerCurrency(ctx, r.DB.Pool, ownerUserID, r.DefaultCurrency)
+		if err != nil {
+			return nil, err
+		}
+		in.Currency = code
+	}
+	id, err := newPaymentIntentID()
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentIntent(r.DB.Pool.QueryRow(ctx, `
+		INSERT INTO payment_intents (id, owner_user_id, provider, method, amount, currency, draft_id, created_by, expires_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		RETURNING `+paymentIntentColumns,
+		id, ownerUserID, in.Provider, in.Method, in.Amount, in.Currency, in.DraftID, in.CreatedBy, in.ExpiresAt))
+}
+
+func newPaymentIntentID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "pi_" + hex.EncodeToString(b), nil
+}
+
+func (r PaymentIntentRepository) Get(ctx context.Context, ownerUserID int64, id string) (*domain.PaymentIntent, error) {
+	return scanPaymentIntent(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+paymentIntentColumns+`
+		FROM payment_intents
+		WHERE id=$1 AND owner_user_id=$2
+	`, id, ownerUserID))
+}
+
+// PaymentIntentState is what the provider last reported; an empty ProviderRef, QRString or Reference keeps
+// the stored one.
+type PaymentIntentState struct {
+	ProviderRef    string
+	Status         domain.PaymentIntentStatus
+	QRString       string
+	Reference      string
+	RefundedAmount int64
+	PaidAt         *time.Time
+}
+
+// ApplyState stores the provider's view of an intent. Only pending intents change status, apart from a paid
+// one being refunded and money arriving after we gave up on it, so a late or replayed report cannot undo a
+// payment. paid_at is set once and kept.
+func (r PaymentIntentRepository) ApplyState(ctx context.Context, ownerUserID int64, id string, st PaymentIntentState) (*domain.PaymentIntent, error) {
+	return applyPaymentIntentState(ctx, r.DB.Pool, ownerUserID, id, st)
+}
+
+// Settle applies st like ApplyState and, in the same database transaction, moves a pending sale paid by the
+// intent to paid or failed. failed runs there too when the sale's payment failed.
+func (r PaymentIntentRepository) Settle(ctx context.Context, ownerUserID int64, id string, st PaymentIntentState, failed func(context.Context, pgx.Tx, int64, domain.Transaction) error) (*domain.PaymentIntent, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	intent, err := r.settleWithTx(ctx, tx, ownerUserID, id, st, failed)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+func (r PaymentIntentRepository) settleWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, id string, st PaymentIntentState, failed func(context.Context, pgx.Tx, int64, domain.Transaction) error) (*domain.PaymentIntent, error) {
+	intent, err := applyPaymentIntentState(ctx, tx, ownerUserID, id, st)
+	if err != nil {
+		return nil, err
+	}
+	if intent.TransactionID != nil {
+		if err := r.settleTransactionWithTx(ctx, tx, ownerUserID, *intent.TransactionID, intent.Status, failed); err != nil {
+			return nil, err
+		}
+	}
+	return intent, nil
+}
+
+// OwnedPaymentIntent is an intent listed across owners, for background work.
+type OwnedPaymentIntent struct {
+	OwnerUserID int64
+	domain.PaymentIntent
+}
+
+func scanOwnedPaymentIntents(rows pgx.Rows) ([]OwnedPaymentIntent, error) {
+	defer rows.Close()
+	var items []OwnedPaymentIntent
+	for rows.Next() {
+		var ownerUserID int64
+		p, err := scanPaymentIntent(rows, &ownerUserID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, OwnedPaymentIntent{OwnerUserID: ownerUserID, PaymentIntent: *p})
+	}
+	return items, rows.Err()
+}
+
+// ListPending returns pending intents to check, least recently updated first: those of provider, and any
+// other provider's that have expired and can no longer be asked about.
+func (r PaymentIntentRepository) ListPending(ctx context.Context, provider string, limit int) ([]OwnedPaymentIntent, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT owner_user_id, `+paymentIntentColumns+`
+		FROM payment_intents
+		WHERE status='pending' AND (provider=$1 OR expires_at < now())
+		ORDER BY updated_at ASC
+		LIMIT $2
+	`, provider, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnedPaymentIntents(rows)
+}
+
+// ClaimUnrecorded returns intents paid before paidBefore that no sale records (none attached, or the sale
+// failed before the money came in), each once: they are marked so the next call skips them.
+func (r PaymentIntentRepository) ClaimUnrecorded(ctx context.Context, paidBefore time.Time, limit int) ([]OwnedPaymentIntent, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		UPDATE payment_intents
+		SET unrecorded_notified_at=now()
+		WHERE id IN (
+			SELECT p.id
+			FROM payment_intents p
+			LEFT JOIN transactions t ON t.id = p.transaction_id
+			WHERE p.status='paid' AND p.unrecorded_notified_at IS NULL AND p.paid_at < $1
+			  AND (p.transaction_id IS NULL OR t.status='failed')
+			ORDER BY p.paid_at ASC
+			LIMIT $2
+			FOR UPDATE OF p SKIP LOCKED
+		)
+		RETURNING owner_user_id, `+paymentIntentColumns,
+		paidBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnedPaymentIntents(rows)
+}
+
+func applyPaymentIntentState(ctx context.Context, q pgxQuerier, ownerUserID int64, id string, st PaymentIntentState) (*domain.PaymentIntent, error) {
+	return scanPaymentIntent(q.QueryRow(ctx, `
+		UPDATE payment_intents
+		SET provider_ref = COALESCE(NULLIF($3, ''), provider_ref),
+		    status = CASE WHEN status = 'pending' OR (status = 'paid' AND $4 = 'refunded')
+		                       OR (status IN ('failed','cancelled','expired') AND $4 = 'paid') THEN $4 ELSE status END,
+		    qr_string = COALESCE(NULLIF($5, ''), qr_string),
+		    reference = COALESCE(NULLIF($6, ''), reference),
+		    refunded_amount = GREATEST(refunded_amount, $7),
+		    paid_at = COALESCE(paid_at, CASE WHEN status <> 'refunded' AND $4 = 'paid' THEN COALESCE($8, now()) END),
+		    updated_at = now()
+		WHERE id=$1 AND owner_user_id=$2
+		RETURNING `+paymentIntentColumns,
+		id, ownerUserID, st.ProviderRef, string(st.Status), st.QRString, st.Reference, st.RefundedAmount, st.PaidAt))
+}
+
+// attachPaymentIntentWithTx marks a paid or pending intent as the tender of a transaction and returns its
+// status. The method and amount must match the tender, and an intent pays for one transaction only.
+func attachPaymentIntentWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, id string, transactionID int64, method string, amount int64) (domain.PaymentIntentStatus, error) {
+	var status string
+	err := tx.QueryRow(ctx, `
+		UPDATE payment_intents
+		SET transaction_id=$3, updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status IN ('paid','pending') AND transaction_id IS NULL
+		  AND method=lower($4) AND amount=$5
+		RETURNING status
+	`, id, ownerUserID, transactionID, method, amount).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrPaymentIntentUnavailable
+		}
+		return "", err
+	}
+	return domain.PaymentIntentStatus(status), nil
+}
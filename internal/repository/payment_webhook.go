@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// WebhookOutcome is what became of one webhook delivery.
+type WebhookOutcome string
+
+const (
+	WebhookApplied   WebhookOutcome = "applied"
+	WebhookDuplicate WebhookOutcome = "duplicate"
+	WebhookUnmatched WebhookOutcome = "unmatched"
+)
+
+// PaymentWebhookEvent is an authentic delivery from a provider. The intent is found by ProviderRef, or by
+// IntentID when the provider echoes our id.
+type PaymentWebhookEvent struct {
+	Provider string
+	EventID  string
+	IntentID string
+	State    PaymentIntentState
+	Payload  []byte
+}
+
+// ApplyWebhook records ev once per provider event id and applies it to the matching intent in one database
+// transaction. A pending transaction paid by the intent becomes paid, or failed when the payment failed,
+// was cancelled or expired; failed then runs in the same transaction so the caller can put back what the
+// sale consumed. Events that match no intent are kept as unmatched for review.
+func (r PaymentIntentRepository) ApplyWebhook(ctx context.Context, ev PaymentWebhookEvent, failed func(context.Context, pgx.Tx, int64, domain.Transaction) error) (WebhookOutcome, *domain.PaymentIntent, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var eventRowID int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO payment_webhook_events (provider, event_id, provider_ref, intent_ref, status, payload)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (provider, event_id) DO NOTHING
+		RETURNING id
+	`, ev.Provider, ev.EventID, ev.State.ProviderRef, ev.IntentID, string(ev.State.Status), string(ev.Payload)).Scan(&eventRowID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return WebhookDuplicate, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var intentID string
+	var ownerUserID int64
+	err = tx.QueryRow(ctx, `
+		SELECT id, owner_user_id
+		FROM payment_intents
+		WHERE provider=$1 AND (($2 <> '' AND provider_ref=$2) OR id=$3)
+		ORDER BY (provider_ref=$2) DESC
+		LIMIT 1
+		FOR UPDATE
+	`, ev.Provider, ev.State.ProviderRef, ev.IntentID).Scan(&intentID, &ownerUserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := tx.Exec(ctx, `UPDATE payment_webhook_events SET outcome='unmatched' WHERE id=$1`, eventRowID); err != nil {
+			return "", nil, err
+		}
+		return WebhookUnmatched, nil, tx.Commit(ctx)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	intent, err := r.settleWithTx(ctx, tx, ownerUserID, intentID, ev.State, failed)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE payment_webhook_events SET outcome='applied', owner_user_id=$2, payment_intent_id=$3 WHERE id=$1
+	`, eventRowID, ownerUserID, intentID); err != nil {
+		return "", nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", nil, err
+	}
+	return WebhookApplied, intent, nil
+}
+
+// UnmatchedWebhook is a stored delivery that matched no payment intent. It has no owner.
+type UnmatchedWebhook struct {
+	ID          int64
+	Provider    string
+	EventID     string
+	ProviderRef string
+	IntentRef   string
+	Status      string
+	Payload     string
+	ReceivedAt  time.Time
+}
+
+// ListUnmatchedWebhooks returns webhook events that matched no intent, newest first. A non-zero beforeID
+// continues a previous page.
+func (r PaymentIntentRepository) ListUnmatchedWebhooks(ctx context.Context, beforeID int64, limit int) ([]UnmatchedWebhook, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT id, provider, event_id, provider_ref, intent_ref, status, payload, received_at
+		FROM payment_webhook_events
+		WHERE outcome='unmatched' AND ($1 = 0 OR id < $1)
+		ORDER BY id DESC
+		LIMIT $2
+	`, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UnmatchedWebhook
+	for rows.Next() {
+		var ev UnmatchedWebhook
+		if err := rows.Scan(&ev.ID, &ev.Provider, &ev.EventID, &ev.ProviderRef, &ev.IntentRef, &ev.Status, &ev.Payload, &ev.ReceivedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, ev)
+	}
+	return items, rows.Err()
+}
+
+// settleTransactionWithTx moves a pending transaction to paid or failed to follow its payment intent. A split
+// payment is paid once none of its intents is pending. Transactions that are no longer pending are left alone.
+func (r PaymentIntentRepository) settleTransactionWithTx(ctx context.Context, tx pgx.Tx, ownerUserID, transactionID int64, intentStatus domain.PaymentIntentStatus, failed func(context.Context, pgx.Tx, int64, domain.Transaction) error) error {
+	var status domain.TransactionStatus
+	switch intentStatus {
+	case domain.PaymentIntentPaid:
+		status = domain.TransactionPaid
+	case domain.PaymentIntentFailed, domain.PaymentIntentCancelled, domain.PaymentIntentExpired:
+		status = domain.TransactionFailed
+	default:
+		return nil
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE transactions SET status=$3, updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='pending'
+		  AND ($3 <> 'paid' OR NOT EXISTS (SELECT 1 FROM payment_intents WHERE transaction_id=$1 AND status='pending'))
+	`, transactionID, ownerUserID, string(status))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	// Pending sales do not count as visits; settled ones do, failed ones never will.
+	if err := refreshTransactionCustomerWithTx(ctx, tx, transactionID); err != nil {
+		return err
+	}
+	if status != domain.TransactionFailed || failed == nil {
+		return nil
+	}
+	t, err := TransactionRepository{DB: r.DB}.getDetail(ctx, tx, "id=$1", transactionID)
+	if err != nil {
+		return err
+	}
+	return failed(ctx, tx, ownerUserID, *t)
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type PrintJobRepository struct {
+	DB *db.Postgres
+}
+
+// printJobColumns leaves out the payload; only the sender needs it.
+const printJobColumns = `id, kind, reference, printer_host, printer_port, status, attempts, max_attempts,
+		       last_error, next_attempt_at, sent_at, created_at, updated_at`
+
+func scanPrintJob(row pgx.Row, extra ...any) (*domain.PrintJob, error) {
+	var j domain.PrintJob
+	var kind, status string
+	dest := []any{
+		&j.ID, &kind, &j.Reference, &j.PrinterHost, &j.PrinterPort, &status, &j.Attempts, &j.MaxAttempts,
+		&j.LastError, &j.NextAttemptAt, &j.SentAt, &j.CreatedAt, &j.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	j.Kind = domain.PrintJobKind(kind)
+	j.Status = domain.PrintJobStatus(status)
+	return &j, nil
+}
+
+type CreatePrintJobInput struct {
+	Kind        domain.PrintJobKind
+	Reference   string
+	PrinterHost string
+	PrinterPort int
+	Payload     []byte
+	MaxAttempts int
+}
+
+func (r PrintJobRepository) Create(ctx context.Context, ownerUserID int64, in CreatePrintJobInput) (*domain.PrintJob, error) {
+	if in.MaxAttempts <= 0 {
+		in.MaxAttempts = 5
+	}
+	return scanPrintJob(r.DB.Pool.QueryRow(ctx, `
+		INSERT INTO print_jobs (owner_user_id, kind, reference, printer_host, printer_port, payload, max_attempts)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		RETURNING `+printJobColumns,
+		ownerUserID, string(in.Kind), in.Reference, in.PrinterHost, in.PrinterPort, in.Payload, in.MaxAttempts))
+}
+
+func (r PrintJobRepository) List(ctx context.Context, ownerUserID int64, status domain.PrintJobStatus, limit int) ([]domain.PrintJob, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+printJobColumns+`
+		FROM print_jobs
+		WHERE owner_user_id=$1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, ownerUserID, string(status), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.PrintJob
+	for rows.Next() {
+		j, err := scanPrintJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+// Retry puts a failed job back in the queue with a fresh set of attempts.
+func (r PrintJobRepository) Retry(ctx context.Context, ownerUserID, id int64) (*domain.PrintJob, error) {
+	return scanPrintJob(r.DB.Pool.QueryRow(ctx, `
+		UPDATE print_jobs
+		SET status='queued', attempts=0, last_error='', next_attempt_at=now(), updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='failed'
+		RETURNING `+printJobColumns,
+		id, ownerUserID))
+}
+
+// ClaimDue takes up to limit queued jobs whose turn has come, counting an attempt for each and pushing
+// next_attempt_at out by lease so no other worker picks them up while they are being sent.
+// The jobs stay queued until MarkSent or MarkAttemptFailed.
+func (r PrintJobRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.PrintJob, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		UPDATE print_jobs
+		SET attempts = attempts + 1, next_attempt_at = now() + $2::interval, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM print_jobs
+			WHERE status='queued' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+printJobColumns+`, payload`,
+		limit, lease.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.PrintJob
+	for rows.Next() {
+		var payload []byte
+		j, err := scanPrintJob(rows, &payload)
+		if err != nil {
+			return nil, err
+		}
+		j.Payload = payload
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+func (r PrintJobRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+		UPDATE print_jobs SET status='sent', last_error='', sent_at=now(), updated_at=now() WHERE id=$1
+	`, id)
+	return err
+}
+
+// MarkAttemptFailed records a failed send: the job is retried at retryAt, or marked failed once it has used
+// all its attempts.
+func (r PrintJobRepository) MarkAttemptFailed(ctx context.Context, id int64, sendErr string, retryAt time.Time) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+		UPDATE print_jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'queued' END,
+		    last_error=$2,
+		    next_attempt_at=$3,
+		    updated_at=now()
+		WHERE id=$1
+	`, id, sendErr, retryAt)
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"barberpos-backend/internal/db"
 	"barberpos-backend/internal/domain"
@@ -54,6 +55,65 @@ func (r ProductRepository) GetByID(ctx context.Context, ownerUserID int64, id in
 	return &p, nil
 }
 
+// GetByIDs loads the owner's live products for the given ids, keyed by id. Missing ids are simply absent.
+func (r ProductRepository) GetByIDs(ctx context.Context, ownerUserID int64, ids []int64) (map[int64]domain.Product, error) {
+	out := make(map[int64]domain.Product, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT id, name, category, price, image, track_stock, stock, min_stock
+		FROM products
+		WHERE id = ANY($1) AND owner_user_id=$2 AND deleted_at IS NULL
+	`, ids, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p domain.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Price.Amount, &p.Image, &p.TrackStock, &p.Stock, &p.MinStock); err != nil {
+			return nil, err
+		}
+		out[p.ID] = p
+	}
+	return out, rows.Err()
+}
+
+// GetByIDsAt is GetByIDs as the catalog stood at the given time: each product carries the price it had then,
+// and products deleted since are still found.
+func (r ProductRepository) GetByIDsAt(ctx context.Context, ownerUserID int64, ids []int64, at time.Time) (map[int64]domain.Product, error) {
+	out := make(map[int64]domain.Product, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT p.id, p.name, p.category,
+			COALESCE((
+				SELECT pp.price FROM product_prices pp
+				WHERE pp.product_id=p.id AND pp.valid_from <= $3
+				ORDER BY pp.valid_from DESC, pp.id DESC LIMIT 1
+			), p.price),
+			p.image, p.track_stock, p.stock, p.min_stock
+		FROM products p
+		WHERE p.id = ANY($1) AND p.owner_user_id=$2 AND (p.deleted_at IS NULL OR p.deleted_at > $3)
+	`, ids, ownerUserID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p domain.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Price.Amount, &p.Image, &p.TrackStock, &p.Stock, &p.MinStock); err != nil {
+			return nil, err
+		}
+		out[p.ID] = p
+	}
+	return out, rows.Err()
+}
+
 func (r ProductRepository) Save(ctx context.Context, ownerUserID int64, p domain.Product) (*domain.Product, error) {
 	if p.ID == 0 {
 		err := r.DB.Pool.QueryRow(ctx, `
@@ -66,7 +126,17 @@ func (r ProductRepository) Save(ctx context.Context, ownerUserID int64, p domain
 			return nil, err
 		}
 	} else {
-		err := r.DB.Pool.QueryRow(ctx, `
+		// Before the first price change, record the price the product had since it was created.
+		_, err := r.DB.Pool.Exec(ctx, `
+			INSERT INTO product_prices (product_id, price, valid_from)
+			SELECT id, price, created_at FROM products
+			WHERE id=$1 AND owner_user_id=$2 AND price<>$3
+				AND NOT EXISTS (SELECT 1 FROM product_prices WHERE product_id=$1)
+		`, p.ID, ownerUserID, p.Price.Amount)
+		if err != nil {
+			return nil, err
+		}
+		err = r.DB.Pool.QueryRow(ctx, `
 			UPDATE products
 			SET name=$1,
 				category=$2,
@@ -87,6 +157,17 @@ func (r ProductRepository) Save(ctx context.Context, ownerUserID int64, p domain
 			}
 			return nil, err
 		}
+		_, err = r.DB.Pool.Exec(ctx, `
+			INSERT INTO product_prices (product_id, price, valid_from)
+			SELECT $1, $2, now()
+			WHERE EXISTS (SELECT 1 FROM product_prices WHERE product_id=$1)
+				AND $2 IS DISTINCT FROM (
+					SELECT price FROM product_prices WHERE product_id=$1 ORDER BY valid_from DESC, id DESC LIMIT 1
+				)
+		`, p.ID, p.Price.Amount)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Keep stocks table in sync for tracked products (used by /stock endpoints).
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestProductGetByIDsAt(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	products := ProductRepository{DB: pg}
+	owner := testOwner(t, pg)
+
+	// Times come from the database clock, which stamps the price changes.
+	dbNow := func() time.Time {
+		t.Helper()
+		time.Sleep(5 * time.Millisecond)
+		var at time.Time
+		if err := pg.Pool.QueryRow(ctx, `SELECT clock_timestamp()`).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+	save := func(p domain.Product) domain.Product {
+		t.Helper()
+		saved, err := products.Save(ctx, owner, p)
+		if err != nil {
+			t.Fatalf("save %s: %v", p.Name, err)
+		}
+		return *saved
+	}
+	priceAt := func(id int64, at time.Time) (int64, bool) {
+		t.Helper()
+		got, err := products.GetByIDsAt(ctx, owner, []int64{id}, at)
+		if err != nil {
+			t.Fatalf("GetByIDsAt: %v", err)
+		}
+		p, ok := got[id]
+		return p.Price.Amount, ok
+	}
+
+	cut := save(domain.Product{Name: "Haircut", Category: "service", Price: domain.Money{Amount: 50000}})
+	shave := save(domain.Product{Name: "Shave", Category: "service", Price: domain.Money{Amount: 30000}})
+	beforeRaise := dbNow()
+
+	cut.Price.Amount = 60000
+	cut = save(cut)
+	afterRaise := dbNow()
+
+	cut.Name = "Haircut & wash" // not a price change
+	cut = save(cut)
+	cut.Price.Amount = 65000
+	cut = save(cut)
+	afterSecondRaise := dbNow()
+
+	tests := []struct {
+		name string
+		id   int64
+		at   time.Time
+		want int64
+	}{
+		{"before the first change", cut.ID, beforeRaise, 50000},
+		{"between changes", cut.ID, afterRaise, 60000},
+		{"after the last change", cut.ID, afterSecondRaise, 65000},
+		{"never changed", shave.ID, beforeRaise, 30000},
+	}
+	for _, tt := range tests {
+		got, ok := priceAt(tt.id, tt.at)
+		if !ok || got != tt.want {
+			t.Errorf("%s: price = %d (found %v), want %d", tt.name, got, ok, tt.want)
+		}
+	}
+
+	if err := products.Delete(ctx, owner, shave.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := priceAt(shave.ID, beforeRaise); !ok {
+		t.Error("product deleted after the order was rung up is not found")
+	}
+	if _, ok := priceAt(shave.ID, dbNow()); ok {
+		t.Error("product deleted before the order was rung up is found")
+	}
+	if got, _ := products.GetByIDs(ctx, owner, []int64{cut.ID}); got[cut.ID].Price.Amount != 65000 {
+		t.Errorf("GetByIDs price = %d, want the current 65000", got[cut.ID].Price.Amount)
+	}
+}
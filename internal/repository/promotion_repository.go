@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPromotionExhausted is returned when a promotion hits its usage limit while an order is being recorded.
+var ErrPromotionExhausted = errors.New("promotion usage limit reached")
+
+type PromotionRepository struct {
+	DB *db.Postgres
+}
+
+const promotionColumns = `id, code, name, kind, scope, value, product_id, min_spend, max_discount,
+		       starts_at, ends_at, usage_limit, used_count, active, created_at, updated_at`
+
+func scanPromotion(row pgx.Row) (*domain.Promotion, error) {
+	var p domain.Promotion
+	var kind, scope string
+	if err := row.Scan(
+		&p.ID, &p.Code, &p.Name, &kind, &scope, &p.Value, &p.ProductID, &p.MinSpend.Amount, &p.MaxDiscount,
+		&p.StartsAt, &p.EndsAt, &p.UsageLimit, &p.UsedCount, &p.Active, &p.CreatedAt, &p.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	p.Kind = domain.DiscountKind(kind)
+	p.Scope = domain.DiscountScope(scope)
+	return &p, nil
+}
+
+func (r PromotionRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]domain.Promotion, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE deleted_at IS NULL AND owner_user_id=$1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, ownerUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *p)
+	}
+	return items, rows.Err()
+}
+
+// GetByCodes returns the owner's live promotions for the given (already normalized) codes, keyed by code.
+func (r PromotionRepository) GetByCodes(ctx context.Context, ownerUserID int64, codes []string) (map[string]domain.Promotion, error) {
+	out := make(map[string]domain.Promotion, len(codes))
+	if len(codes) == 0 {
+		return out, nil
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE deleted_at IS NULL AND owner_user_id=$1 AND code = ANY($2)
+	`, ownerUserID, codes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[p.Code] = *p
+	}
+	return out, rows.Err()
+}
+
+func (r PromotionRepository) Save(ctx context.Context, ownerUserID int64, p domain.Promotion) (*domain.Promotion, error) {
+	if p.ID == 0 {
+		return scanPromotion(r.DB.Pool.QueryRow(ctx, `
+			INSERT INTO promotions (owner_user_id, code, name, kind, scope, value, product_id, min_spend, max_discount,
+			                        starts_at, ends_at, usage_limit, active, created_at, updated_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13, now(), now())
+			RETURNING `+promotionColumns,
+			ownerUserID, p.Code, p.Name, string(p.Kind), string(p.Scope), p.Value, p.ProductID, p.MinSpend.Amount, p.MaxDiscount,
+			p.StartsAt, p.EndsAt, p.UsageLimit, p.Active))
+	}
+	return scanPromotion(r.DB.Pool.QueryRow(ctx, `
+		UPDATE promotions
+		SET code=$1,
+			name=$2,
+			kind=$3,
+			scope=$4,
+			value=$5,
+			product_id=$6,
+			min_spend=$7,
+			max_discount=$8,
+			starts_at=$9,
+			ends_at=$10,
+			usage_limit=$11,
+			active=$12,
+			updated_at=now()
+		WHERE id=$13 AND owner_user_id=$14 AND deleted_at IS NULL
+		RETURNING `+promotionColumns,
+		p.Code, p.Name, string(p.Kind), string(p.Scope), p.Value, p.ProductID, p.MinSpend.Amount, p.MaxDiscount,
+		p.StartsAt, p.EndsAt, p.UsageLimit, p.Active, p.ID, ownerUserID))
+}
+
+func (r PromotionRepository) Delete(ctx context.Context, ownerUserID int64, id int64) error {
+	ct, err := r.DB.Pool.Exec(ctx, `UPDATE promotions SET deleted_at=now(), updated_at=now() WHERE id=$1 AND owner_user_id=$2 AND deleted_at IS NULL`, id, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RedeemWithTx counts one use of a promotion, failing if its usage limit is already reached.
+func (r PromotionRepository) RedeemWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, id int64) error {
+	ct, err := tx.Exec(ctx, `
+		UPDATE promotions
+		SET used_count = used_count + 1, updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND deleted_at IS NULL
+		  AND (usage_limit IS NULL OR used_count < usage_limit)
+	`, id, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrPromotionExhausted
+	}
+	return nil
+}
+
+// ReleaseWithTx gives back one use of a promotion, e.g. when the sale that redeemed it is voided.
+func (r PromotionRepository) ReleaseWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, id int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE promotions
+		SET used_count = GREATEST(used_count - 1, 0), updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2
+	`, id, ownerUserID)
+	return err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestPromotionRedeemAndRelease(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	promotions := PromotionRepository{DB: pg}
+	shop, other := testOwner(t, pg), testOwner(t, pg)
+
+	limit := 2
+	p, err := promotions.Save(ctx, shop, domain.Promotion{
+		Code: "HEMAT", Name: "Hemat", Kind: domain.DiscountFixed, Scope: domain.DiscountScopeOrder,
+		Value: 5000, UsageLimit: &limit, Active: true,
+	})
+	if err != nil {
+		t.Fatalf("save promotion: %v", err)
+	}
+
+	redeem := func(owner int64) error {
+		tx, err := pg.Pool.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+		if err := promotions.RedeemWithTx(ctx, tx, owner, p.ID); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	used := func() int {
+		t.Helper()
+		found, err := promotions.GetByCodes(ctx, shop, []string{"HEMAT"})
+		if err != nil {
+			t.Fatalf("get promotion: %v", err)
+		}
+		return found["HEMAT"].UsedCount
+	}
+
+	if err := redeem(other); !errors.Is(err, ErrPromotionExhausted) {
+		t.Errorf("another shop redeeming: err = %v, want ErrPromotionExhausted", err)
+	}
+	for i := 0; i < limit; i++ {
+		if err := redeem(shop); err != nil {
+			t.Fatalf("redeem %d: %v", i+1, err)
+		}
+	}
+	if err := redeem(shop); !errors.Is(err, ErrPromotionExhausted) {
+		t.Errorf("redeem past the limit: err = %v, want ErrPromotionExhausted", err)
+	}
+	if got := used(); got != limit {
+		t.Errorf("used count = %d, want %d", got, limit)
+	}
+
+	tx, err := pg.Pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.ReleaseWithTx(ctx, tx, shop, p.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := redeem(shop); err != nil {
+		t.Errorf("redeem after a release: %v", err)
+	}
+
+	if found, err := promotions.GetByCodes(ctx, other, []string{"HEMAT"}); err != nil || len(found) != 0 {
+		t.Errorf("another shop looking up the code = %v (%v), want nothing", found, err)
+	}
+}
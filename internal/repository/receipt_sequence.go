@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
+	"github.com/jackc/pgx/v5"
+)
+
+// receiptNumbering is the owner's receipt number format, read from settings.
+type receiptNumbering struct {
+	Prefix     string
+	DateFormat domain.ReceiptDateFormat
+	Reset      domain.ReceiptReset
+	Digits     int
+}
+
+func loadReceiptNumberingWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64) (receiptNumbering, error) {
+	def := defaultSettings(money.DefaultCode)
+	n := receiptNumbering{Prefix: def.ReceiptPrefix, DateFormat: def.ReceiptDateFormat, Reset: def.ReceiptReset, Digits: def.ReceiptDigits}
+	err := tx.QueryRow(ctx, `
+		SELECT receipt_prefix, receipt_date_format, receipt_reset, receipt_digits
+		FROM settings
+		WHERE owner_user_id=$1
+	`, ownerUserID).Scan(&n.Prefix, &n.DateFormat, &n.Reset, &n.Digits)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return n, err
+	}
+	return n, nil
+}
+
+// allocateReceiptNumberWithTx takes the next number in the owner's sequence for the current reset period.
+// The upsert locks the counter row until tx ends, so concurrent creates queue up and a rollback frees the number.
+func allocateReceiptNumberWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, now time.Time) (string, error) {
+	n, err := loadReceiptNumberingWithTx(ctx, tx, ownerUserID)
+	if err != nil {
+		return "", err
+	}
+	period := now.Format("2006")
+	if n.Reset == domain.ReceiptResetDaily {
+		period = now.Format("2006-01-02")
+	}
+	var seq int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO receipt_sequences (owner_user_id, period, last_value, updated_at)
+		VALUES ($1, $2, 1, now())
+		ON CONFLICT (owner_user_id, period) DO UPDATE
+		SET last_value = receipt_sequences.last_value + 1, updated_at = now()
+		RETURNING last_value
+	`, ownerUserID, period).Scan(&seq)
+	if err != nil {
+		return "", err
+	}
+	return formatReceiptNumber(n, now, seq), nil
+}
+
+func formatReceiptNumber(n receiptNumbering, now time.Time, seq int64) string {
+	parts := make([]string, 0, 3)
+	if p := strings.TrimSpace(n.Prefix); p != "" {
+		parts = append(parts, p)
+	}
+	switch n.DateFormat {
+	case domain.ReceiptDateYear:
+		parts = append(parts, now.Format("2006"))
+	case domain.ReceiptDateMonth:
+		parts = append(parts, now.Format("200601"))
+	case domain.ReceiptDateDay:
+		parts = append(parts, now.Format("20060102"))
+	}
+	digits := n.Digits
+	if digits <= 0 {
+		digits = 4
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", digits, seq))
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestFormatReceiptNumber(t *testing.T) {
+	now := time.Date(2026, time.March, 7, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		n    receiptNumbering
+		seq  int64
+		want string
+	}{
+		{"prefix and day", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateDay, Digits: 4}, 12, "INV-20260307-0012"},
+		{"month", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateMonth, Digits: 4}, 1, "INV-202603-0001"},
+		{"year", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateYear, Digits: 6}, 42, "INV-2026-000042"},
+		{"no date", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateNone, Digits: 4}, 7, "INV-0007"},
+		{"no prefix", receiptNumbering{DateFormat: domain.ReceiptDateDay, Digits: 4}, 7, "20260307-0007"},
+		{"blank prefix", receiptNumbering{Prefix: "  ", DateFormat: domain.ReceiptDateNone, Digits: 3}, 7, "007"},
+		{"prefix trimmed", receiptNumbering{Prefix: " BK ", DateFormat: domain.ReceiptDateNone, Digits: 3}, 7, "BK-007"},
+		{"default digits", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateNone}, 7, "INV-0007"},
+		{"overflows digits", receiptNumbering{Prefix: "INV", DateFormat: domain.ReceiptDateNone, Digits: 3}, 12345, "INV-12345"},
+	}
+	for _, tt := range tests {
+		if got := formatReceiptNumber(tt.n, now, tt.seq); got != tt.want {
+			t.Errorf("%s: formatReceiptNumber = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -7,14 +7,21 @@ import (
 
 	"barberpos-backend/internal/db"
 	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
 	"github.com/jackc/pgx/v5"
 )
 
 type SettingsRepository struct {
 	DB *db.Postgres
+	// DefaultCurrency is the currency new owners start with (CURRENCY_CODE); empty means IDR.
+	DefaultCurrency string
 }
 
-func defaultSettings() domain.Settings {
+func defaultSettings(currency string) domain.Settings {
+	c, err := money.Lookup(currency)
+	if err != nil {
+		c, _ = money.Lookup(money.DefaultCode)
+	}
 	return domain.Settings{
 		BusinessName:         "BarberPOS",
 		BusinessAddress:      "",
@@ -31,41 +38,128 @@ func defaultSettings() domain.Settings {
 		Notifications:        true,
 		TrackStock:           true,
 		RoundingPrice:        false,
+		RoundingUnit:         c.CashUnits[0],
+		RoundingMode:         domain.RoundingNearest,
 		AutoBackup:           false,
 		CashierPin:           false,
-		CurrencyCode:         "IDR",
+		CurrencyCode:         c.Code,
+		TaxRate:              0,
+		TaxInclusive:         false,
+		ServiceChargeRate:    0,
+		ReceiptPrefix:        "INV",
+		ReceiptDateFormat:    domain.ReceiptDateDay,
+		ReceiptReset:         domain.ReceiptResetDaily,
+		ReceiptDigits:        4,
+		VoidReasons:          []string{"wrong_item", "wrong_payment", "duplicate", "customer_cancelled", "other"},
+		Timezone:             DefaultTimezone,
+		ShiftPolicy:          domain.ShiftPolicyOff,
+		AllowCustomItems:     false,
+		AllowManualDiscounts: false,
 	}
 }
 
-func (r SettingsRepository) Get(ctx context.Context, ownerUserID int64) (*domain.Settings, error) {
-	row := r.DB.Pool.QueryRow(ctx, `
-		SELECT business_name, business_address, business_phone, receipt_footer, default_payment_method,
+// DefaultTimezone is the business timezone of owners who have not picked one (WIB).
+const DefaultTimezone = "Asia/Jakarta"
+
+// LoadLocation returns the named timezone, falling back to DefaultTimezone when it is empty or unknown.
+func LoadLocation(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// ownerLocation returns the owner's business timezone without creating a settings row.
+func ownerLocation(ctx context.Context, q pgxQuerier, ownerUserID int64) (*time.Location, error) {
+	var name string
+	err := q.QueryRow(ctx, `SELECT timezone FROM settings WHERE owner_user_id=$1`, ownerUserID).Scan(&name)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return LoadLocation(name), nil
+}
+
+// businessToday is the owner's current date, as midnight in their timezone.
+func businessToday(ctx context.Context, q pgxQuerier, ownerUserID int64) (time.Time, error) {
+	loc, err := ownerLocation(ctx, q, ownerUserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// HasLedger reports whether the owner has recorded any sale or finance entry, after which
+// the currency can no longer change without mixing amounts.
+func (r SettingsRepository) HasLedger(ctx context.Context, ownerUserID int64) (bool, error) {
+	var ok bool
+	err := r.DB.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM transactions WHERE owner_user_id=$1 AND deleted_at IS NULL)
+		    OR EXISTS (SELECT 1 FROM finance_entries WHERE owner_user_id=$1 AND deleted_at IS NULL)
+	`, ownerUserID).Scan(&ok)
+	return ok, err
+}
+
+// Location is the owner's business timezone.
+func (r SettingsRepository) Location(ctx context.Context, ownerUserID int64) (*time.Location, error) {
+	return ownerLocation(ctx, r.DB.Pool, ownerUserID)
+}
+
+// Today is the owner's current business date, as midnight in their timezone.
+func (r SettingsRepository) Today(ctx context.Context, ownerUserID int64) (time.Time, error) {
+	return businessToday(ctx, r.DB.Pool, ownerUserID)
+}
+
+const settingsColumns = `business_name, business_address, business_phone, receipt_footer, default_payment_method,
 		       printer_name, printer_type, printer_host, printer_port, printer_mac,
-		       paper_size, auto_print, notifications, track_stock, rounding_price, auto_backup, cashier_pin, currency_code, updated_at
-		FROM settings
-		WHERE owner_user_id=$1
-	`, ownerUserID)
+		       paper_size, auto_print, notifications, track_stock, rounding_price, rounding_unit, rounding_mode, auto_backup, cashier_pin, currency_code,
+		       tax_rate, tax_inclusive, service_charge_rate,
+		       receipt_prefix, receipt_date_format, receipt_reset, receipt_digits, void_reasons, timezone, shift_policy, allow_custom_items, allow_manual_discounts, updated_at`
+
+func scanSettings(row pgx.Row) (*domain.Settings, error) {
 	var s domain.Settings
 	if err := row.Scan(
 		&s.BusinessName, &s.BusinessAddress, &s.BusinessPhone, &s.ReceiptFooter, &s.DefaultPaymentMethod,
 		&s.PrinterName, &s.PrinterType, &s.PrinterHost, &s.PrinterPort, &s.PrinterMac,
-		&s.PaperSize, &s.AutoPrint, &s.Notifications, &s.TrackStock, &s.RoundingPrice, &s.AutoBackup, &s.CashierPin, &s.CurrencyCode, &s.UpdatedAt,
+		&s.PaperSize, &s.AutoPrint, &s.Notifications, &s.TrackStock, &s.RoundingPrice, &s.RoundingUnit, &s.RoundingMode, &s.AutoBackup, &s.CashierPin, &s.CurrencyCode,
+		&s.TaxRate, &s.TaxInclusive, &s.ServiceChargeRate,
+		&s.ReceiptPrefix, &s.ReceiptDateFormat, &s.ReceiptReset, &s.ReceiptDigits, &s.VoidReasons, &s.Timezone, &s.ShiftPolicy, &s.AllowCustomItems, &s.AllowManualDiscounts, &s.UpdatedAt,
 	); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r SettingsRepository) Get(ctx context.Context, ownerUserID int64) (*domain.Settings, error) {
+	s, err := scanSettings(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+settingsColumns+`
+		FROM settings
+		WHERE owner_user_id=$1
+	`, ownerUserID))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			def := defaultSettings()
+			def := defaultSettings(r.DefaultCurrency)
 			return r.Save(ctx, ownerUserID, def)
 		}
 		return nil, err
 	}
-	return &s, nil
+	return s, nil
 }
 
 func (r SettingsRepository) Save(ctx context.Context, ownerUserID int64, s domain.Settings) (*domain.Settings, error) {
-	err := r.DB.Pool.QueryRow(ctx, `
+	return scanSettings(r.DB.Pool.QueryRow(ctx, `
 		INSERT INTO settings (owner_user_id, business_name, business_address, business_phone, receipt_footer, default_payment_method,
 		                      printer_name, printer_type, printer_host, printer_port, printer_mac,
-		                      paper_size, auto_print, notifications, track_stock, rounding_price, auto_backup, cashier_pin, currency_code, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19, now())
+		                      paper_size, auto_print, notifications, track_stock, rounding_price, rounding_unit, rounding_mode, auto_backup, cashier_pin, currency_code,
+		                      tax_rate, tax_inclusive, service_charge_rate,
+		                      receipt_prefix, receipt_date_format, receipt_reset, receipt_digits, void_reasons, timezone, shift_policy, allow_custom_items, allow_manual_discounts, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33, now())
 		ON CONFLICT (owner_user_id) DO UPDATE SET
 			business_name=EXCLUDED.business_name,
 			business_address=EXCLUDED.business_address,
@@ -82,24 +176,30 @@ func (r SettingsRepository) Save(ctx context.Context, ownerUserID int64, s domai
 			notifications=EXCLUDED.notifications,
 			track_stock=EXCLUDED.track_stock,
 			rounding_price=EXCLUDED.rounding_price,
+			rounding_unit=EXCLUDED.rounding_unit,
+			rounding_mode=EXCLUDED.rounding_mode,
 			auto_backup=EXCLUDED.auto_backup,
 			cashier_pin=EXCLUDED.cashier_pin,
 			currency_code=EXCLUDED.currency_code,
+			tax_rate=EXCLUDED.tax_rate,
+			tax_inclusive=EXCLUDED.tax_inclusive,
+			service_charge_rate=EXCLUDED.service_charge_rate,
+			receipt_prefix=EXCLUDED.receipt_prefix,
+			receipt_date_format=EXCLUDED.receipt_date_format,
+			receipt_reset=EXCLUDED.receipt_reset,
+			receipt_digits=EXCLUDED.receipt_digits,
+			void_reasons=EXCLUDED.void_reasons,
+			timezone=EXCLUDED.timezone,
+			shift_policy=EXCLUDED.shift_policy,
+			allow_custom_items=EXCLUDED.allow_custom_items,
+			allow_manual_discounts=EXCLUDED.allow_manual_discounts,
 			updated_at=now()
-		RETURNING business_name, business_address, business_phone, receipt_footer, default_payment_method,
-		          printer_name, printer_type, printer_host, printer_port, printer_mac,
-		          paper_size, auto_print, notifications, track_stock, rounding_price, auto_backup, cashier_pin, currency_code, updated_at
-	`, ownerUserID, s.BusinessName, s.BusinessAddress, s.BusinessPhone, s.ReceiptFooter, s.DefaultPaymentMethod,
+		RETURNING `+settingsColumns,
+		ownerUserID, s.BusinessName, s.BusinessAddress, s.BusinessPhone, s.ReceiptFooter, s.DefaultPaymentMethod,
 		s.PrinterName, s.PrinterType, s.PrinterHost, s.PrinterPort, s.PrinterMac,
-		s.PaperSize, s.AutoPrint, s.Notifications, s.TrackStock, s.RoundingPrice, s.AutoBackup, s.CashierPin, s.CurrencyCode).Scan(
-		&s.BusinessName, &s.BusinessAddress, &s.BusinessPhone, &s.ReceiptFooter, &s.DefaultPaymentMethod,
-		&s.PrinterName, &s.PrinterType, &s.PrinterHost, &s.PrinterPort, &s.PrinterMac,
-		&s.PaperSize, &s.AutoPrint, &s.Notifications, &s.TrackStock, &s.RoundingPrice, &s.AutoBackup, &s.CashierPin, &s.CurrencyCode, &s.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &s, nil
+		s.PaperSize, s.AutoPrint, s.Notifications, s.TrackStock, s.RoundingPrice, s.RoundingUnit, s.RoundingMode, s.AutoBackup, s.CashierPin, s.CurrencyCode,
+		s.TaxRate, s.TaxInclusive, s.ServiceChargeRate,
+		s.ReceiptPrefix, s.ReceiptDateFormat, s.ReceiptReset, s.ReceiptDigits, s.VoidReasons, s.Timezone, s.ShiftPolicy, s.AllowCustomItems, s.AllowManualDiscounts))
 }
 
 func (r SettingsRepository) HasQrisImage(ctx context.Context, ownerUserID int64) (bool, error) {
@@ -118,12 +218,14 @@ func (r SettingsRepository) HasQrisImage(ctx context.Context, ownerUserID int64)
 	return ok, nil
 }
 
-func (r SettingsRepository) SetQrisImage(ctx context.Context, ownerUserID int64, bytes []byte, mime string) error {
+// SetQrisImage stores an uploaded QRIS image together with the payload decoded from it.
+func (r SettingsRepository) SetQrisImage(ctx context.Context, ownerUserID int64, bytes []byte, mime, payload string) error {
 	_, err := r.DB.Pool.Exec(ctx, `
 		UPDATE settings
-		SET qris_image=$2, qris_image_mime=$3, qris_image_updated_at=now(), updated_at=now()
+		SET qris_image=$2, qris_image_mime=$3, qris_image_updated_at=now(),
+		    qris_payload=$4, qris_payload_updated_at=now(), updated_at=now()
 		WHERE owner_user_id=$1
-	`, ownerUserID, bytes, mime)
+	`, ownerUserID, bytes, mime, payload)
 	return err
 }
 
@@ -150,3 +252,85 @@ func (r SettingsRepository) GetQrisImage(ctx context.Context, ownerUserID int64)
 	}
 	return bytes, mime, updatedAt, nil
 }
+
+// QrisPayload returns the merchant's static QRIS payload, or "" when none is registered.
+func (r SettingsRepository) QrisPayload(ctx context.Context, ownerUserID int64) (string, error) {
+	var payload string
+	err := r.DB.Pool.QueryRow(ctx, `
+		SELECT qris_payload
+		FROM settings
+		WHERE owner_user_id=$1
+	`, ownerUserID).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return payload, err
+}
+
+// SetQrisPayload stores a static QRIS payload the caller has already validated; "" removes it.
+func (r SettingsRepository) SetQrisPayload(ctx context.Context, ownerUserID int64, payload string) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+		UPDATE settings
+		SET qris_payload=$2, qris_payload_updated_at=CASE WHEN $2='' THEN NULL ELSE now() END, updated_at=now()
+		WHERE owner_user_id=$1
+	`, ownerUserID, payload)
+	return err
+}
+
+// ManagerPinHash returns the bcrypt hash of the PIN managers use to approve staff actions, or nil when none is set.
+func (r SettingsRepository) ManagerPinHash(ctx context.Context, ownerUserID int64) (*string, error) {
+	var hash *string
+	err := r.DB.Pool.QueryRow(ctx, `
+		SELECT manager_pin_hash
+		FROM settings
+		WHERE owner_user_id=$1
+	`, ownerUserID).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return hash, nil
+}
+
+func (r SettingsRepository) SetManagerPinHash(ctx context.Context, ownerUserID int64, hash *string) error {
+	if _, err := r.Get(ctx, ownerUserID); err != nil {
+		return err
+	}
+	_, err := r.DB.Pool.Exec(ctx, `
+		UPDATE settings
+		SET manager_pin_hash=$2, manager_pin_failures=0, manager_pin_locked_until=NULL, updated_at=now()
+		WHERE owner_user_id=$1
+	`, ownerUserID, hash)
+	return err
+}
+
+// ClaimManagerPinAttempt counts a manager PIN attempt before the PIN is checked, so parallel guesses cannot
+// slip past the limit. attempt numbers it since the last correct PIN or the end of the last lockout; the
+// maxAttempts-th sets lockedUntil, which a correct PIN clears through ClearManagerPinAttempts. An attempt
+// beyond maxAttempts must be refused unchecked.
+func (r SettingsRepository) ClaimManagerPinAttempt(ctx context.Context, ownerUserID int64, maxAttempts int, lockout time.Duration) (attempt int, lockedUntil *time.Time, err error) {
+	err = r.DB.Pool.QueryRow(ctx, `
+		UPDATE settings
+		SET manager_pin_failures = CASE WHEN manager_pin_locked_until <= now() THEN 1 ELSE manager_pin_failures + 1 END,
+		    manager_pin_locked_until = CASE
+		        WHEN manager_pin_locked_until <= now() THEN NULL
+		        WHEN manager_pin_locked_until IS NULL AND manager_pin_failures + 1 >= $2 THEN now() + make_interval(secs => $3)
+		        ELSE manager_pin_locked_until
+		    END
+		WHERE owner_user_id=$1
+		RETURNING manager_pin_failures, manager_pin_locked_until
+	`, ownerUserID, maxAttempts, lockout.Seconds()).Scan(&attempt, &lockedUntil)
+	return attempt, lockedUntil, err
+}
+
+// ClearManagerPinAttempts forgets wrong attempts and lifts the lockout after a correct PIN.
+func (r SettingsRepository) ClearManagerPinAttempts(ctx context.Context, ownerUserID int64) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+		UPDATE settings
+		SET manager_pin_failures=0, manager_pin_locked_until=NULL
+		WHERE owner_user_id=$1
+	`, ownerUserID)
+	return err
+}
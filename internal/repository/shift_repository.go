@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"barberpos-backend/internal/db"
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrShiftOpen is returned when opening a shift on a terminal that already has one open.
+	ErrShiftOpen = errors.New("terminal already has an open shift")
+	// ErrShiftClosed is returned when closing a shift that was already closed.
+	ErrShiftClosed = errors.New("shift is closed")
+)
+
+type ShiftRepository struct {
+	DB *db.Postgres
+}
+
+const shiftColumns = `id, terminal_id, operator_name, status, note, opened_by, opened_at, closed_by, closed_at, created_at, updated_at`
+
+func scanShift(row pgx.Row) (*domain.Shift, error) {
+	var s domain.Shift
+	var status string
+	if err := row.Scan(
+		&s.ID, &s.TerminalID, &s.OperatorName, &status, &s.Note, &s.OpenedBy, &s.OpenedAt, &s.ClosedBy, &s.ClosedAt, &s.CreatedAt, &s.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	s.Status = domain.ShiftStatus(status)
+	return &s, nil
+}
+
+func newShiftID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "sh_" + hex.EncodeToString(b), nil
+}
+
+type OpenShiftInput struct {
+	TerminalID   string
+	OperatorName string
+	Note         string
+	OpenedBy     *int64
+}
+
+// Open starts a shift on a terminal under a new server-generated ID.
+func (r ShiftRepository) Open(ctx context.Context, ownerUserID int64, in OpenShiftInput) (*domain.Shift, error) {
+	id, err := newShiftID()
+	if err != nil {
+		return nil, err
+	}
+	s, err := scanShift(r.DB.Pool.QueryRow(ctx, `
+		INSERT INTO shifts (id, owner_user_id, terminal_id, operator_name, note, opened_by)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (owner_user_id, terminal_id) WHERE status = 'open' DO NOTHING
+		RETURNING `+shiftColumns,
+		id, ownerUserID, in.TerminalID, in.OperatorName, in.Note, in.OpenedBy))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrShiftOpen
+	}
+	return s, err
+}
+
+func (r ShiftRepository) Get(ctx context.Context, ownerUserID int64, id string) (*domain.Shift, error) {
+	return scanShift(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE id=$1 AND owner_user_id=$2
+	`, id, ownerUserID))
+}
+
+// Current returns the open shift of a terminal.
+func (r ShiftRepository) Current(ctx context.Context, ownerUserID int64, terminalID string) (*domain.Shift, error) {
+	return scanShift(r.DB.Pool.QueryRow(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE owner_user_id=$1 AND terminal_id=$2 AND status='open'
+	`, ownerUserID, terminalID))
+}
+
+// Close ends an open shift.
+func (r ShiftRepository) Close(ctx context.Context, ownerUserID int64, id string, closedBy *int64) (*domain.Shift, error) {
+	s, err := scanShift(r.DB.Pool.QueryRow(ctx, `
+		UPDATE shifts
+		SET status='closed', closed_by=$3, closed_at=now(), updated_at=now()
+		WHERE id=$1 AND owner_user_id=$2 AND status='open'
+		RETURNING `+shiftColumns,
+		id, ownerUserID, closedBy))
+	if errors.Is(err, ErrNotFound) {
+		if _, err := r.Get(ctx, ownerUserID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrShiftClosed
+	}
+	return s, err
+}
+
+type ShiftFilter struct {
+	Status     string // open or closed; empty for both
+	TerminalID string
+	Limit      int
+}
+
+// List returns shifts newest first.
+func (r ShiftRepository) List(ctx context.Context, ownerUserID int64, f ShiftFilter) ([]domain.Shift, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	return r.query(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE owner_user_id=$1 AND ($2 = '' OR status=$2) AND ($3 = '' OR terminal_id=$3)
+		ORDER BY opened_at DESC, id
+		LIMIT $4
+	`, ownerUserID, f.Status, f.TerminalID, f.Limit)
+}
+
+// Covering returns the shifts that were open at at, on terminalID or on any terminal when it is empty.
+// A zero at means now, i.e. the shifts open at the moment.
+func (r ShiftRepository) Covering(ctx context.Context, ownerUserID int64, terminalID string, at time.Time) ([]domain.Shift, error) {
+	if at.IsZero() {
+		return r.query(ctx, `
+			SELECT `+shiftColumns+`
+			FROM shifts
+			WHERE owner_user_id=$1 AND ($2 = '' OR terminal_id=$2) AND status='open'
+			ORDER BY opened_at DESC, id
+		`, ownerUserID, terminalID)
+	}
+	return r.query(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE owner_user_id=$1 AND ($2 = '' OR terminal_id=$2)
+		  AND opened_at <= $3 AND (closed_at IS NULL OR closed_at >= $3)
+		ORDER BY opened_at DESC, id
+	`, ownerUserID, terminalID, at)
+}
+
+func (r ShiftRepository) query(ctx context.Context, sql string, args ...any) ([]domain.Shift, error) {
+	rows, err := r.DB.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []domain.Shift
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *s)
+	}
+	return items, rows.Err()
+}
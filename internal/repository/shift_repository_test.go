@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShiftOpenCloseCovering(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	shifts := ShiftRepository{DB: pg}
+	shop, other := testOwner(t, pg), testOwner(t, pg)
+
+	open := func(owner int64, terminal string) string {
+		t.Helper()
+		s, err := shifts.Open(ctx, owner, OpenShiftInput{TerminalID: terminal, OperatorName: "Sari"})
+		if err != nil {
+			t.Fatalf("open shift on %s: %v", terminal, err)
+		}
+		return s.ID
+	}
+	covering := func(terminal string, at time.Time) []string {
+		t.Helper()
+		list, err := shifts.Covering(ctx, shop, terminal, at)
+		if err != nil {
+			t.Fatalf("covering: %v", err)
+		}
+		ids := make([]string, 0, len(list))
+		for _, s := range list {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	// Times come from the database clock, which stamps opened_at and closed_at.
+	dbNow := func() time.Time {
+		t.Helper()
+		time.Sleep(5 * time.Millisecond)
+		var at time.Time
+		if err := pg.Pool.QueryRow(ctx, `SELECT clock_timestamp()`).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+
+	first := open(shop, "T1")
+	if _, err := shifts.Open(ctx, shop, OpenShiftInput{TerminalID: "T1"}); !errors.Is(err, ErrShiftOpen) {
+		t.Errorf("second shift on T1: err = %v, want ErrShiftOpen", err)
+	}
+	second := open(shop, "T2")
+	open(other, "T1") // terminals are per shop
+
+	if got := covering("", time.Time{}); len(got) != 2 {
+		t.Errorf("open shifts = %v, want T1 and T2", got)
+	}
+	if got := covering("T1", time.Time{}); len(got) != 1 || got[0] != first {
+		t.Errorf("open shifts on T1 = %v, want %s", got, first)
+	}
+
+	during := dbNow()
+	closed, err := shifts.Close(ctx, shop, first, nil)
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if closed.ClosedAt == nil {
+		t.Error("closed shift has no closedAt")
+	}
+	after := dbNow()
+	if _, err := shifts.Close(ctx, shop, first, nil); !errors.Is(err, ErrShiftClosed) {
+		t.Errorf("closing twice: err = %v, want ErrShiftClosed", err)
+	}
+	if _, err := shifts.Close(ctx, shop, "sh_unknown", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("closing an unknown shift: err = %v, want ErrNotFound", err)
+	}
+	if _, err := shifts.Close(ctx, other, second, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("closing another shop's shift: err = %v, want ErrNotFound", err)
+	}
+
+	// Offline orders look for the shift that was open when they were rung up.
+	if got := covering("T1", during); len(got) != 1 || got[0] != first {
+		t.Errorf("T1 shifts open before the close = %v, want %s", got, first)
+	}
+	if got := covering("T1", after); len(got) != 0 {
+		t.Errorf("T1 shifts open after the close = %v, want none", got)
+	}
+	if got := covering("", time.Time{}); len(got) != 1 || got[0] != second {
+		t.Errorf("open shifts after closing T1 = %v, want %s", got, second)
+	}
+
+	reopened := open(shop, "T1")
+	if reopened == first {
+		t.Error("a reopened terminal reuses the closed shift id")
+	}
+}
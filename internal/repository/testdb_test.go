@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"barberpos-backend/internal/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB migrates a fresh schema in the database at TEST_DATABASE_URL and drops it when the test ends.
+// Tests that need Postgres are skipped when the variable is not set.
+func testDB(t *testing.T) *db.Postgres {
+	t.Helper()
+	return testDBBefore(t, "")
+}
+
+// testDBBefore is testDB with only the migrations that sort before the named file applied,
+// so a test can seed data for that migration and run it with migrateTestDB. An empty name applies all.
+func testDBBefore(t *testing.T, migration string) *db.Postgres {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(b)
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	pg := &db.Postgres{Pool: pool}
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if migration != "" && filepath.Base(f) >= migration {
+			break
+		}
+		migrateTestDB(t, pg, filepath.Base(f))
+	}
+	return pg
+}
+
+// migrateTestDB applies the Up section of one migration file.
+func migrateTestDB(t *testing.T, pg *db.Postgres, migration string) {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("..", "..", "migrations", migration))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Run the Up section as one simple-protocol batch; goose's statement markers are comments to Postgres.
+	up, _, _ := strings.Cut(string(raw), "-- +goose Down")
+	if _, err := pg.Pool.Exec(context.Background(), up); err != nil {
+		t.Fatalf("%s: %v", migration, err)
+	}
+}
+
+// testOwner creates a manager account to own test data.
+func testOwner(t *testing.T, pg *db.Postgres) int64 {
+	t.Helper()
+	var id int64
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	err := pg.Pool.QueryRow(context.Background(), `
+		INSERT INTO users (name, email, role) VALUES ('Owner', $1, 'manager') RETURNING id
+	`, "owner-"+hex.EncodeToString(b)+"@example.com").Scan(&id)
+	if err != nil {
+		t.Fatalf("create owner: %v", err)
+	}
+	return id
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+const (
+	DefaultTransactionPageSize = 50
+	MaxTransactionPageSize     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TransactionFilter narrows a transaction listing. Zero values mean "no filter".
+type TransactionFilter struct {
+	StartDate     *time.Time
+	EndDate       *time.Time
+	StylistID     *int64
+	Stylist       string // stylist name, case-insensitive; wildcards are matched literally
+	PaymentMethod string // matches the header method or any tender of a split payment
+	Statuses      []domain.TransactionStatus
+	Operator      string // operator name, case-insensitive; wildcards are matched literally
+	ShiftID       string
+	CustomerPhone string
+	MinAmount     *int64
+	MaxAmount     *int64
+	Query         string // substring of code or receipt number
+	Cursor        string
+	Limit         int
+}
+
+// transactionCursor is the keyset position of the last row of a page; listings are ordered by
+// (transacted_date, id) descending so the pair is unique and stable while new rows come in.
+type transactionCursor struct {
+	Date time.Time
+	ID   int64
+}
+
+func encodeTransactionCursor(t domain.Transaction) string {
+	raw := t.Date.Format("2006-01-02") + "|" + strconv.FormatInt(t.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransactionCursor(s string) (transactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return transactionCursor{}, ErrInvalidCursor
+	}
+	datePart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return transactionCursor{}, ErrInvalidCursor
+	}
+	date, err := time.Parse("2006-01-02", datePart)
+	if err != nil {
+		return transactionCursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		return transactionCursor{}, ErrInvalidCursor
+	}
+	return transactionCursor{Date: date, ID: id}, nil
+}
+
+// ListPage returns one page of transactions matching f, newest first, and the cursor of the
+// next page ("" when this is the last one).
+func (r TransactionRepository) ListPage(ctx context.Context, ownerUserID int64, f TransactionFilter) ([]domain.Transaction, string, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultTransactionPageSize
+	}
+	if limit > MaxTransactionPageSize {
+		limit = MaxTransactionPageSize
+	}
+
+	query, args, err := transactionFilterQuery(ownerUserID, f)
+	if err != nil {
+		return nil, "", err
+	}
+	// One extra row tells us whether another page exists without a COUNT(*).
+	args = append(args, limit+1)
+	query += fmt.Sprintf(" ORDER BY transacted_date DESC, id DESC LIMIT $%d", len(args))
+
+	txs, err := r.listWhere(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(txs) > limit {
+		txs = txs[:limit]
+		next = encodeTransactionCursor(txs[len(txs)-1])
+	}
+	return txs, next, nil
+}
+
+// ListAll returns every transaction matching f, newest first; f.Limit is ignored.
+func (r TransactionRepository) ListAll(ctx context.Context, ownerUserID int64, f TransactionFilter) ([]domain.Transaction, error) {
+	query, args, err := transactionFilterQuery(ownerUserID, f)
+	if err != nil {
+		return nil, err
+	}
+	return r.listWhere(ctx, query+" ORDER BY transacted_date DESC, id DESC", args...)
+}
+
+// transactionFilterQuery builds the SELECT for the owner's transactions matching f, without ordering or limit.
+func transactionFilterQuery(ownerUserID int64, f TransactionFilter) (string, []any, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE deleted_at IS NULL AND owner_user_id = $1
+	`
+	args := []any{ownerUserID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.StartDate != nil {
+		query += " AND transacted_date >= " + arg(f.StartDate.Format("2006-01-02")) + "::date"
+	}
+	if f.EndDate != nil {
+		query += " AND transacted_date <= " + arg(f.EndDate.Format("2006-01-02")) + "::date"
+	}
+	if f.StylistID != nil {
+		// A stylist matches the order's header or any line they were credited with.
+		s := arg(*f.StylistID)
+		query += " AND (stylist_id = " + s + " OR EXISTS (SELECT 1 FROM transaction_items ti WHERE ti.transaction_id = transactions.id AND ti.stylist_id = " + s + "))"
+	}
+	if f.Stylist != "" {
+		// Like stylistId, the name matches the header or any line.
+		s := arg(escapeLike(f.Stylist))
+		query += " AND (stylist ILIKE " + s + ` ESCAPE '\' OR EXISTS (SELECT 1 FROM transaction_items ti WHERE ti.transaction_id = transactions.id AND ti.stylist ILIKE ` + s + ` ESCAPE '\'))`
+	}
+	if f.PaymentMethod != "" {
+		p := arg(f.PaymentMethod)
+		query += " AND (payment_method = " + p + " OR EXISTS (SELECT 1 FROM transaction_payments tp WHERE tp.transaction_id = transactions.id AND tp.method = " + p + "))"
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, 0, len(f.Statuses))
+		for _, s := range f.Statuses {
+			statuses = append(statuses, string(s))
+		}
+		query += " AND status = ANY(" + arg(statuses) + ")"
+	}
+	if f.Operator != "" {
+		query += " AND operator_name ILIKE " + arg(escapeLike(f.Operator)) + ` ESCAPE '\'`
+	}
+	if f.ShiftID != "" {
+		query += " AND shift_id = " + arg(f.ShiftID)
+	}
+	if f.CustomerPhone != "" {
+		query += " AND customer_phone LIKE " + arg("%"+escapeLike(f.CustomerPhone)+"%") + ` ESCAPE '\'`
+	}
+	if f.MinAmount != nil {
+		query += " AND amount >= " + arg(*f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		query += " AND amount <= " + arg(*f.MaxAmount)
+	}
+	if f.Query != "" {
+		q := arg("%" + escapeLike(f.Query) + "%")
+		query += " AND (code ILIKE " + q + ` ESCAPE '\' OR receipt_number ILIKE ` + q + ` ESCAPE '\')`
+	}
+	if f.Cursor != "" {
+		c, err := decodeTransactionCursor(f.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		query += " AND (transacted_date, id) < (" + arg(c.Date.Format("2006-01-02")) + "::date, " + arg(c.ID) + ")"
+	}
+	return query, args, nil
+}
+
+// escapeLike quotes LIKE wildcards in s; the pattern must be used with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
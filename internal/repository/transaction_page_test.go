@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTransactionListingFilters(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	owner := testOwner(t, pg)
+	txs := TransactionRepository{DB: pg}
+
+	sell := func(stylist string, lineStylists ...string) string {
+		t.Helper()
+		time.Sleep(2 * time.Millisecond) // codes are minted from the clock in milliseconds
+		in := CreateTransactionInput{PaymentMethod: "cash", Stylist: stylist}
+		for _, s := range lineStylists {
+			in.Items = append(in.Items, CreateTransactionItem{Name: "Haircut", Price: 50000, Qty: 1, Stylist: s})
+			in.Amount += 50000
+		}
+		in.Subtotal = in.Amount
+		sale, err := txs.Create(ctx, owner, in, nil)
+		if err != nil {
+			t.Fatalf("create sale: %v", err)
+		}
+		return sale.Code
+	}
+	codes := func(f TransactionFilter) []string {
+		t.Helper()
+		list, err := txs.ListAll(ctx, owner, f)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		out := make([]string, 0, len(list))
+		for _, tx := range list {
+			out = append(out, tx.Code)
+		}
+		return out
+	}
+
+	header := sell("Budi", "")
+	split := sell("Andi", "Andi", "Budi")
+	other := sell("Andi", "Andi")
+
+	got := codes(TransactionFilter{Stylist: "budi"})
+	if len(got) != 2 || got[0] != split || got[1] != header {
+		t.Errorf("stylist budi = %v, want %v (newest first)", got, []string{split, header})
+	}
+	if got := codes(TransactionFilter{Stylist: "B_di"}); len(got) != 0 {
+		t.Errorf("stylist with a wildcard = %v, want none", got)
+	}
+
+	// A day either side: the sale date is in the shop's timezone.
+	from, to := time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 1)
+	if got := codes(TransactionFilter{StartDate: &from, EndDate: &to}); len(got) != 3 || got[0] != other {
+		t.Errorf("date range = %v, want all three sales, newest first", got)
+	}
+	page, next, err := txs.ListPage(ctx, owner, TransactionFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("list page: %v", err)
+	}
+	if len(page) != 2 || next == "" {
+		t.Fatalf("first page has %d rows, next %q; want 2 and a cursor", len(page), next)
+	}
+	if got := codes(TransactionFilter{Cursor: next}); len(got) != 1 || got[0] != header {
+		t.Errorf("after the first page = %v, want %v", got, []string{header})
+	}
+}
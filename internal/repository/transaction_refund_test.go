@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestPartialRefundsAndMarkPaid(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	owner := testOwner(t, pg)
+	txs := TransactionRepository{DB: pg}
+	dashboard := DashboardRepository{DB: pg}
+
+	sell := func(in CreateTransactionInput) *domain.Transaction {
+		t.Helper()
+		time.Sleep(2 * time.Millisecond) // codes are minted from the clock in milliseconds
+		in.PaymentMethod = "cash"
+		sale, err := txs.Create(ctx, owner, in, nil)
+		if err != nil {
+			t.Fatalf("create sale: %v", err)
+		}
+		got, err := txs.GetByCode(ctx, owner, sale.Code)
+		if err != nil {
+			t.Fatalf("get sale: %v", err)
+		}
+		return got
+	}
+	refund := func(in RefundTransactionParams) (*domain.Refund, error) {
+		return txs.RefundByCode(ctx, owner, in, nil)
+	}
+
+	// Haircut 50000 by Budi, two pomades at 20000 less 4000 by Andi: 86000.
+	sale := sell(CreateTransactionInput{
+		Amount:   86000,
+		Subtotal: 90000,
+		Items: []CreateTransactionItem{
+			{Name: "Haircut", Price: 50000, Qty: 1, Stylist: "Budi"},
+			{Name: "Pomade", Price: 20000, Qty: 2, Discount: 4000, Stylist: "Andi"},
+		},
+	})
+	pomade := sale.Items[1].ID
+	refunded := sell(CreateTransactionInput{
+		Amount:   70000,
+		Subtotal: 70000,
+		Items:    []CreateTransactionItem{{Name: "Haircut", Price: 70000, Qty: 1, Stylist: "Budi"}},
+	})
+	if _, err := refund(RefundTransactionParams{Code: refunded.Code}); err != nil {
+		t.Fatalf("full refund: %v", err)
+	}
+
+	rf, err := refund(RefundTransactionParams{Code: sale.Code, Lines: []RefundLineInput{{ItemID: pomade, Qty: 1}}})
+	if err != nil {
+		t.Fatalf("line refund: %v", err)
+	}
+	if rf.Amount.Amount != 18000 || rf.Units != 1 {
+		t.Errorf("line refund = %d for %d units, want 18000 for 1", rf.Amount.Amount, rf.Units)
+	}
+	if _, err := refund(RefundTransactionParams{Code: sale.Code, Amount: 10000}); err != nil {
+		t.Fatalf("amount refund: %v", err)
+	}
+	var invalid *RefundInvalidError
+	if _, err := refund(RefundTransactionParams{Code: sale.Code, Lines: []RefundLineInput{{ItemID: pomade, Qty: 2}}}); !errors.As(err, &invalid) {
+		t.Errorf("refunding more units than are left: err = %v, want RefundInvalidError", err)
+	}
+	if _, err := refund(RefundTransactionParams{Code: sale.Code, Amount: 60000}); !errors.As(err, &invalid) {
+		t.Errorf("refunding more than is left: err = %v, want RefundInvalidError", err)
+	}
+
+	got, err := txs.GetByCode(ctx, owner, sale.Code)
+	if err != nil {
+		t.Fatalf("get sale: %v", err)
+	}
+	if got.Status != domain.TransactionPartiallyRefunded || got.RefundedAmount.Amount != 28000 {
+		t.Errorf("after refunds: status %s, refunded %d; want partially_refunded, 28000", got.Status, got.RefundedAmount.Amount)
+	}
+
+	// The top lists leave out the refunded sale and the refunded pomade.
+	services, err := dashboard.TopServices(ctx, owner, 10)
+	if err != nil {
+		t.Fatalf("top services: %v", err)
+	}
+	wantServices := []DashboardItem{
+		{Name: "Haircut", Amount: 50000, Gross: 50000, Discount: 0, Count: 1},
+		{Name: "Pomade", Amount: 18000, Gross: 20000, Discount: 2000, Count: 1},
+	}
+	if len(services) != len(wantServices) {
+		t.Fatalf("top services = %+v, want %+v", services, wantServices)
+	}
+	for i := range wantServices {
+		if services[i] != wantServices[i] {
+			t.Errorf("top services[%d] = %+v, want %+v", i, services[i], wantServices[i])
+		}
+	}
+	staff, err := dashboard.TopStaff(ctx, owner, 10)
+	if err != nil {
+		t.Fatalf("top staff: %v", err)
+	}
+	if len(staff) != 2 || staff[0].Name != "Budi" || staff[0].Amount != 50000 || staff[1].Name != "Andi" || staff[1].Amount != 18000 {
+		t.Errorf("top staff = %+v, want Budi 50000 then Andi 18000", staff)
+	}
+
+	// Mark-paid undoes both refunds and reports what they gave back.
+	tx, err := pg.Pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	reversed, err := txs.MarkPaidByCodeWithTx(ctx, tx, owner, sale.Code)
+	if err != nil {
+		t.Fatalf("mark paid: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reversed.Amount.Amount != 28000 || reversed.Units != 1 || len(reversed.Items) != 1 ||
+		reversed.Items[0].TransactionItemID != pomade || reversed.Items[0].Qty != 1 {
+		t.Errorf("reversed = %+v, want 28000 and one pomade", reversed)
+	}
+	got, err = txs.GetByCode(ctx, owner, sale.Code)
+	if err != nil {
+		t.Fatalf("get sale: %v", err)
+	}
+	if got.Status != domain.TransactionPaid || got.RefundedAmount.Amount != 0 || got.Items[1].RefundedQty != 0 {
+		t.Errorf("after mark paid: status %s, refunded %d, pomade refunded qty %d; want paid, 0, 0",
+			got.Status, got.RefundedAmount.Amount, got.Items[1].RefundedQty)
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,20 +13,22 @@ import (
 )
 
 type TransactionRepository struct {
-	DB *db.Postgres
+	DB              *db.Postgres
+	DefaultCurrency string // CURRENCY_CODE, for owners who have not saved settings
 }
 
-func (r TransactionRepository) GetByCode(ctx context.Context, ownerUserID int64, code string) (*domain.Transaction, error) {
-	row := r.DB.Pool.QueryRow(ctx, `
-		SELECT id, code, transacted_date, transacted_time, amount, payment_method, status, stylist, stylist_id,
-		       customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
-		       shift_id, operator_name, refunded_at, refunded_by, refund_note, created_at, updated_at, deleted_at
-		FROM transactions
-		WHERE deleted_at IS NULL AND code = $1 AND owner_user_id=$2
-		LIMIT 1
-	`, code, ownerUserID)
+const transactionColumns = `id, code, receipt_number, transacted_date, transacted_time, amount, subtotal, discount_total,
+		       service_charge_rate, service_charge, tax_rate, tax_inclusive, tax_amount, payment_method, status, stylist, stylist_id,
+		       customer_id, customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
+		       shift_id, operator_name, refunded_at, refunded_by, refund_note, refunded_amount, tip_total, rounding_adjustment, currency,
+		       payment_intent_id, voided_at, voided_by, void_approved_by, void_reason, void_note, created_at, updated_at, deleted_at`
+
+const transactionItemColumns = `transaction_id, id, product_id, name, category, price, qty, discount, refunded_qty, stylist_id, stylist, custom, currency, created_at`
+
+func scanTransaction(row pgx.Row) (*domain.Transaction, error) {
 	var t domain.Transaction
 	var status string
+	var receiptNumber pgtype.Text
 	var customerName, customerPhone, customerEmail, customerAddress pgtype.Text
 	var visits pgtype.Int4
 	var lastVisit pgtype.Text
@@ -37,9 +40,11 @@ func (r TransactionRepository) GetByCode(ctx context.Context, ownerUserID int64,
 	var refundNote pgtype.Text
 	var deletedAt pgtype.Timestamptz
 	if err := row.Scan(
-		&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.PaymentMethod, &status, &t.Stylist, &stylistID,
-		&customerName, &customerPhone, &customerEmail, &customerAddress, &visits, &lastVisit,
-		&shiftID, &opName, &refundedAt, &refundedBy, &refundNote, &t.CreatedAt, &t.UpdatedAt, &deletedAt,
+		&t.ID, &t.Code, &receiptNumber, &t.Date, &t.Time, &t.Amount.Amount, &t.Subtotal.Amount, &t.DiscountTotal.Amount,
+		&t.ServiceChargeRate, &t.ServiceCharge.Amount, &t.TaxRate, &t.TaxInclusive, &t.TaxAmount.Amount, &t.PaymentMethod, &status, &t.Stylist, &stylistID,
+		&t.CustomerID, &customerName, &customerPhone, &customerEmail, &customerAddress, &visits, &lastVisit,
+		&shiftID, &opName, &refundedAt, &refundedBy, &refundNote, &t.RefundedAmount.Amount, &t.TipTotal.Amount, &t.Rounding.Amount, &t.Amount.Currency,
+		&t.PaymentIntentID, &t.VoidedAt, &t.VoidedBy, &t.VoidApprovedBy, &t.VoidReason, &t.VoidNote, &t.CreatedAt, &t.UpdatedAt, &deletedAt,
 	); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
@@ -50,6 +55,7 @@ func (r TransactionRepository) GetByCode(ctx context.Context, ownerUserID int64,
 		t.StylistID = &stylistID.Int64
 	}
 	t.Status = domain.TransactionStatus(status)
+	t.ReceiptNumber = receiptNumber.String
 	t.Customer = &domain.TransactionCustomerSnapshot{
 		Name:    customerName.String,
 		Phone:   customerPhone.String,
@@ -86,30 +92,275 @@ func (r TransactionRepository) GetByCode(ctx context.Context, ownerUserID int64,
 		d := deletedAt.Time
 		t.DeletedAt = &d
 	}
+	return &t, nil
+}
 
-	itemRows, err := r.DB.Pool.Query(ctx, `
-		SELECT transaction_id, id, product_id, name, category, price, qty, created_at
+// loadItems fetches the line items for the given transactions, grouped by transaction id.
+func loadItems(ctx context.Context, q pgxQuerier, ids []int64) (map[int64][]domain.TransactionItem, error) {
+	itemsByTx := make(map[int64][]domain.TransactionItem)
+	if len(ids) == 0 {
+		return itemsByTx, nil
+	}
+	rows, err := q.Query(ctx, `
+		SELECT `+transactionItemColumns+`
 		FROM transaction_items
-		WHERE transaction_id=$1 AND deleted_at IS NULL
-	`, t.ID)
+		WHERE transaction_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id ASC
+	`, ids)
 	if err != nil {
 		return nil, err
 	}
-	defer itemRows.Close()
+	defer rows.Close()
 
-	for itemRows.Next() {
+	for rows.Next() {
 		var it domain.TransactionItem
-		var txID int64
-		if err := itemRows.Scan(&txID, &it.ID, &it.ProductID, &it.Name, &it.Category, &it.Price.Amount, &it.Qty, &it.CreatedAt); err != nil {
+		if err := rows.Scan(&it.TransactionID, &it.ID, &it.ProductID, &it.Name, &it.Category, &it.Price.Amount, &it.Qty, &it.Discount.Amount, &it.RefundedQty, &it.StylistID, &it.Stylist, &it.Custom, &it.Price.Currency, &it.CreatedAt); err != nil {
 			return nil, err
 		}
-		it.TransactionID = txID
-		t.Items = append(t.Items, it)
+		it.Discount.Currency = it.Price.Currency
+		itemsByTx[it.TransactionID] = append(itemsByTx[it.TransactionID], it)
 	}
-	if err := itemRows.Err(); err != nil {
+	return itemsByTx, rows.Err()
+}
+
+// loadDiscounts fetches the discount lines recorded against a transaction.
+func loadDiscounts(ctx context.Context, q pgxQuerier, transactionID int64) ([]domain.TransactionDiscount, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, transaction_id, transaction_item_id, promotion_id, code, kind, scope, value, amount, note, created_at
+		FROM transaction_discounts
+		WHERE transaction_id=$1
+		ORDER BY id ASC
+	`, transactionID)
+	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	defer rows.Close()
+
+	var out []domain.TransactionDiscount
+	for rows.Next() {
+		var d domain.TransactionDiscount
+		var kind, scope string
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.TransactionItemID, &d.PromotionID, &d.Code, &kind, &scope, &d.Value, &d.Amount.Amount, &d.Note, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Kind = domain.DiscountKind(kind)
+		d.Scope = domain.DiscountScope(scope)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// loadPayments fetches the tenders recorded against the given transactions, grouped by transaction id.
+func loadPayments(ctx context.Context, q pgxQuerier, ids []int64) (map[int64][]domain.TransactionPayment, error) {
+	paymentsByTx := make(map[int64][]domain.TransactionPayment)
+	if len(ids) == 0 {
+		return paymentsByTx, nil
+	}
+	rows, err := q.Query(ctx, `
+		SELECT id, transaction_id, method, amount, reference, payment_intent_id, created_at
+		FROM transaction_payments
+		WHERE transaction_id = ANY($1)
+		ORDER BY id ASC
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p domain.TransactionPayment
+		if err := rows.Scan(&p.ID, &p.TransactionID, &p.Method, &p.Amount.Amount, &p.Reference, &p.PaymentIntentID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		paymentsByTx[p.TransactionID] = append(paymentsByTx[p.TransactionID], p)
+	}
+	return paymentsByTx, rows.Err()
+}
+
+// loadRefunds fetches every refund recorded against a transaction, oldest first, with the lines each one covered.
+func loadRefunds(ctx context.Context, q pgxQuerier, transactionID int64) ([]domain.Refund, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, transaction_id, amount, units, note, refunded_by, reversed_at, created_at
+		FROM transaction_refunds
+		WHERE transaction_id=$1
+		ORDER BY id ASC
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.Refund
+	for rows.Next() {
+		var rf domain.Refund
+		if err := rows.Scan(&rf.ID, &rf.TransactionID, &rf.Amount.Amount, &rf.Units, &rf.Note, &rf.RefundedBy, &rf.ReversedAt, &rf.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, rf)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	lineRows, err := q.Query(ctx, `
+		SELECT ri.refund_id, ri.transaction_item_id, ti.product_id, ri.qty
+		FROM transaction_refund_items ri
+		JOIN transaction_items ti ON ti.id = ri.transaction_item_id
+		JOIN transaction_refunds r ON r.id = ri.refund_id
+		WHERE r.transaction_id=$1
+		ORDER BY ri.id ASC
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer lineRows.Close()
+	index := make(map[int64]int, len(out))
+	for i := range out {
+		index[out[i].ID] = i
+	}
+	for lineRows.Next() {
+		var refundID int64
+		var line domain.RefundLine
+		if err := lineRows.Scan(&refundID, &line.TransactionItemID, &line.ProductID, &line.Qty); err != nil {
+			return nil, err
+		}
+		if i, ok := index[refundID]; ok {
+			out[i].Items = append(out[i].Items, line)
+		}
+	}
+	return out, lineRows.Err()
+}
+
+// loadTips fetches the per-stylist tip split of a transaction.
+func loadTips(ctx context.Context, q pgxQuerier, transactionID int64) ([]domain.TransactionTip, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, transaction_id, stylist_id, stylist, amount, created_at
+		FROM transaction_tips
+		WHERE transaction_id=$1
+		ORDER BY id ASC
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.TransactionTip
+	for rows.Next() {
+		var tip domain.TransactionTip
+		if err := rows.Scan(&tip.ID, &tip.TransactionID, &tip.StylistID, &tip.Stylist, &tip.Amount.Amount, &tip.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, tip)
+	}
+	return out, rows.Err()
+}
+
+// getDetail loads a single transaction with its items, discounts, payments, tips and refunds.
+func (r TransactionRepository) getDetail(ctx context.Context, q pgxQuerier, where string, args ...any) (*domain.Transaction, error) {
+	t, err := scanTransaction(q.QueryRow(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions
+		WHERE deleted_at IS NULL AND `+where+`
+		LIMIT 1
+	`, args...))
+	if err != nil {
+		return nil, err
+	}
+	items, err := loadItems(ctx, q, []int64{t.ID})
+	if err != nil {
+		return nil, err
+	}
+	t.Items = items[t.ID]
+	t.Discounts, err = loadDiscounts(ctx, q, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := loadPayments(ctx, q, []int64{t.ID})
+	if err != nil {
+		return nil, err
+	}
+	t.Payments = payments[t.ID]
+	t.Tips, err = loadTips(ctx, q, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	t.Refunds, err = loadRefunds(ctx, q, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	applyCurrency(t)
+	return t, nil
+}
+
+// applyCurrency labels every amount of t, down to its tenders, tips and refunds, with the currency
+// the transaction was recorded in (scanned into t.Amount.Currency).
+func applyCurrency(t *domain.Transaction) {
+	c := t.Amount.Currency
+	for _, m := range []*domain.Money{&t.Subtotal, &t.DiscountTotal, &t.ServiceCharge, &t.TaxAmount, &t.RefundedAmount, &t.TipTotal, &t.Rounding} {
+		m.Currency = c
+	}
+	for i := range t.Items {
+		t.Items[i].Price.Currency = c
+		t.Items[i].Discount.Currency = c
+	}
+	for i := range t.Discounts {
+		t.Discounts[i].Amount.Currency = c
+	}
+	for i := range t.Payments {
+		t.Payments[i].Amount.Currency = c
+	}
+	for i := range t.Tips {
+		t.Tips[i].Amount.Currency = c
+	}
+	for i := range t.Refunds {
+		t.Refunds[i].Amount.Currency = c
+	}
+}
+
+// listWhere loads transactions matching the given predicate, newest first, with their items and payments.
+func (r TransactionRepository) listWhere(ctx context.Context, query string, args ...any) ([]domain.Transaction, error) {
+	rows, err := r.DB.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []domain.Transaction
+	var ids []int64
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, t.ID)
+		txs = append(txs, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemsByTx, err := loadItems(ctx, r.DB.Pool, ids)
+	if err != nil {
+		return nil, err
+	}
+	paymentsByTx, err := loadPayments(ctx, r.DB.Pool, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range txs {
+		txs[i].Items = itemsByTx[txs[i].ID]
+		txs[i].Payments = paymentsByTx[txs[i].ID]
+		applyCurrency(&txs[i])
+	}
+	return txs, nil
+}
+
+// GetByCode finds a transaction by its internal code or its receipt number.
+func (r TransactionRepository) GetByCode(ctx context.Context, ownerUserID int64, code string) (*domain.Transaction, error) {
+	return r.getDetail(ctx, r.DB.Pool, "(code = $1 OR receipt_number = $1) AND owner_user_id=$2", code, ownerUserID)
 }
 
 type CreateTransactionInput struct {
@@ -125,8 +376,42 @@ type CreateTransactionInput struct {
 	ShiftID           *string
 	OperatorName      string
 	ClientRef         *string
+	// TransactedAt is when the sale happened on the device; zero means now. Offline orders synced
+	// later keep their original date and time.
+	TransactedAt      time.Time
 	Amount            int64
+	Subtotal          int64
+	DiscountTotal     int64
+	ServiceChargeRate float64
+	ServiceCharge     int64
+	TaxRate           float64
+	TaxInclusive      bool
+	TaxAmount         int64
 	Items             []CreateTransactionItem
+	Discounts         []CreateTransactionDiscount
+	// Payments are the tenders covering Amount plus tips and Rounding; when empty a single PaymentMethod tender is recorded.
+	Payments []CreateTransactionPayment
+	// Tips are kept out of Amount and split per stylist.
+	Tips []CreateTransactionTip
+	// LinkCustomer ties the transaction to a customers row; the snapshot fields are then filled from it.
+	LinkCustomer *CustomerLink
+	// Rounding is the cash rounding included in the cash tender on top of Amount plus tips.
+	Rounding int64
+	// PaymentIntentID is the intent paying the single PaymentMethod tender when Payments is empty.
+	PaymentIntentID *string
+}
+
+type CreateTransactionTip struct {
+	StylistID *int64
+	Stylist   string
+	Amount    int64
+}
+
+type CreateTransactionPayment struct {
+	Method          string
+	Amount          int64
+	Reference       *string
+	PaymentIntentID *string
 }
 
 type CreateTransactionItem struct {
@@ -135,6 +420,25 @@ type CreateTransactionItem struct {
 	Category  string
 	Price     int64
 	Qty       int
+	// Discount is the line's share of every discount applied to the order, so price*qty-discount is net revenue.
+	Discount int64
+	// StylistID and Stylist name who did the line; both empty means the transaction's stylist.
+	StylistID *int64
+	Stylist   string
+	// Custom marks a line without a product, priced by the cashier.
+	Custom bool
+}
+
+type CreateTransactionDiscount struct {
+	// ItemIndex points into CreateTransactionInput.Items for line-level discounts; nil for order-level ones.
+	ItemIndex   *int
+	PromotionID *int64
+	Code        string
+	Kind        domain.DiscountKind
+	Scope       domain.DiscountScope
+	Value       int64
+	Amount      int64
+	Note        string
 }
 
 func (r TransactionRepository) Create(ctx context.Context, ownerUserID int64, in CreateTransactionInput, after func(context.Context, pgx.Tx) error) (*domain.Transaction, error) {
@@ -157,48 +461,177 @@ func (r TransactionRepository) Create(ctx context.Context, ownerUserID int64, in
 		}
 	}
 
+	var tipTotal int64
+	for _, tip := range in.Tips {
+		tipTotal += tip.Amount
+	}
+
 	code := fmt.Sprintf("ORD-%d", time.Now().UnixNano()/1e6)
-	now := time.Now()
+	// Dates and times are the shop's local business day, whatever the server's timezone.
+	loc, err := ownerLocation(ctx, tx, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(loc)
+	if !in.TransactedAt.IsZero() {
+		now = in.TransactedAt.In(loc)
+	}
+	// Amounts are recorded in the owner's currency at the time of sale.
+	currency, err := ownerCurrency(ctx, tx, ownerUserID, r.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
 	var id int64
 	_, err = tx.Exec(ctx, "SET LOCAL synchronous_commit TO OFF")
 	if err != nil {
 		// non-fatal; continue
 	}
+	receiptNumber, err := allocateReceiptNumberWithTx(ctx, tx, ownerUserID, now)
+	if err != nil {
+		return nil, err
+	}
+	var customerID *int64
+	if in.LinkCustomer != nil {
+		c, err := resolveCustomerWithTx(ctx, tx, ownerUserID, *in.LinkCustomer)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			// The snapshot is what the receipt shows: this visit's number and the visit before it.
+			customerID = &c.ID
+			visits := c.Visits + 1
+			in.CustomerVisits = &visits
+			in.CustomerLastVisit = nil
+			if c.LastVisit != nil {
+				lv := c.LastVisit.Format("2006-01-02")
+				in.CustomerLastVisit = &lv
+			}
+			if in.CustomerName == "" {
+				in.CustomerName = c.Name
+			}
+			in.CustomerPhone = c.Phone
+			if in.CustomerEmail == "" {
+				in.CustomerEmail = c.Email
+			}
+			if in.CustomerAddr == "" {
+				in.CustomerAddr = c.Address
+			}
+		}
+	}
 	err = tx.QueryRow(ctx, `
 		INSERT INTO transactions
-		(owner_user_id, code, client_ref, transacted_date, transacted_time, amount, payment_method, status, stylist, stylist_id,
-		 customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
-		 shift_id, operator_name, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18, now(), now())
+		(owner_user_id, code, receipt_number, client_ref, transacted_date, transacted_time, amount, subtotal, discount_total,
+		 service_charge_rate, service_charge, tax_rate, tax_inclusive, tax_amount, payment_method, status, stylist, stylist_id,
+		 customer_id, customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
+		 shift_id, operator_name, tip_total, rounding_adjustment, currency, payment_intent_id, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31, now(), now())
 		RETURNING id
-	`, ownerUserID, code, in.ClientRef, now.Format("2006-01-02"), now.Format("15:04"), in.Amount, in.PaymentMethod, domain.TransactionPaid, in.Stylist, in.StylistID,
-		in.CustomerName, in.CustomerPhone, in.CustomerEmail, in.CustomerAddr, in.CustomerVisits, in.CustomerLastVisit,
-		in.ShiftID, in.OperatorName).Scan(&id)
+	`, ownerUserID, code, receiptNumber, in.ClientRef, now.Format("2006-01-02"), now.Format("15:04"), in.Amount, in.Subtotal, in.DiscountTotal,
+		in.ServiceChargeRate, in.ServiceCharge, in.TaxRate, in.TaxInclusive, in.TaxAmount, in.PaymentMethod, domain.TransactionPaid, in.Stylist, in.StylistID,
+		customerID, in.CustomerName, in.CustomerPhone, in.CustomerEmail, in.CustomerAddr, in.CustomerVisits, in.CustomerLastVisit,
+		in.ShiftID, in.OperatorName, tipTotal, in.Rounding, currency, paymentIntentID(in)).Scan(&id)
 	if err != nil {
 		// Race-safe idempotency: if another request with the same client_ref inserted first, load and return it.
+		// The failed insert aborted tx, so the winner is read outside it.
 		if in.ClientRef != nil && *in.ClientRef != "" && db.IsUniqueViolation(err) {
-			existing, getErr := r.getByClientRefWithTx(ctx, tx, ownerUserID, *in.ClientRef)
+			_ = tx.Rollback(ctx)
+			existing, getErr := r.GetByClientRef(ctx, ownerUserID, *in.ClientRef)
 			if getErr != nil {
 				return nil, err
 			}
-			if err := tx.Commit(ctx); err != nil {
-				return nil, err
-			}
 			return existing, nil
 		}
 		return nil, err
 	}
 
-	for _, item := range in.Items {
+	items := append([]CreateTransactionItem(nil), in.Items...)
+	itemIDs := make([]int64, len(items))
+	for i := range items {
+		item := &items[i]
+		if item.StylistID == nil && item.Stylist == "" {
+			item.StylistID, item.Stylist = in.StylistID, in.Stylist
+		}
+		// As with tips, a stylist id that is not one of the owner's employees is dropped and the name kept.
+		err := tx.QueryRow(ctx, `
+			INSERT INTO transaction_items (transaction_id, product_id, name, category, price, qty, discount, stylist_id, stylist, currency, custom, created_at)
+			SELECT $1,
+			       (SELECT id FROM products WHERE id=$2 AND owner_user_id=$7 AND deleted_at IS NULL),
+			       $3,$4,$5,$6,$8, e.id, COALESCE(NULLIF($10, ''), e.name, ''), $11, $12, now()
+			FROM (SELECT 1) AS one
+			LEFT JOIN employees e ON e.id = $9 AND e.manager_user_id = $7 AND e.deleted_at IS NULL
+			RETURNING id, stylist_id, stylist
+		`, id, item.ProductID, item.Name, item.Category, item.Price, item.Qty, ownerUserID, item.Discount,
+			item.StylistID, item.Stylist, currency, item.Custom).Scan(&itemIDs[i], &item.StylistID, &item.Stylist)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range in.Discounts {
+		var itemID *int64
+		if d.ItemIndex != nil && *d.ItemIndex >= 0 && *d.ItemIndex < len(itemIDs) {
+			itemID = &itemIDs[*d.ItemIndex]
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO transaction_discounts (transaction_id, transaction_item_id, promotion_id, code, kind, scope, value, amount, note, created_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9, now())
+		`, id, itemID, d.PromotionID, d.Code, string(d.Kind), string(d.Scope), d.Value, d.Amount, d.Note)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tips := make([]domain.TransactionTip, 0, len(in.Tips))
+	for _, tip := range in.Tips {
+		t := domain.TransactionTip{TransactionID: id, Amount: domain.Money{Amount: tip.Amount}}
+		// Only keep the stylist id if it is one of the owner's employees; fall back to their name when none was sent.
+		err := tx.QueryRow(ctx, `
+			INSERT INTO transaction_tips (transaction_id, stylist_id, stylist, amount, created_at)
+			SELECT $1, e.id, COALESCE(NULLIF($3, ''), e.name, ''), $4, now()
+			FROM (SELECT 1) AS one
+			LEFT JOIN employees e ON e.id = $2 AND e.manager_user_id = $5 AND e.deleted_at IS NULL
+			RETURNING id, stylist_id, stylist, created_at
+		`, id, tip.StylistID, tip.Stylist, tip.Amount, ownerUserID).Scan(&t.ID, &t.StylistID, &t.Stylist, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tips = append(tips, t)
+	}
+
+	status := domain.TransactionPaid
+	payments := in.Payments
+	if len(payments) == 0 {
+		payments = []CreateTransactionPayment{{Method: in.PaymentMethod, Amount: in.Amount + tipTotal + in.Rounding, PaymentIntentID: in.PaymentIntentID}}
+	}
+	for _, p := range payments {
 		_, err := tx.Exec(ctx, `
-			INSERT INTO transaction_items (transaction_id, product_id, name, category, price, qty, created_at)
-			VALUES ($1,
-			        (SELECT id FROM products WHERE id=$2 AND owner_user_id=$7 AND deleted_at IS NULL),
-			        $3,$4,$5,$6, now())
-		`, id, item.ProductID, item.Name, item.Category, item.Price, item.Qty, ownerUserID)
+			INSERT INTO transaction_payments (transaction_id, method, amount, reference, payment_intent_id, created_at)
+			VALUES ($1,$2,$3,$4,$5, now())
+		`, id, p.Method, p.Amount, p.Reference, p.PaymentIntentID)
 		if err != nil {
 			return nil, err
 		}
+		if p.PaymentIntentID != nil {
+			intentStatus, err := attachPaymentIntentWithTx(ctx, tx, ownerUserID, *p.PaymentIntentID, id, p.Method, p.Amount)
+			if err != nil {
+				return nil, err
+			}
+			if intentStatus == domain.PaymentIntentPending {
+				status = domain.TransactionPending
+			}
+		}
+	}
+	// The sale waits for the payment webhook before it counts.
+	if status == domain.TransactionPending {
+		if _, err := tx.Exec(ctx, `UPDATE transactions SET status='pending' WHERE id=$1`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if customerID != nil {
+		if err := refreshCustomerStatsWithTx(ctx, tx, *customerID); err != nil {
+			return nil, err
+		}
 	}
 
 	if after != nil {
@@ -211,16 +644,26 @@ func (r TransactionRepository) Create(ctx context.Context, ownerUserID int64, in
 		return nil, err
 	}
 
-	return &domain.Transaction{
-		ID:            id,
-		Code:          code,
-		Date:          now,
-		Time:          now.Format("15:04"),
-		Amount:        domain.Money{Amount: in.Amount},
-		PaymentMethod: in.PaymentMethod,
-		Status:        domain.TransactionPaid,
-		Stylist:       in.Stylist,
-		StylistID:     in.StylistID,
+	created := &domain.Transaction{
+		ID:                id,
+		Code:              code,
+		ReceiptNumber:     receiptNumber,
+		Date:              now,
+		Time:              now.Format("15:04"),
+		Amount:            domain.Money{Amount: in.Amount},
+		Subtotal:          domain.Money{Amount: in.Subtotal},
+		DiscountTotal:     domain.Money{Amount: in.DiscountTotal},
+		ServiceCharge:     domain.Money{Amount: in.ServiceCharge},
+		TaxAmount:         domain.Money{Amount: in.TaxAmount},
+		TaxRate:           in.TaxRate,
+		TaxInclusive:      in.TaxInclusive,
+		PaymentMethod:     in.PaymentMethod,
+		PaymentIntentID:   paymentIntentID(in),
+		Status:            status,
+		Stylist:           in.Stylist,
+		StylistID:         in.StylistID,
+		ServiceChargeRate: in.ServiceChargeRate,
+		CustomerID:        customerID,
 		Customer: &domain.TransactionCustomerSnapshot{
 			Name:      in.CustomerName,
 			Phone:     in.CustomerPhone,
@@ -229,234 +672,110 @@ func (r TransactionRepository) Create(ctx context.Context, ownerUserID int64, in
 			Visits:    in.CustomerVisits,
 			LastVisit: in.CustomerLastVisit,
 		},
-		Items:     mapItems(in.Items),
+		Items:     mapItems(items, itemIDs),
+		Discounts: mapDiscounts(in.Discounts),
+		Payments:  mapPayments(id, payments),
+		TipTotal:  domain.Money{Amount: tipTotal},
+		Tips:      tips,
+		Rounding:  domain.Money{Amount: in.Rounding},
 		CreatedAt: now,
 		UpdatedAt: now,
-	}, nil
-}
-
-func (r TransactionRepository) getByClientRefWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, clientRef string) (*domain.Transaction, error) {
-	row := tx.QueryRow(ctx, `
-		SELECT id, code, transacted_date, transacted_time, amount, payment_method, status, stylist, stylist_id,
-		       customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
-		       shift_id, operator_name, refunded_at, refunded_by, refund_note, created_at, updated_at, deleted_at
-		FROM transactions
-		WHERE deleted_at IS NULL AND client_ref = $1 AND owner_user_id=$2
-		LIMIT 1
-	`, clientRef, ownerUserID)
-	var t domain.Transaction
-	var status string
-	var customerName, customerPhone, customerEmail, customerAddress pgtype.Text
-	var visits pgtype.Int4
-	var lastVisit pgtype.Text
-	var shiftID pgtype.Text
-	var opName pgtype.Text
-	var stylistID pgtype.Int8
-	var refundedAt pgtype.Timestamptz
-	var refundedBy pgtype.Int8
-	var refundNote pgtype.Text
-	var deletedAt pgtype.Timestamptz
-	if err := row.Scan(
-		&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.PaymentMethod, &status, &t.Stylist, &stylistID,
-		&customerName, &customerPhone, &customerEmail, &customerAddress, &visits, &lastVisit,
-		&shiftID, &opName, &refundedAt, &refundedBy, &refundNote, &t.CreatedAt, &t.UpdatedAt, &deletedAt,
-	); err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, err
-	}
-	if stylistID.Valid {
-		t.StylistID = &stylistID.Int64
-	}
-	t.Status = domain.TransactionStatus(status)
-	t.Customer = &domain.TransactionCustomerSnapshot{
-		Name:    customerName.String,
-		Phone:   customerPhone.String,
-		Email:   customerEmail.String,
-		Address: customerAddress.String,
-	}
-	if visits.Valid {
-		v := int(visits.Int32)
-		t.Customer.Visits = &v
-	}
-	if lastVisit.Valid {
-		lv := lastVisit.String
-		t.Customer.LastVisit = &lv
-	}
-	if shiftID.Valid {
-		s := shiftID.String
-		t.ShiftID = &s
-	}
-	if opName.Valid {
-		t.OperatorName = opName.String
-	}
-	if refundedAt.Valid {
-		rt := refundedAt.Time
-		t.RefundedAt = &rt
-	}
-	if refundedBy.Valid {
-		rb := refundedBy.Int64
-		t.RefundedBy = &rb
-	}
-	if refundNote.Valid {
-		t.RefundNote = refundNote.String
-	}
-	if deletedAt.Valid {
-		d := deletedAt.Time
-		t.DeletedAt = &d
 	}
+	created.Amount.Currency = currency
+	applyCurrency(created)
+	return created, nil
+}
 
-	itemRows, err := tx.Query(ctx, `
-		SELECT transaction_id, id, product_id, name, category, price, qty, created_at
-		FROM transaction_items
-		WHERE transaction_id=$1 AND deleted_at IS NULL
-	`, t.ID)
-	if err != nil {
-		return nil, err
-	}
-	defer itemRows.Close()
+// GetByClientRef returns the transaction recorded under a device-generated idempotency key.
+func (r TransactionRepository) GetByClientRef(ctx context.Context, ownerUserID int64, clientRef string) (*domain.Transaction, error) {
+	return r.getDetail(ctx, r.DB.Pool, "client_ref = $1 AND owner_user_id=$2", clientRef, ownerUserID)
+}
 
-	for itemRows.Next() {
-		var it domain.TransactionItem
-		var txID int64
-		if err := itemRows.Scan(&txID, &it.ID, &it.ProductID, &it.Name, &it.Category, &it.Price.Amount, &it.Qty, &it.CreatedAt); err != nil {
-			return nil, err
-		}
-		it.TransactionID = txID
-		t.Items = append(t.Items, it)
-	}
-	if err := itemRows.Err(); err != nil {
-		return nil, err
-	}
-	return &t, nil
+func (r TransactionRepository) getByClientRefWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, clientRef string) (*domain.Transaction, error) {
+	return r.getDetail(ctx, tx, "client_ref = $1 AND owner_user_id=$2", clientRef, ownerUserID)
 }
 
-func mapItems(items []CreateTransactionItem) []domain.TransactionItem {
+func mapItems(items []CreateTransactionItem, ids []int64) []domain.TransactionItem {
 	var out []domain.TransactionItem
-	for _, it := range items {
+	for i, it := range items {
 		out = append(out, domain.TransactionItem{
+			ID:        ids[i],
 			ProductID: it.ProductID,
 			Name:      it.Name,
 			Category:  it.Category,
 			Price:     domain.Money{Amount: it.Price},
 			Qty:       it.Qty,
+			Discount:  domain.Money{Amount: it.Discount},
+			StylistID: it.StylistID,
+			Stylist:   it.Stylist,
+			Custom:    it.Custom,
 		})
 	}
 	return out
 }
 
-func (r TransactionRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]domain.Transaction, error) {
-	rows, err := r.DB.Pool.Query(ctx, `
-		SELECT id, code, transacted_date, transacted_time, amount, payment_method, status, stylist, stylist_id,
-		       customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
-		       shift_id, operator_name, refunded_at, refunded_by, refund_note, created_at, updated_at
-		FROM transactions
-		WHERE deleted_at IS NULL AND owner_user_id=$1
-		ORDER BY transacted_date DESC, id DESC
-		LIMIT $2
-	`, ownerUserID, limit)
-	if err != nil {
-		return nil, err
+func mapDiscounts(discounts []CreateTransactionDiscount) []domain.TransactionDiscount {
+	var out []domain.TransactionDiscount
+	for _, d := range discounts {
+		out = append(out, domain.TransactionDiscount{
+			PromotionID: d.PromotionID,
+			Code:        d.Code,
+			Kind:        d.Kind,
+			Scope:       d.Scope,
+			Value:       d.Value,
+			Amount:      domain.Money{Amount: d.Amount},
+			Note:        d.Note,
+		})
 	}
-	defer rows.Close()
+	return out
+}
 
-	var txs []domain.Transaction
-	var ids []int64
-	for rows.Next() {
-		var t domain.Transaction
-		var status string
-		var customerName, customerPhone, customerEmail, customerAddress pgtype.Text
-		var visits pgtype.Int4
-		var lastVisit pgtype.Text
-		var shiftID pgtype.Text
-		var opName pgtype.Text
-		var stylistID pgtype.Int8
-		var refundedAt pgtype.Timestamptz
-		var refundedBy pgtype.Int8
-		var refundNote pgtype.Text
-		if err := rows.Scan(
-			&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.PaymentMethod, &status, &t.Stylist, &stylistID,
-			&customerName, &customerPhone, &customerEmail, &customerAddress, &visits, &lastVisit,
-			&shiftID, &opName, &refundedAt, &refundedBy, &refundNote, &t.CreatedAt, &t.UpdatedAt,
-		); err != nil {
-			return nil, err
+// paymentIntentID is the intent recorded on the transaction itself: the single tender's, or the only one among
+// split tenders.
+func paymentIntentID(in CreateTransactionInput) *string {
+	if len(in.Payments) == 0 {
+		return in.PaymentIntentID
+	}
+	var id *string
+	for _, p := range in.Payments {
+		if p.PaymentIntentID == nil {
+			continue
 		}
-		if stylistID.Valid {
-			t.StylistID = &stylistID.Int64
+		if id != nil {
+			return nil
 		}
-		t.Status = domain.TransactionStatus(status)
-		t.Customer = &domain.TransactionCustomerSnapshot{
-			Name:    customerName.String,
-			Phone:   customerPhone.String,
-			Email:   customerEmail.String,
-			Address: customerAddress.String,
-		}
-		if visits.Valid {
-			v := int(visits.Int32)
-			t.Customer.Visits = &v
-		}
-		if lastVisit.Valid {
-			lv := lastVisit.String
-			t.Customer.LastVisit = &lv
-		}
-		if refundedAt.Valid {
-			rt := refundedAt.Time
-			t.RefundedAt = &rt
-		}
-		if refundedBy.Valid {
-			rb := refundedBy.Int64
-			t.RefundedBy = &rb
-		}
-		if refundNote.Valid {
-			t.RefundNote = refundNote.String
-		}
-		ids = append(ids, t.ID)
-		txs = append(txs, t)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	if len(ids) == 0 {
-		return txs, nil
-	}
-
-	itemRows, err := r.DB.Pool.Query(ctx, `
-		SELECT transaction_id, id, product_id, name, category, price, qty, created_at
-		FROM transaction_items
-		WHERE transaction_id = ANY($1)
-	`, ids)
-	if err != nil {
-		return nil, err
-	}
-	defer itemRows.Close()
-
-	itemsByTx := make(map[int64][]domain.TransactionItem)
-	for itemRows.Next() {
-		var it domain.TransactionItem
-		var txID int64
-		if err := itemRows.Scan(&txID, &it.ID, &it.ProductID, &it.Name, &it.Category, &it.Price.Amount, &it.Qty, &it.CreatedAt); err != nil {
-			return nil, err
-		}
-		itemsByTx[txID] = append(itemsByTx[txID], it)
-	}
-	if err := itemRows.Err(); err != nil {
-		return nil, err
+		id = p.PaymentIntentID
 	}
+	return id
+}
 
-	for i := range txs {
-		txs[i].Items = itemsByTx[txs[i].ID]
+func mapPayments(transactionID int64, payments []CreateTransactionPayment) []domain.TransactionPayment {
+	var out []domain.TransactionPayment
+	for _, p := range payments {
+		out = append(out, domain.TransactionPayment{
+			TransactionID:   transactionID,
+			Method:          p.Method,
+			Amount:          domain.Money{Amount: p.Amount},
+			Reference:       p.Reference,
+			PaymentIntentID: p.PaymentIntentID,
+		})
 	}
+	return out
+}
 
-	return txs, nil
+func (r TransactionRepository) List(ctx context.Context, ownerUserID int64, limit int) ([]domain.Transaction, error) {
+	return r.listWhere(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions
+		WHERE deleted_at IS NULL AND owner_user_id=$1
+		ORDER BY transacted_date DESC, id DESC
+		LIMIT $2
+	`, ownerUserID, limit)
 }
 
 func (r TransactionRepository) ListFiltered(ctx context.Context, ownerUserID int64, startDate, endDate *time.Time) ([]domain.Transaction, error) {
 	query := `
-		SELECT id, code, transacted_date, transacted_time, amount, payment_method, status, stylist, stylist_id,
-		       customer_name, customer_phone, customer_email, customer_address, customer_visits, customer_last_visit,
-		       shift_id, operator_name, refunded_at, refunded_by, refund_note, created_at, updated_at
+		SELECT ` + transactionColumns + `
 		FROM transactions
 		WHERE deleted_at IS NULL AND owner_user_id = $1
 	`
@@ -472,143 +791,168 @@ func (r TransactionRepository) ListFiltered(ctx context.Context, ownerUserID int
 	}
 	query += " ORDER BY transacted_date DESC, id DESC"
 
+	return r.listWhere(ctx, query, args...)
+}
+
+// TipPayout is what one stylist is owed in tips over a period.
+type TipPayout struct {
+	StylistID *int64
+	Stylist   string
+	Amount    int64
+	Count     int64
+}
+
+// TipPayouts totals tips per stylist for transactions that were not fully refunded, biggest first.
+func (r TransactionRepository) TipPayouts(ctx context.Context, ownerUserID int64, startDate, endDate *time.Time) ([]TipPayout, error) {
+	if _, err := salesCurrency(ctx, r.DB.Pool, ownerUserID, startDate, endDate, r.DefaultCurrency); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT tp.stylist_id, tp.stylist, COALESCE(SUM(tp.amount),0) AS amount, COUNT(DISTINCT tp.transaction_id) AS cnt
+		FROM transaction_tips tp
+		JOIN transactions t ON t.id = tp.transaction_id
+		WHERE t.deleted_at IS NULL AND t.owner_user_id = $1 AND t.status IN ('paid','partially_refunded')
+	`
+	args := []any{ownerUserID}
+	if startDate != nil {
+		query += fmt.Sprintf(" AND t.transacted_date >= $%d", len(args)+1)
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endDate != nil {
+		query += fmt.Sprintf(" AND t.transacted_date <= $%d", len(args)+1)
+		args = append(args, endDate.Format("2006-01-02"))
+	}
+	query += " GROUP BY tp.stylist_id, tp.stylist ORDER BY amount DESC, tp.stylist ASC"
+
 	rows, err := r.DB.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var txs []domain.Transaction
-	var ids []int64
+	var out []TipPayout
 	for rows.Next() {
-		var t domain.Transaction
-		var status string
-		var customerName, customerPhone, customerEmail, customerAddress pgtype.Text
-		var visits pgtype.Int4
-		var lastVisit pgtype.Text
-		var shiftID pgtype.Text
-		var opName pgtype.Text
-		var stylistID pgtype.Int8
-		var refundedAt pgtype.Timestamptz
-		var refundedBy pgtype.Int8
-		var refundNote pgtype.Text
-		if err := rows.Scan(
-			&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.PaymentMethod, &status, &t.Stylist, &stylistID,
-			&customerName, &customerPhone, &customerEmail, &customerAddress, &visits, &lastVisit,
-			&shiftID, &opName, &refundedAt, &refundedBy, &refundNote, &t.CreatedAt, &t.UpdatedAt,
-		); err != nil {
+		var p TipPayout
+		if err := rows.Scan(&p.StylistID, &p.Stylist, &p.Amount, &p.Count); err != nil {
 			return nil, err
 		}
-		if stylistID.Valid {
-			t.StylistID = &stylistID.Int64
-		}
-		t.Status = domain.TransactionStatus(status)
-		t.Customer = &domain.TransactionCustomerSnapshot{
-			Name:    customerName.String,
-			Phone:   customerPhone.String,
-			Email:   customerEmail.String,
-			Address: customerAddress.String,
-		}
-		if visits.Valid {
-			v := int(visits.Int32)
-			t.Customer.Visits = &v
-		}
-		if lastVisit.Valid {
-			lv := lastVisit.String
-			t.Customer.LastVisit = &lv
-		}
-		if refundedAt.Valid {
-			rt := refundedAt.Time
-			t.RefundedAt = &rt
-		}
-		if refundedBy.Valid {
-			rb := refundedBy.Int64
-			t.RefundedBy = &rb
-		}
-		if refundNote.Valid {
-			t.RefundNote = refundNote.String
-		}
-		ids = append(ids, t.ID)
-		txs = append(txs, t)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		out = append(out, p)
 	}
+	return out, rows.Err()
+}
 
-	if len(ids) == 0 {
-		return txs, nil
+func (r TransactionRepository) MarkPaidByCode(ctx context.Context, ownerUserID int64, code string) error {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := r.MarkPaidByCodeWithTx(ctx, tx, ownerUserID, code); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
+}
 
-	itemRows, err := r.DB.Pool.Query(ctx, `
-		SELECT transaction_id, id, product_id, name, category, price, qty, created_at
-		FROM transaction_items
-		WHERE transaction_id = ANY($1)
-	`, ids)
+// ErrTransactionVoided is returned when undoing a refund on a transaction that was voided; voids are final.
+var ErrTransactionVoided = errors.New("transaction is voided")
+
+// ErrTransactionFailed is returned when marking paid a transaction whose payment failed; what it consumed was
+// already put back.
+var ErrTransactionFailed = errors.New("transaction payment failed")
+
+// MarkPaidByCodeWithTx undoes every refund on a transaction: the refund records are kept but marked reversed.
+// It returns what those refunds had given back, summed per item, so the caller can take the stock and
+// membership units again in the same database transaction.
+func (r TransactionRepository) MarkPaidByCodeWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, code string) (*domain.Refund, error) {
+	var id int64
+	var status string
+	err := tx.QueryRow(ctx, `
+		SELECT id, status
+		FROM transactions
+		WHERE (code = $1 OR receipt_number = $1) AND owner_user_id=$2
+		FOR UPDATE
+	`, code, ownerUserID).Scan(&id, &status)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
-	defer itemRows.Close()
-
-	itemsByTx := make(map[int64][]domain.TransactionItem)
-	for itemRows.Next() {
-		var it domain.TransactionItem
-		var txID int64
-		if err := itemRows.Scan(&txID, &it.ID, &it.ProductID, &it.Name, &it.Category, &it.Price.Amount, &it.Qty, &it.CreatedAt); err != nil {
+	switch domain.TransactionStatus(status) {
+	case domain.TransactionVoid:
+		return nil, ErrTransactionVoided
+	case domain.TransactionFailed:
+		return nil, ErrTransactionFailed
+	}
+	reversed := domain.Refund{TransactionID: id}
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount),0), COALESCE(SUM(units),0)
+		FROM transaction_refunds
+		WHERE transaction_id=$1 AND reversed_at IS NULL
+	`, id).Scan(&reversed.Amount.Amount, &reversed.Units)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT ri.transaction_item_id, ti.product_id, SUM(ri.qty)
+		FROM transaction_refund_items ri
+		JOIN transaction_refunds rf ON rf.id = ri.refund_id
+		JOIN transaction_items ti ON ti.id = ri.transaction_item_id
+		WHERE rf.transaction_id=$1 AND rf.reversed_at IS NULL
+		GROUP BY ri.transaction_item_id, ti.product_id
+		ORDER BY ri.transaction_item_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l domain.RefundLine
+		if err := rows.Scan(&l.TransactionItemID, &l.ProductID, &l.Qty); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		itemsByTx[txID] = append(itemsByTx[txID], it)
+		reversed.Items = append(reversed.Items, l)
 	}
-	if err := itemRows.Err(); err != nil {
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	for i := range txs {
-		txs[i].Items = itemsByTx[txs[i].ID]
-	}
-
-	return txs, nil
-}
-
-func (r TransactionRepository) MarkPaidByCode(ctx context.Context, ownerUserID int64, code string) error {
-	ct, err := r.DB.Pool.Exec(ctx, `
+	_, err = tx.Exec(ctx, `
 		UPDATE transactions
 		SET status='paid',
 		    refunded_at=NULL,
 		    refunded_by=NULL,
 		    refund_note='',
+		    refunded_amount=0,
 		    deleted_at=NULL,
 		    updated_at=now()
-		WHERE code=$1 AND owner_user_id=$2
-	`, code, ownerUserID)
+		WHERE id=$1
+	`, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if ct.RowsAffected() == 0 {
-		return ErrNotFound
+	if _, err := tx.Exec(ctx, `UPDATE transaction_items SET refunded_qty=0 WHERE transaction_id=$1`, id); err != nil {
+		return nil, err
 	}
-	return nil
+	if _, err := tx.Exec(ctx, `UPDATE transaction_refunds SET reversed_at=now() WHERE transaction_id=$1 AND reversed_at IS NULL`, id); err != nil {
+		return nil, err
+	}
+	if err := refreshTransactionCustomerWithTx(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	return &reversed, nil
 }
 
-func (r TransactionRepository) MarkPaidByCodeWithTx(ctx context.Context, tx pgx.Tx, ownerUserID int64, code string) (int64, error) {
-	var id int64
-	err := tx.QueryRow(ctx, `
-		UPDATE transactions
-		SET status='paid',
-		    refunded_at=NULL,
-		    refunded_by=NULL,
-		    refund_note='',
-		    deleted_at=NULL,
-		    updated_at=now()
-		WHERE code=$1 AND owner_user_id=$2
-		RETURNING id
-	`, code, ownerUserID).Scan(&id)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return 0, ErrNotFound
-		}
-		return 0, err
-	}
-	return id, nil
+// ErrNothingToRefund is returned when a refund request covers nothing that is still refundable.
+var ErrNothingToRefund = errors.New("nothing left to refund")
+
+// RefundInvalidError reports a refund request that does not fit the transaction (unknown line, too many units, amount too large).
+type RefundInvalidError struct {
+	Message string
+}
+
+func (e *RefundInvalidError) Error() string {
+	return e.Message
 }
 
 type RefundTransactionParams struct {
@@ -616,89 +960,211 @@ type RefundTransactionParams struct {
 	Note       string
 	Delete     bool
 	RefundedBy *int64
+	// Lines refunds specific item quantities; Amount refunds a plain amount with no items.
+	// With neither set, everything still refundable is refunded.
+	Lines  []RefundLineInput
+	Amount int64
 }
 
-type RefundItem struct {
-	ProductID *int64
-	Qty       int
+type RefundLineInput struct {
+	ItemID int64
+	Qty    int
+}
+
+type refundableItem struct {
+	ID          int64
+	ProductID   *int64
+	Net         int64
+	Qty         int
+	RefundedQty int
 }
 
-func (r TransactionRepository) RefundByCode(ctx context.Context, ownerUserID int64, in RefundTransactionParams, after func(context.Context, pgx.Tx, domain.Transaction, []RefundItem, int) error) error {
+// RefundByCode records one refund against a transaction and moves it to partially_refunded or refund.
+// Item refunds are priced as the refunded share of the lines' net value applied to the amount charged,
+// so tax and service charge come back in proportion. The callback receives the refund (lines and units)
+// to reverse stock, finance and membership inside the same database transaction.
+func (r TransactionRepository) RefundByCode(ctx context.Context, ownerUserID int64, in RefundTransactionParams, after func(context.Context, pgx.Tx, domain.Transaction, domain.Refund) error) (*domain.Refund, error) {
 	tx, err := r.DB.Pool.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
 	row := tx.QueryRow(ctx, `
-		SELECT id, code, transacted_date, transacted_time, amount, payment_method, status
+		SELECT id, code, transacted_date, transacted_time, amount, refunded_amount, payment_method, status
 		FROM transactions
-		WHERE code=$1 AND owner_user_id=$2
+		WHERE (code = $1 OR receipt_number = $1) AND owner_user_id=$2
 		FOR UPDATE
 	`, in.Code, ownerUserID)
 	var t domain.Transaction
 	var status string
-	if err := row.Scan(&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.PaymentMethod, &status); err != nil {
+	if err := row.Scan(&t.ID, &t.Code, &t.Date, &t.Time, &t.Amount.Amount, &t.RefundedAmount.Amount, &t.PaymentMethod, &status); err != nil {
 		if err == pgx.ErrNoRows {
-			return ErrNotFound
+			return nil, ErrNotFound
 		}
-		return err
+		return nil, err
 	}
 	t.Status = domain.TransactionStatus(status)
+	switch t.Status {
+	case domain.TransactionVoid:
+		return nil, &RefundInvalidError{Message: "voided transactions cannot be refunded"}
+	case domain.TransactionPending, domain.TransactionFailed:
+		return nil, &RefundInvalidError{Message: "transaction has not been paid"}
+	}
 
 	itemRows, err := tx.Query(ctx, `
-		SELECT product_id, qty
+		SELECT id, product_id, price*qty - discount, qty, refunded_qty
 		FROM transaction_items
 		WHERE transaction_id=$1 AND deleted_at IS NULL
+		ORDER BY id ASC
 	`, t.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	units := 0
-	var items []RefundItem
+	var items []refundableItem
 	for itemRows.Next() {
-		var productID pgtype.Int8
-		var qty int
-		if err := itemRows.Scan(&productID, &qty); err != nil {
+		var it refundableItem
+		if err := itemRows.Scan(&it.ID, &it.ProductID, &it.Net, &it.Qty, &it.RefundedQty); err != nil {
 			itemRows.Close()
-			return err
-		}
-		units += qty
-		var pid *int64
-		if productID.Valid {
-			pid = &productID.Int64
+			return nil, err
 		}
-		items = append(items, RefundItem{ProductID: pid, Qty: qty})
+		items = append(items, it)
 	}
 	if err := itemRows.Err(); err != nil {
 		itemRows.Close()
-		return err
+		return nil, err
 	}
 	itemRows.Close()
-	if units == 0 {
-		units = 1
+
+	remaining := t.Amount.Amount - t.RefundedAmount.Amount
+	if remaining <= 0 && t.Status == domain.TransactionRefund {
+		return nil, ErrNothingToRefund
+	}
+
+	refund := domain.Refund{TransactionID: t.ID, Note: in.Note, RefundedBy: in.RefundedBy}
+	qtyByItem := make(map[int64]int)
+	switch {
+	case len(in.Lines) > 0:
+		byID := make(map[int64]refundableItem, len(items))
+		for _, it := range items {
+			byID[it.ID] = it
+		}
+		for _, l := range in.Lines {
+			it, ok := byID[l.ItemID]
+			if !ok {
+				return nil, &RefundInvalidError{Message: fmt.Sprintf("item %d is not part of this transaction", l.ItemID)}
+			}
+			if l.Qty <= 0 {
+				return nil, &RefundInvalidError{Message: "refund qty must be positive"}
+			}
+			qtyByItem[it.ID] += l.Qty
+			if it.RefundedQty+qtyByItem[it.ID] > it.Qty {
+				return nil, &RefundInvalidError{Message: fmt.Sprintf("item %d has only %d units left to refund", it.ID, it.Qty-it.RefundedQty)}
+			}
+		}
+	case in.Amount > 0:
+		if in.Amount > remaining {
+			return nil, &RefundInvalidError{Message: fmt.Sprintf("refund amount exceeds the %d still refundable", remaining)}
+		}
+		refund.Amount.Amount = in.Amount
+	case in.Amount < 0:
+		return nil, &RefundInvalidError{Message: "refund amount must be positive"}
+	default:
+		for _, it := range items {
+			if left := it.Qty - it.RefundedQty; left > 0 {
+				qtyByItem[it.ID] = left
+			}
+		}
+		refund.Amount.Amount = remaining
+	}
+
+	if len(qtyByItem) > 0 {
+		var netTotal, netRefundedAfter int64
+		allRefunded := true
+		for _, it := range items {
+			after := it.RefundedQty + qtyByItem[it.ID]
+			netTotal += it.Net
+			netRefundedAfter += it.Net * int64(after) / int64(it.Qty)
+			if after < it.Qty {
+				allRefunded = false
+			}
+			if q := qtyByItem[it.ID]; q > 0 {
+				refund.Items = append(refund.Items, domain.RefundLine{TransactionItemID: it.ID, ProductID: it.ProductID, Qty: q})
+				refund.Units += q
+			}
+		}
+		if len(in.Lines) > 0 {
+			switch {
+			case allRefunded || netTotal <= 0:
+				refund.Amount.Amount = remaining
+			default:
+				refund.Amount.Amount = t.Amount.Amount*netRefundedAfter/netTotal - t.RefundedAmount.Amount
+			}
+		}
+		if refund.Amount.Amount < 0 {
+			refund.Amount.Amount = 0
+		}
+		if refund.Amount.Amount > remaining {
+			refund.Amount.Amount = remaining
+		}
+	}
+	if refund.Amount.Amount == 0 && len(refund.Items) == 0 {
+		return nil, ErrNothingToRefund
 	}
 
-	// Keep transaction for audit; default behavior from UI is "refund & delete".
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transaction_refunds (transaction_id, amount, units, note, refunded_by, created_at)
+		VALUES ($1,$2,$3,$4,$5, now())
+		RETURNING id, created_at
+	`, t.ID, refund.Amount.Amount, refund.Units, refund.Note, refund.RefundedBy).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range refund.Items {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO transaction_refund_items (refund_id, transaction_item_id, qty) VALUES ($1,$2,$3)
+		`, refund.ID, l.TransactionItemID, l.Qty); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE transaction_items SET refunded_qty = refunded_qty + $1 WHERE id=$2
+		`, l.Qty, l.TransactionItemID); err != nil {
+			return nil, err
+		}
+	}
+
+	t.RefundedAmount.Amount += refund.Amount.Amount
+	t.Status = domain.TransactionPartiallyRefunded
+	if t.RefundedAmount.Amount >= t.Amount.Amount {
+		t.Status = domain.TransactionRefund
+	}
+	// Keep transaction for audit; default behavior from UI is "refund & delete", which only applies once fully refunded.
 	_, err = tx.Exec(ctx, `
 		UPDATE transactions
-		SET status='refund',
+		SET status=$1,
+		    refunded_amount=$2,
 		    refunded_at=now(),
-		    refunded_by=$1,
-		    refund_note=$2,
-		    deleted_at=CASE WHEN $3 THEN now() ELSE NULL END,
+		    refunded_by=$3,
+		    refund_note=$4,
+		    deleted_at=CASE WHEN $5 AND $1 = 'refund' THEN now() ELSE NULL END,
 		    updated_at=now()
-		WHERE id=$4
-	`, in.RefundedBy, in.Note, in.Delete, t.ID)
+		WHERE id=$6
+	`, string(t.Status), t.RefundedAmount.Amount, in.RefundedBy, in.Note, in.Delete, t.ID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := refreshTransactionCustomerWithTx(ctx, tx, t.ID); err != nil {
+		return nil, err
 	}
 
 	if after != nil {
-		if err := after(ctx, tx, t, items, units); err != nil {
-			return err
+		if err := after(ctx, tx, t, refund); err != nil {
+			return nil, err
 		}
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &refund, nil
 }
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"barberpos-backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// VoidRejectedError reports why a transaction can no longer be voided (already refunded, another day, shift closed).
+type VoidRejectedError struct {
+	Message string
+}
+
+func (e *VoidRejectedError) Error() string {
+	return e.Message
+}
+
+type VoidTransactionParams struct {
+	Code       string
+	Reason     string
+	Note       string
+	VoidedBy   *int64
+	ApprovedBy *int64
+	// ShiftID is the caller's current shift; when both it and the transaction's shift are known they must match.
+	// Unless the owner's shift policy is off it is required, and must be the sale's shift, still open.
+	ShiftID string
+}
+
+// VoidByCode cancels a sale made today, in a shift that has not been closed (closed in the shift itself or by a
+// closing), and that has not been refunded.
+// The callback receives the transaction with its items and discounts to put back stock, membership quota
+// and promotion uses inside the same database transaction.
+func (r TransactionRepository) VoidByCode(ctx context.Context, ownerUserID int64, in VoidTransactionParams, after func(context.Context, pgx.Tx, domain.Transaction) error) (*domain.Transaction, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	today, err := businessToday(ctx, tx, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	var sameDay bool
+	err = tx.QueryRow(ctx, `
+		SELECT id, transacted_date = $3::date
+		FROM transactions
+		WHERE (code = $1 OR receipt_number = $1) AND owner_user_id=$2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, in.Code, ownerUserID, today.Format("2006-01-02")).Scan(&id, &sameDay)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	t, err := r.getDetail(ctx, tx, "id=$1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.Status == domain.TransactionVoid:
+		return nil, &VoidRejectedError{Message: "transaction is already voided"}
+	case t.Status == domain.TransactionPending:
+		return nil, &VoidRejectedError{Message: "transaction is waiting for its payment; cancel the payment instead"}
+	case t.Status == domain.TransactionFailed:
+		return nil, &VoidRejectedError{Message: "transaction payment failed"}
+	case t.Status != domain.TransactionPaid || t.RefundedAmount.Amount > 0:
+		return nil, &VoidRejectedError{Message: "refunded transactions cannot be voided"}
+	case !sameDay:
+		return nil, &VoidRejectedError{Message: "only transactions from today can be voided; use a refund instead"}
+	}
+
+	// With shifts enforced, the cashier voids from the sale's own shift while it is open.
+	var policy string
+	err = tx.QueryRow(ctx, `SELECT shift_policy FROM settings WHERE owner_user_id=$1`, ownerUserID).Scan(&policy)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	shiftRequired := policy != "" && domain.ShiftPolicy(policy) != domain.ShiftPolicyOff
+	saleShift := ""
+	if t.ShiftID != nil {
+		saleShift = strings.TrimSpace(*t.ShiftID)
+	}
+	switch {
+	case shiftRequired && saleShift == "":
+		return nil, &VoidRejectedError{Message: "transaction was not recorded in a shift; use a refund instead"}
+	case shiftRequired && in.ShiftID == "":
+		return nil, &VoidRejectedError{Message: "shiftId is required to void"}
+	}
+	if saleShift != "" {
+		if in.ShiftID != "" && !strings.EqualFold(in.ShiftID, saleShift) {
+			return nil, &VoidRejectedError{Message: "transaction belongs to another shift; use a refund instead"}
+		}
+		var closedByClosing bool
+		var shiftStatus *string
+		if err := tx.QueryRow(ctx, `
+			SELECT
+				EXISTS (
+					SELECT 1 FROM closing_history
+					WHERE owner_user_id=$1 AND shift_id=$2 AND deleted_at IS NULL
+				),
+				(SELECT status FROM shifts WHERE owner_user_id=$1 AND id=$2)
+		`, ownerUserID, saleShift).Scan(&closedByClosing, &shiftStatus); err != nil {
+			return nil, err
+		}
+		switch {
+		case closedByClosing || (shiftStatus != nil && domain.ShiftStatus(*shiftStatus) == domain.ShiftClosed):
+			return nil, &VoidRejectedError{Message: "shift is already closed; use a refund instead"}
+		case shiftRequired && shiftStatus == nil:
+			return nil, &VoidRejectedError{Message: "transaction's shift is unknown; use a refund instead"}
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE transactions
+		SET status='void',
+		    voided_at=now(),
+		    voided_by=$2,
+		    void_approved_by=$3,
+		    void_reason=$4,
+		    void_note=$5,
+		    updated_at=now()
+		WHERE id=$1
+		RETURNING voided_at, updated_at
+	`, t.ID, in.VoidedBy, in.ApprovedBy, in.Reason, in.Note).Scan(&t.VoidedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// A voided sale was never a visit.
+	if err := refreshTransactionCustomerWithTx(ctx, tx, t.ID); err != nil {
+		return nil, err
+	}
+	t.Status = domain.TransactionVoid
+	t.VoidedBy = in.VoidedBy
+	t.VoidApprovedBy = in.ApprovedBy
+	t.VoidReason = in.Reason
+	t.VoidNote = in.Note
+
+	if after != nil {
+		if err := after(ctx, tx, *t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+)
+
+func TestVoidByCodeShiftRules(t *testing.T) {
+	pg := testDB(t)
+	ctx := context.Background()
+	txs := TransactionRepository{DB: pg}
+	shifts := ShiftRepository{DB: pg}
+	settings := SettingsRepository{DB: pg}
+
+	setPolicy := func(owner int64, policy domain.ShiftPolicy) {
+		t.Helper()
+		s, err := settings.Get(ctx, owner)
+		if err != nil {
+			t.Fatalf("get settings: %v", err)
+		}
+		s.ShiftPolicy = policy
+		if _, err := settings.Save(ctx, owner, *s); err != nil {
+			t.Fatalf("save settings: %v", err)
+		}
+	}
+	openShift := func(owner int64, terminal string) string {
+		t.Helper()
+		s, err := shifts.Open(ctx, owner, OpenShiftInput{TerminalID: terminal})
+		if err != nil {
+			t.Fatalf("open shift: %v", err)
+		}
+		return s.ID
+	}
+	sell := func(owner int64, shiftID string) string {
+		t.Helper()
+		time.Sleep(2 * time.Millisecond) // codes are minted from the clock in milliseconds
+		in := CreateTransactionInput{
+			PaymentMethod: "cash",
+			Amount:        50000,
+			Subtotal:      50000,
+			Items:         []CreateTransactionItem{{Name: "Haircut", Price: 50000, Qty: 1}},
+		}
+		if shiftID != "" {
+			in.ShiftID = &shiftID
+		}
+		sale, err := txs.Create(ctx, owner, in, nil)
+		if err != nil {
+			t.Fatalf("create sale: %v", err)
+		}
+		return sale.Code
+	}
+	void := func(owner int64, code, shiftID string) error {
+		_, err := txs.VoidByCode(ctx, owner, VoidTransactionParams{Code: code, Reason: "wrong_item", ShiftID: shiftID}, nil)
+		return err
+	}
+
+	t.Run("policy off", func(t *testing.T) {
+		owner := testOwner(t, pg)
+		a := openShift(owner, "t1")
+		b := openShift(owner, "t2")
+
+		if err := void(owner, sell(owner, a), a); err != nil {
+			t.Errorf("same open shift: %v", err)
+		}
+		if err := void(owner, sell(owner, ""), ""); err != nil {
+			t.Errorf("sale without a shift: %v", err)
+		}
+		var rejected *VoidRejectedError
+		if err := void(owner, sell(owner, a), b); !errors.As(err, &rejected) {
+			t.Errorf("other shift: err = %v, want rejected", err)
+		}
+		code := sell(owner, a)
+		if _, err := shifts.Close(ctx, owner, a, nil); err != nil {
+			t.Fatalf("close shift: %v", err)
+		}
+		if err := void(owner, code, ""); !errors.As(err, &rejected) {
+			t.Errorf("shift closed with POST /shifts/{id}/close: err = %v, want rejected", err)
+		}
+	})
+
+	t.Run("policy enforced", func(t *testing.T) {
+		owner := testOwner(t, pg)
+		setPolicy(owner, domain.ShiftPolicyReject)
+		a := openShift(owner, "t1")
+
+		var rejected *VoidRejectedError
+		if err := void(owner, sell(owner, a), ""); !errors.As(err, &rejected) {
+			t.Errorf("no shiftId sent: err = %v, want rejected", err)
+		}
+		if err := void(owner, sell(owner, ""), a); !errors.As(err, &rejected) {
+			t.Errorf("sale without a shift: err = %v, want rejected", err)
+		}
+		if err := void(owner, sell(owner, "sh_from_the_device"), "sh_from_the_device"); !errors.As(err, &rejected) {
+			t.Errorf("unknown shift: err = %v, want rejected", err)
+		}
+		if err := void(owner, sell(owner, a), a); err != nil {
+			t.Errorf("same open shift: %v", err)
+		}
+	})
+}
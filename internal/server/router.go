@@ -41,6 +41,10 @@ func NewRouter(cfg config.Config,
 	employees handler.EmployeeHandler,
 	docs handler.DocsHandler,
 	home handler.HomeHandler,
+	promotions handler.PromotionHandler,
+	printJobs handler.PrintJobHandler,
+	cashDrawers handler.CashDrawerHandler,
+	shifts handler.ShiftHandler,
 ) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
@@ -52,7 +56,7 @@ func NewRouter(cfg config.Config,
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "X-Truncated", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -63,6 +67,7 @@ func NewRouter(cfg config.Config,
 	home.RegisterRoutes(r)
 	regions.RegisterRoutes(r)
 	docs.RegisterRoutes(r)
+	payments.RegisterWebhookRoutes(r)
 	r.Method("GET", "/metrics", promhttp.Handler())
 
 	// Public uploads (local storage). Keep it outside auth so Image.network can load without headers.
@@ -82,11 +87,14 @@ func NewRouter(cfg config.Config,
 			attendance.RegisterRoutes(sr)
 			payments.RegisterRoutes(sr)
 			closing.RegisterRoutes(sr)
+			cashDrawers.RegisterRoutes(sr)
+			shifts.RegisterRoutes(sr)
 			logs.RegisterRoutes(sr)
 			qris.RegisterStaffRoutes(sr)
 			membership.RegisterStaffRoutes(sr)
 			fcm.RegisterRoutes(sr)
 			notifications.RegisterRoutes(sr)
+			printJobs.RegisterRoutes(sr)
 		})
 		// manager-level (manager/admin)
 		pr.Group(func(mr chi.Router) {
@@ -95,10 +103,17 @@ func NewRouter(cfg config.Config,
 			productsAdmin.RegisterRoutes(mr)
 			settings.RegisterRoutes(mr)
 			qris.RegisterManagerRoutes(mr)
+			payments.RegisterManagerRoutes(mr)
 			finance.RegisterRoutes(mr)
 			membership.RegisterManagerRoutes(mr)
 			stocks.RegisterRoutes(mr)
 			employees.RegisterRoutes(mr)
+			promotions.RegisterRoutes(mr)
+		})
+		// admin-only: data across owners
+		pr.Group(func(ar chi.Router) {
+			ar.Use(RequireRole(domain.RoleAdmin))
+			payments.RegisterAdminRoutes(ar)
 		})
 	})
 
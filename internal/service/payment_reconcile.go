@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/money"
+	"barberpos-backend/internal/ports"
+	"barberpos-backend/internal/repository"
+)
+
+// Run reconciles payment intents with the provider until ctx is cancelled, for payments whose webhook never
+// arrives or arrives late.
+func (s PaymentService) Run(ctx context.Context) {
+	interval := s.ReconcileInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile makes one pass: pending intents are checked with the provider, those past expiresAt are
+// withdrawn and marked expired (cancelling the draft they were opened for), and paid intents that no sale
+// records are reported to the owner.
+func (s PaymentService) Reconcile(ctx context.Context) {
+	pending, err := s.Intents.ListPending(ctx, s.Provider.Name(), 50)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.Logger.Warn("payment reconcile list failed", "err", err)
+		}
+		return
+	}
+	for _, p := range pending {
+		if err := s.reconcileIntent(ctx, p); err != nil && ctx.Err() == nil {
+			s.Logger.Warn("payment reconcile failed", "intent", p.ID, "err", err)
+		}
+	}
+	s.reportUnrecorded(ctx)
+}
+
+func (s PaymentService) reconcileIntent(ctx context.Context, p repository.OwnedPaymentIntent) error {
+	expired := time.Now().After(p.ExpiresAt)
+	ours := p.Provider == s.Provider.Name() && p.ProviderRef != ""
+	state := ports.PaymentState{Status: domain.PaymentIntentPending}
+	if ours {
+		var err error
+		if state, err = s.Provider.GetStatus(ctx, p.ProviderRef); err != nil {
+			return err
+		}
+	}
+	if state.Status == domain.PaymentIntentPending && expired {
+		// Withdraw the code at the provider so a late scan cannot pay it; whatever it answers short of
+		// paid, the intent is over.
+		if ours {
+			if cancelled, err := s.Provider.Cancel(ctx, p.ProviderRef); err == nil {
+				state = cancelled
+			}
+		}
+		if state.Status != domain.PaymentIntentPaid {
+			state.Status = domain.PaymentIntentExpired
+		}
+	}
+	intent, err := s.apply(ctx, p.OwnerUserID, p.ID, state)
+	if err != nil {
+		return err
+	}
+	if intent.Status != domain.PaymentIntentExpired || intent.DraftID == nil || intent.TransactionID != nil {
+		return nil
+	}
+	err = s.Drafts.Cancel(ctx, p.OwnerUserID, *intent.DraftID)
+	if err != nil && !errors.Is(err, repository.ErrDraftNotOpen) && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("cancel draft %d: %w", *intent.DraftID, err)
+	}
+	return nil
+}
+
+// reportUnrecorded tells the owner, once per intent, about money the provider collected that no sale records.
+func (s PaymentService) reportUnrecorded(ctx context.Context) {
+	after := s.UnrecordedAfter
+	if after <= 0 {
+		after = 15 * time.Minute
+	}
+	intents, err := s.Intents.ClaimUnrecorded(ctx, time.Now().Add(-after), 50)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.Logger.Warn("payment reconcile unrecorded failed", "err", err)
+		}
+		return
+	}
+	for _, p := range intents {
+		paidAt := p.UpdatedAt
+		if p.PaidAt != nil {
+			paidAt = *p.PaidAt
+		}
+		_, err := s.Notifications.Create(ctx, repository.CreateNotificationInput{
+			UserID: p.OwnerUserID,
+			Title:  "Payment not recorded",
+			Message: fmt.Sprintf("%s payment %s of %s was paid at %s but no sale records it. Record the order with this payment or refund it.",
+				paymentMethodLabel(p.Method), p.ID, money.Format(p.Amount), paidAt.Format("2006-01-02 15:04 MST")),
+			Type: domain.NotificationWarning,
+		})
+		if err != nil {
+			s.Logger.Warn("payment unrecorded notification failed", "intent", p.ID, "err", err)
+			continue
+		}
+		s.Logger.Warn("payment paid but not recorded", "intent", p.ID, "owner", p.OwnerUserID, "amount", p.Amount.Amount)
+	}
+}
+
+func paymentMethodLabel(method string) string {
+	if method == "qris" {
+		return "QRIS"
+	}
+	return "Card"
+}
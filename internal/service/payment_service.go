@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/ports"
+	"barberpos-backend/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrPaymentIntentNotPending is returned when cancelling an intent that already succeeded, failed or ended.
+	ErrPaymentIntentNotPending = errors.New("payment intent is not pending")
+	// ErrPaymentIntentNotPaid is returned when refunding an intent that was never paid.
+	ErrPaymentIntentNotPaid = errors.New("payment intent is not paid")
+	// ErrRefundExceedsPayment is returned when a refund is larger than what is left of the payment.
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount paid")
+	// ErrPaymentProviderChanged is returned for intents created with a provider that is no longer configured.
+	ErrPaymentProviderChanged = errors.New("payment intent belongs to another provider")
+	// ErrWebhookNotSupported is returned for webhooks addressed to a provider that is not configured or does
+	// not send them.
+	ErrWebhookNotSupported = errors.New("payment provider does not send webhooks")
+)
+
+// PaymentService collects non-cash tenders through the configured provider and keeps payment_intents, and the
+// sales they pay for, in step with what the provider reports. Run reconciles in the background.
+type PaymentService struct {
+	Provider ports.PaymentProvider
+	Intents  repository.PaymentIntentRepository
+	// TTL is how long a customer has to pay before the intent expires.
+	TTL    time.Duration
+	Logger *slog.Logger
+	// Releaser puts back what a pending sale consumed when its payment fails.
+	Releaser SaleReleaser
+	// Drafts and Notifications are used by Run: drafts waiting on an expired intent are cancelled, and the
+	// manager hears about payments that no sale records.
+	Drafts        repository.DraftOrderRepository
+	Notifications repository.NotificationRepository
+	// ReconcileInterval is the pause between passes; UnrecordedAfter is how long a paid intent may wait for
+	// its order before the manager is told.
+	ReconcileInterval time.Duration
+	UnrecordedAfter   time.Duration
+}
+
+type CreatePaymentInput struct {
+	Method    string // qris or card
+	Amount    int64
+	DraftID   *int64
+	CreatedBy *int64
+}
+
+// Create records an intent and asks the provider to collect it. When the provider refuses, the intent is kept
+// as failed so the attempt is visible.
+func (s PaymentService) Create(ctx context.Context, ownerUserID int64, in CreatePaymentInput) (*domain.PaymentIntent, error) {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	intent, err := s.Intents.Create(ctx, ownerUserID, repository.CreatePaymentIntentInput{
+		Provider:  s.Provider.Name(),
+		Method:    in.Method,
+		Amount:    in.Amount,
+		DraftID:   in.DraftID,
+		CreatedBy: in.CreatedBy,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.Provider.CreateIntent(ctx, ports.PaymentRequest{
+		IntentID:  intent.ID,
+		Method:    intent.Method,
+		Amount:    intent.Amount.Amount,
+		Currency:  intent.Amount.Currency,
+		ExpiresAt: intent.ExpiresAt,
+	})
+	if err != nil {
+		_, _ = s.Intents.ApplyState(ctx, ownerUserID, intent.ID, repository.PaymentIntentState{Status: domain.PaymentIntentFailed})
+		return nil, err
+	}
+	return s.apply(ctx, ownerUserID, intent.ID, state)
+}
+
+// Refresh returns the intent, first asking the provider for news when it is still pending.
+func (s PaymentService) Refresh(ctx context.Context, ownerUserID int64, id string) (*domain.PaymentIntent, error) {
+	intent, err := s.Intents.Get(ctx, ownerUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != domain.PaymentIntentPending || intent.Provider != s.Provider.Name() || intent.ProviderRef == "" {
+		return intent, nil
+	}
+	state, err := s.Provider.GetStatus(ctx, intent.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, ownerUserID, id, state)
+}
+
+// Cancel withdraws a pending intent, e.g. when the customer decides to pay cash instead.
+func (s PaymentService) Cancel(ctx context.Context, ownerUserID int64, id string) (*domain.PaymentIntent, error) {
+	intent, err := s.provided(ctx, ownerUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != domain.PaymentIntentPending {
+		return nil, ErrPaymentIntentNotPending
+	}
+	state, err := s.Provider.Cancel(ctx, intent.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, ownerUserID, id, state)
+}
+
+// Refund returns amount of a paid intent to the customer; zero refunds whatever is left.
+func (s PaymentService) Refund(ctx context.Context, ownerUserID int64, id string, amount int64) (*domain.PaymentIntent, error) {
+	intent, err := s.provided(ctx, ownerUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != domain.PaymentIntentPaid {
+		return nil, ErrPaymentIntentNotPaid
+	}
+	left := intent.Amount.Amount - intent.RefundedAmount.Amount
+	if amount == 0 {
+		amount = left
+	}
+	if amount > left {
+		return nil, ErrRefundExceedsPayment
+	}
+	state, err := s.Provider.Refund(ctx, intent.ProviderRef, amount)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, ownerUserID, id, state)
+}
+
+// HandleWebhook verifies one webhook delivery addressed to provider and applies it. Redeliveries are
+// recognised by event id and ignored.
+func (s PaymentService) HandleWebhook(ctx context.Context, provider string, header http.Header, body []byte) (repository.WebhookOutcome, error) {
+	parser, ok := s.Provider.(ports.PaymentWebhookParser)
+	if !ok || !strings.EqualFold(provider, s.Provider.Name()) {
+		return "", ErrWebhookNotSupported
+	}
+	ev, err := parser.ParseWebhook(header, body)
+	if err != nil {
+		return "", err
+	}
+	outcome, _, err := s.Intents.ApplyWebhook(ctx, repository.PaymentWebhookEvent{
+		Provider: s.Provider.Name(),
+		EventID:  ev.EventID,
+		IntentID: ev.IntentID,
+		State:    toIntentState(ev.State),
+		Payload:  body,
+	}, s.releaseFailed)
+	if err != nil {
+		return "", err
+	}
+	if outcome == repository.WebhookUnmatched && s.Logger != nil {
+		s.Logger.Warn("payment webhook matches no intent", "provider", s.Provider.Name(), "event", ev.EventID, "ref", ev.State.ProviderRef)
+	}
+	return outcome, nil
+}
+
+// UnmatchedWebhooks lists webhook deliveries that matched no intent, newest first, for review.
+func (s PaymentService) UnmatchedWebhooks(ctx context.Context, beforeID int64, limit int) ([]repository.UnmatchedWebhook, error) {
+	return s.Intents.ListUnmatchedWebhooks(ctx, beforeID, limit)
+}
+
+// provided loads an intent the configured provider can act on.
+func (s PaymentService) provided(ctx context.Context, ownerUserID int64, id string) (*domain.PaymentIntent, error) {
+	intent, err := s.Intents.Get(ctx, ownerUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Provider != s.Provider.Name() {
+		return nil, ErrPaymentProviderChanged
+	}
+	return intent, nil
+}
+
+func (s PaymentService) apply(ctx context.Context, ownerUserID int64, id string, state ports.PaymentState) (*domain.PaymentIntent, error) {
+	return s.Intents.Settle(ctx, ownerUserID, id, toIntentState(state), s.releaseFailed)
+}
+
+func (s PaymentService) releaseFailed(ctx context.Context, tx pgx.Tx, ownerUserID int64, t domain.Transaction) error {
+	return s.Releaser.ReleaseWithTx(ctx, tx, ownerUserID, t, "payment_failed")
+}
+
+func toIntentState(state ports.PaymentState) repository.PaymentIntentState {
+	return repository.PaymentIntentState{
+		ProviderRef:    state.ProviderRef,
+		Status:         state.Status,
+		QRString:       state.QRString,
+		Reference:      state.Reference,
+		RefundedAmount: state.RefundedAmount,
+		PaidAt:         state.PaidAt,
+	}
+}
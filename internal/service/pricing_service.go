@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+)
+
+// PricingService re-prices orders from the owner's catalog so the server, not the client, decides amounts.
+type PricingService struct {
+	Products   repository.ProductRepository
+	Promotions repository.PromotionRepository
+}
+
+// PriceDiscrepancy describes one difference between what the client sent and what the server computed.
+type PriceDiscrepancy struct {
+	Line      int // index into the order lines; -1 for order-level fields
+	ProductID *int64
+	Field     string
+	Expected  int64
+	Actual    int64
+	Message   string
+}
+
+// PricingError is returned when the client's order does not match server pricing.
+type PricingError struct {
+	Discrepancies []PriceDiscrepancy
+}
+
+func (e *PricingError) Error() string {
+	return fmt.Sprintf("order pricing mismatch (%d discrepancies)", len(e.Discrepancies))
+}
+
+// ManualDiscount is a cashier-entered discount. Percent values are whole percents (1-100);
+// fixed values are rupiah off the line (or the order).
+type ManualDiscount struct {
+	Kind  domain.DiscountKind
+	Value int64
+	Note  string
+}
+
+type PriceOrderLine struct {
+	Item     repository.CreateTransactionItem
+	Discount *ManualDiscount
+}
+
+type PriceOrderInput struct {
+	Lines         []PriceOrderLine
+	PromoCodes    []string
+	OrderDiscount *ManualDiscount
+	ClientTotal   int64
+	// Settings supplies the tenant's tax and service charge rates; nil means neither applies.
+	Settings *domain.Settings
+	// AllowCustomItems accepts lines without a product id at the client's price, flagged as custom items.
+	// Otherwise they are refused: the server could not check their price.
+	AllowCustomItems bool
+	// AllowManualDiscounts accepts the cashier's line and order discounts; otherwise they are refused.
+	AllowManualDiscounts bool
+	// At is when the order was rung up: catalog prices and promotion windows are taken as of then.
+	// Zero means now.
+	At time.Time
+}
+
+// PricedOrder is the server-authoritative view of an order.
+type PricedOrder struct {
+	Items             []repository.CreateTransactionItem
+	Discounts         []repository.CreateTransactionDiscount
+	Subtotal          int64
+	DiscountTotal     int64
+	ServiceChargeRate float64
+	ServiceCharge     int64
+	TaxRate           float64
+	TaxInclusive      bool
+	TaxAmount         int64
+	Total             int64
+}
+
+// PriceOrder replaces each catalog line's price, name and category with the stored product,
+// applies line discounts, item promotions, order promotions and the manual order discount (in that order),
+// recomputes the total and reports every mismatch against the client-sent values.
+// Lines without a product id are custom items that keep the client price, and only when in.AllowCustomItems;
+// manual discounts are only taken when in.AllowManualDiscounts. Prices and promotion windows are those at in.At.
+func (s PricingService) PriceOrder(ctx context.Context, ownerUserID int64, in PriceOrderInput) (*PricedOrder, error) {
+	ids := make([]int64, 0, len(in.Lines))
+	for _, l := range in.Lines {
+		if l.Item.ProductID != nil {
+			ids = append(ids, *l.Item.ProductID)
+		}
+	}
+	var products map[int64]domain.Product
+	var err error
+	if in.At.IsZero() {
+		products, err = s.Products.GetByIDs(ctx, ownerUserID, ids)
+	} else {
+		products, err = s.Products.GetByIDsAt(ctx, ownerUserID, ids, in.At)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []PriceDiscrepancy
+	if len(in.Lines) == 0 {
+		issues = append(issues, PriceDiscrepancy{Line: -1, Field: "items", Message: "order has no items"})
+	}
+
+	out := PricedOrder{Items: make([]repository.CreateTransactionItem, 0, len(in.Lines))}
+	// net tracks each line's remaining amount after the discounts applied so far.
+	net := make([]int64, 0, len(in.Lines))
+	for i, l := range in.Lines {
+		it := l.Item
+		if it.Qty <= 0 {
+			issues = append(issues, PriceDiscrepancy{
+				Line: i, ProductID: it.ProductID, Field: "qty", Expected: 1, Actual: int64(it.Qty),
+				Message: "qty must be positive",
+			})
+			continue
+		}
+		if it.ProductID != nil {
+			p, ok := products[*it.ProductID]
+			if !ok {
+				issues = append(issues, PriceDiscrepancy{
+					Line: i, ProductID: it.ProductID, Field: "productId",
+					Message: "product not found",
+				})
+				continue
+			}
+			if it.Price != p.Price.Amount {
+				issues = append(issues, PriceDiscrepancy{
+					Line: i, ProductID: it.ProductID, Field: "price", Expected: p.Price.Amount, Actual: it.Price,
+					Message: "price differs from catalog",
+				})
+			}
+			it.Price = p.Price.Amount
+			it.Name = p.Name
+			it.Category = p.Category
+		} else {
+			if !in.AllowCustomItems {
+				issues = append(issues, PriceDiscrepancy{
+					Line: i, Field: "productId",
+					Message: "custom items are not allowed; pick a catalog product",
+				})
+				continue
+			}
+			if it.Price < 0 {
+				issues = append(issues, PriceDiscrepancy{
+					Line: i, Field: "price", Expected: 0, Actual: it.Price,
+					Message: "price must not be negative",
+				})
+				continue
+			}
+			it.Custom = true
+		}
+		gross := it.Price * int64(it.Qty)
+		out.Subtotal += gross
+		idx := len(out.Items)
+		out.Items = append(out.Items, it)
+		net = append(net, gross)
+
+		if l.Discount != nil {
+			if msg := validateManualDiscount(*l.Discount, in.AllowManualDiscounts); msg != "" {
+				issues = append(issues, PriceDiscrepancy{Line: i, ProductID: it.ProductID, Field: "discount", Actual: l.Discount.Value, Message: msg})
+				continue
+			}
+			amount := discountAmount(l.Discount.Kind, l.Discount.Value, gross, 1)
+			net[idx] -= amount
+			out.Discounts = append(out.Discounts, repository.CreateTransactionDiscount{
+				ItemIndex: intPtr(idx),
+				Kind:      l.Discount.Kind,
+				Scope:     domain.DiscountScopeItem,
+				Value:     l.Discount.Value,
+				Amount:    amount,
+				Note:      l.Discount.Note,
+			})
+		}
+	}
+
+	promos, promoIssues, err := s.resolvePromotions(ctx, ownerUserID, in.PromoCodes, out.Subtotal, in.At)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, promoIssues...)
+
+	for _, p := range promos {
+		if p.Scope != domain.DiscountScopeItem {
+			continue
+		}
+		remainingCap := int64(-1)
+		if p.MaxDiscount != nil {
+			remainingCap = *p.MaxDiscount
+		}
+		for idx, it := range out.Items {
+			if p.ProductID != nil && (it.ProductID == nil || *it.ProductID != *p.ProductID) {
+				continue
+			}
+			amount := discountAmount(p.Kind, p.Value, net[idx], it.Qty)
+			if remainingCap >= 0 && amount > remainingCap {
+				amount = remainingCap
+			}
+			if amount <= 0 {
+				continue
+			}
+			net[idx] -= amount
+			if remainingCap >= 0 {
+				remainingCap -= amount
+			}
+			out.Discounts = append(out.Discounts, repository.CreateTransactionDiscount{
+				ItemIndex:   intPtr(idx),
+				PromotionID: &p.ID,
+				Code:        p.Code,
+				Kind:        p.Kind,
+				Scope:       domain.DiscountScopeItem,
+				Value:       p.Value,
+				Amount:      amount,
+				Note:        p.Name,
+			})
+		}
+	}
+
+	for _, p := range promos {
+		if p.Scope != domain.DiscountScopeOrder {
+			continue
+		}
+		amount := discountAmount(p.Kind, p.Value, sumInt64(net), 1)
+		if p.MaxDiscount != nil && amount > *p.MaxDiscount {
+			amount = *p.MaxDiscount
+		}
+		allocate(net, amount)
+		out.Discounts = append(out.Discounts, repository.CreateTransactionDiscount{
+			PromotionID: &p.ID,
+			Code:        p.Code,
+			Kind:        p.Kind,
+			Scope:       domain.DiscountScopeOrder,
+			Value:       p.Value,
+			Amount:      amount,
+			Note:        p.Name,
+		})
+	}
+
+	if in.OrderDiscount != nil {
+		if msg := validateManualDiscount(*in.OrderDiscount, in.AllowManualDiscounts); msg != "" {
+			issues = append(issues, PriceDiscrepancy{Line: -1, Field: "discount", Actual: in.OrderDiscount.Value, Message: msg})
+		} else {
+			amount := discountAmount(in.OrderDiscount.Kind, in.OrderDiscount.Value, sumInt64(net), 1)
+			allocate(net, amount)
+			out.Discounts = append(out.Discounts, repository.CreateTransactionDiscount{
+				Kind:   in.OrderDiscount.Kind,
+				Scope:  domain.DiscountScopeOrder,
+				Value:  in.OrderDiscount.Value,
+				Amount: amount,
+				Note:   in.OrderDiscount.Note,
+			})
+		}
+	}
+
+	for idx := range out.Items {
+		out.Items[idx].Discount = out.Items[idx].Price*int64(out.Items[idx].Qty) - net[idx]
+	}
+	netTotal := sumInt64(net)
+	out.DiscountTotal = out.Subtotal - netTotal
+	out.Total = applyTaxAndService(&out, netTotal, in.Settings)
+
+	if len(issues) == 0 && in.ClientTotal != out.Total {
+		issues = append(issues, PriceDiscrepancy{
+			Line: -1, Field: "total", Expected: out.Total, Actual: in.ClientTotal,
+			Message: "total differs from server-computed total",
+		})
+	}
+	if len(issues) > 0 {
+		return nil, &PricingError{Discrepancies: issues}
+	}
+	return &out, nil
+}
+
+// applyTaxAndService adds the service charge on the discounted net, then tax on net plus service charge.
+// Inclusive tax is carved out of that base instead of added on top. Returns the amount to charge.
+func applyTaxAndService(out *PricedOrder, net int64, settings *domain.Settings) int64 {
+	if settings == nil {
+		return net
+	}
+	out.ServiceChargeRate = settings.ServiceChargeRate
+	out.TaxRate = settings.TaxRate
+	out.TaxInclusive = settings.TaxInclusive
+
+	out.ServiceCharge = roundRate(net, settings.ServiceChargeRate)
+	base := net + out.ServiceCharge
+	if settings.TaxRate <= 0 {
+		return base
+	}
+	if settings.TaxInclusive {
+		out.TaxAmount = int64(math.Round(float64(base) * settings.TaxRate / (100 + settings.TaxRate)))
+		return base
+	}
+	out.TaxAmount = roundRate(base, settings.TaxRate)
+	return base + out.TaxAmount
+}
+
+// roundRate returns rate percent of amount, rounded half away from zero to whole rupiah.
+func roundRate(amount int64, rate float64) int64 {
+	if rate <= 0 || amount <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(amount) * rate / 100))
+}
+
+// CashRounding returns what to add to a cash amount so it lands on the settings' rounding unit:
+// positive when rounding up, negative when rounding down, zero when rounding is off.
+// Halves round up in nearest mode.
+func CashRounding(amount int64, settings *domain.Settings) int64 {
+	if settings == nil || !settings.RoundingPrice || settings.RoundingUnit <= 0 || amount <= 0 {
+		return 0
+	}
+	unit := settings.RoundingUnit
+	rest := amount % unit
+	if rest == 0 {
+		return 0
+	}
+	switch settings.RoundingMode {
+	case domain.RoundingUp:
+		return unit - rest
+	case domain.RoundingDown:
+		return -rest
+	default:
+		if rest*2 >= unit {
+			return unit - rest
+		}
+		return -rest
+	}
+}
+
+// NormalizePromoCode is the canonical form promo codes are stored and looked up in.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// resolvePromotions loads the requested codes and checks each one is currently usable for this order.
+func (s PricingService) resolvePromotions(ctx context.Context, ownerUserID int64, codes []string, subtotal int64, at time.Time) ([]domain.Promotion, []PriceDiscrepancy, error) {
+	seen := make(map[string]struct{}, len(codes))
+	normalized := make([]string, 0, len(codes))
+	for _, c := range codes {
+		c = NormalizePromoCode(c)
+		if c == "" {
+			continue
+		}
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		normalized = append(normalized, c)
+	}
+	if len(normalized) == 0 {
+		return nil, nil, nil
+	}
+	found, err := s.Promotions.GetByCodes(ctx, ownerUserID, normalized)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if at.IsZero() {
+		at = time.Now()
+	}
+	var promos []domain.Promotion
+	var issues []PriceDiscrepancy
+	for _, c := range normalized {
+		p, ok := found[c]
+		if !ok {
+			issues = append(issues, PriceDiscrepancy{Line: -1, Field: "promoCodes", Message: "promo code " + c + " is not valid"})
+			continue
+		}
+		if issue := checkPromotion(p, subtotal, at); issue != nil {
+			issues = append(issues, *issue)
+			continue
+		}
+		promos = append(promos, p)
+	}
+	return promos, issues, nil
+}
+
+// checkPromotion reports why p cannot apply to an order with the given subtotal rung up at the given time,
+// or nil when it can.
+func checkPromotion(p domain.Promotion, subtotal int64, at time.Time) *PriceDiscrepancy {
+	issue := func(msg string) *PriceDiscrepancy {
+		return &PriceDiscrepancy{Line: -1, Field: "promoCodes", Message: "promo code " + p.Code + " " + msg}
+	}
+	switch {
+	case !p.Active:
+		return issue("is not valid")
+	case p.StartsAt != nil && at.Before(*p.StartsAt):
+		return issue("is not active yet")
+	case p.EndsAt != nil && at.After(*p.EndsAt):
+		return issue("has expired")
+	case p.UsageLimit != nil && p.UsedCount >= *p.UsageLimit:
+		return issue("has reached its usage limit")
+	case subtotal < p.MinSpend.Amount:
+		d := issue("requires a minimum spend")
+		d.Expected, d.Actual = p.MinSpend.Amount, subtotal
+		return d
+	}
+	return nil
+}
+
+func validateManualDiscount(d ManualDiscount, allowed bool) string {
+	if !allowed {
+		return "manual discounts are not allowed; ask a manager"
+	}
+	switch d.Kind {
+	case domain.DiscountPercent:
+		if d.Value <= 0 || d.Value > 100 {
+			return "percent discount must be between 1 and 100"
+		}
+	case domain.DiscountFixed:
+		if d.Value <= 0 {
+			return "fixed discount must be positive"
+		}
+	default:
+		return "discount type must be percent or fixed"
+	}
+	return ""
+}
+
+// discountAmount computes a discount against base, clamped to [0, base].
+// Fixed discounts are applied once per unit.
+func discountAmount(kind domain.DiscountKind, value int64, base int64, units int) int64 {
+	var amount int64
+	switch kind {
+	case domain.DiscountPercent:
+		amount = base * value / 100
+	case domain.DiscountFixed:
+		amount = value * int64(units)
+	}
+	if amount < 0 {
+		return 0
+	}
+	if amount > base {
+		return base
+	}
+	return amount
+}
+
+// allocate spreads an order-level discount across lines in proportion to their remaining net,
+// giving the rounding remainder to the last line that still has value.
+func allocate(net []int64, amount int64) {
+	total := sumInt64(net)
+	if total <= 0 || amount <= 0 {
+		return
+	}
+	if amount > total {
+		amount = total
+	}
+	last := -1
+	for i := range net {
+		if net[i] > 0 {
+			last = i
+		}
+	}
+	left := amount
+	for i := range net {
+		if net[i] <= 0 {
+			continue
+		}
+		share := amount * net[i] / total
+		if i == last {
+			share = left
+		}
+		if share > net[i] {
+			share = net[i]
+		}
+		net[i] -= share
+		left -= share
+	}
+	// Rounding can leave a few rupiah when the last line is small; put them wherever there is room.
+	for i := range net {
+		if left <= 0 {
+			break
+		}
+		take := left
+		if take > net[i] {
+			take = net[i]
+		}
+		net[i] -= take
+		left -= take
+	}
+}
+
+func sumInt64(values []int64) int64 {
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func intPtr(v int) *int {
+	return &v
+}
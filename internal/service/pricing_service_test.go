@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+)
+
+// customLine is a line without a product, so pricing it needs no catalog lookup.
+func customLine(name string, price int64, qty int, discount *ManualDiscount) PriceOrderLine {
+	return PriceOrderLine{Item: repository.CreateTransactionItem{Name: name, Price: price, Qty: qty}, Discount: discount}
+}
+
+func pricingMessages(err error) []string {
+	var pe *PricingError
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	var out []string
+	for _, d := range pe.Discrepancies {
+		out = append(out, d.Message)
+	}
+	return out
+}
+
+func TestPriceOrderManualDiscounts(t *testing.T) {
+	tenPercent := &ManualDiscount{Kind: domain.DiscountPercent, Value: 10}
+	fiveThousand := &ManualDiscount{Kind: domain.DiscountFixed, Value: 5000}
+	tests := []struct {
+		name      string
+		in        PriceOrderInput
+		wantTotal int64
+		wantErr   string
+	}{
+		{
+			name: "staff line discount",
+			in: PriceOrderInput{
+				Lines:       []PriceOrderLine{customLine("Haircut", 50000, 1, tenPercent)},
+				ClientTotal: 45000,
+			},
+			wantErr: "manual discounts are not allowed",
+		},
+		{
+			name: "staff order discount",
+			in: PriceOrderInput{
+				Lines:         []PriceOrderLine{customLine("Haircut", 50000, 1, nil)},
+				OrderDiscount: &ManualDiscount{Kind: domain.DiscountPercent, Value: 100},
+				ClientTotal:   0,
+			},
+			wantErr: "manual discounts are not allowed",
+		},
+		{
+			name: "staff without discounts",
+			in: PriceOrderInput{
+				Lines:       []PriceOrderLine{customLine("Haircut", 50000, 1, nil)},
+				ClientTotal: 50000,
+			},
+			wantTotal: 50000,
+		},
+		{
+			name: "allowed line and order discounts",
+			in: PriceOrderInput{
+				Lines: []PriceOrderLine{
+					customLine("Haircut", 50000, 1, tenPercent),
+					customLine("Wash", 30000, 2, nil),
+				},
+				OrderDiscount:        fiveThousand,
+				ClientTotal:          100000,
+				AllowManualDiscounts: true,
+			},
+			wantTotal: 100000,
+		},
+		{
+			name: "percent over 100",
+			in: PriceOrderInput{
+				Lines:                []PriceOrderLine{customLine("Haircut", 50000, 1, &ManualDiscount{Kind: domain.DiscountPercent, Value: 150})},
+				ClientTotal:          0,
+				AllowManualDiscounts: true,
+			},
+			wantErr: "percent discount must be between 1 and 100",
+		},
+		{
+			name: "fixed discount larger than the line",
+			in: PriceOrderInput{
+				Lines:                []PriceOrderLine{customLine("Haircut", 50000, 1, &ManualDiscount{Kind: domain.DiscountFixed, Value: 80000})},
+				ClientTotal:          0,
+				AllowManualDiscounts: true,
+			},
+			wantTotal: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt.in.AllowCustomItems = true
+		priced, err := PricingService{}.PriceOrder(context.Background(), 1, tt.in)
+		if tt.wantErr != "" {
+			msgs := pricingMessages(err)
+			if len(msgs) == 0 || !strings.HasPrefix(msgs[0], tt.wantErr) {
+				t.Errorf("%s: err = %v (%v), want %q", tt.name, err, msgs, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: PriceOrder: %v (%v)", tt.name, err, pricingMessages(err))
+			continue
+		}
+		if priced.Total != tt.wantTotal {
+			t.Errorf("%s: total = %d, want %d", tt.name, priced.Total, tt.wantTotal)
+		}
+		var lineDiscounts int64
+		for _, it := range priced.Items {
+			lineDiscounts += it.Discount
+		}
+		if lineDiscounts != priced.DiscountTotal {
+			t.Errorf("%s: line discounts add up to %d, DiscountTotal is %d", tt.name, lineDiscounts, priced.DiscountTotal)
+		}
+	}
+}
+
+func TestPriceOrderCustomItems(t *testing.T) {
+	in := PriceOrderInput{Lines: []PriceOrderLine{customLine("Beard oil", 25000, 2, nil)}, ClientTotal: 50000}
+	_, err := PricingService{}.PriceOrder(context.Background(), 1, in)
+	if msgs := pricingMessages(err); len(msgs) != 1 || !strings.HasPrefix(msgs[0], "custom items are not allowed") {
+		t.Fatalf("staff custom item: err = %v (%v)", err, msgs)
+	}
+
+	in.AllowCustomItems = true
+	priced, err := PricingService{}.PriceOrder(context.Background(), 1, in)
+	if err != nil {
+		t.Fatalf("allowed custom item: %v (%v)", err, pricingMessages(err))
+	}
+	if !priced.Items[0].Custom || priced.Total != 50000 {
+		t.Errorf("custom item priced as %+v, total %d", priced.Items[0], priced.Total)
+	}
+
+	in.Lines = []PriceOrderLine{customLine("Store credit", -25000, 1, nil)}
+	in.ClientTotal = -25000
+	if _, err := (PricingService{}).PriceOrder(context.Background(), 1, in); len(pricingMessages(err)) == 0 {
+		t.Errorf("negative custom price accepted")
+	}
+}
+
+func TestPriceOrderTaxAndServiceCharge(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *domain.Settings
+		total    int64
+		service  int64
+		tax      int64
+	}{
+		{"none", nil, 100000, 0, 0},
+		{"service charge only", &domain.Settings{ServiceChargeRate: 5}, 105000, 5000, 0},
+		{"exclusive tax on net plus service", &domain.Settings{ServiceChargeRate: 5, TaxRate: 11}, 116550, 5000, 11550},
+		{"inclusive tax", &domain.Settings{ServiceChargeRate: 5, TaxRate: 11, TaxInclusive: true}, 105000, 5000, 10405},
+	}
+	for _, tt := range tests {
+		priced, err := PricingService{}.PriceOrder(context.Background(), 1, PriceOrderInput{
+			Lines:            []PriceOrderLine{customLine("Haircut", 100000, 1, nil)},
+			ClientTotal:      tt.total,
+			Settings:         tt.settings,
+			AllowCustomItems: true,
+		})
+		if err != nil {
+			t.Errorf("%s: PriceOrder: %v (%v)", tt.name, err, pricingMessages(err))
+			continue
+		}
+		if priced.Total != tt.total || priced.ServiceCharge != tt.service || priced.TaxAmount != tt.tax {
+			t.Errorf("%s: total %d, service %d, tax %d; want %d, %d, %d", tt.name, priced.Total, priced.ServiceCharge, priced.TaxAmount, tt.total, tt.service, tt.tax)
+		}
+	}
+}
+
+func TestCashRounding(t *testing.T) {
+	rounding := func(unit int64, mode domain.RoundingMode) *domain.Settings {
+		return &domain.Settings{RoundingPrice: true, RoundingUnit: unit, RoundingMode: mode}
+	}
+	tests := []struct {
+		name     string
+		amount   int64
+		settings *domain.Settings
+		want     int64
+	}{
+		{"no settings", 12345, nil, 0},
+		{"rounding off", 12345, &domain.Settings{RoundingUnit: 100, RoundingMode: domain.RoundingUp}, 0},
+		{"no unit", 12345, rounding(0, domain.RoundingUp), 0},
+		{"zero amount", 0, rounding(100, domain.RoundingUp), 0},
+		{"already round", 12300, rounding(100, domain.RoundingNearest), 0},
+		{"nearest down", 12349, rounding(100, domain.RoundingNearest), -49},
+		{"nearest half goes up", 12350, rounding(100, domain.RoundingNearest), 50},
+		{"nearest up", 12351, rounding(100, domain.RoundingNearest), 49},
+		{"empty mode is nearest", 12260, rounding(500, ""), 240},
+		{"up", 12301, rounding(500, domain.RoundingUp), 199},
+		{"down", 12999, rounding(1000, domain.RoundingDown), -999},
+		{"below one unit, down", 400, rounding(1000, domain.RoundingDown), -400},
+		{"below one unit, up", 400, rounding(1000, domain.RoundingUp), 600},
+	}
+	for _, tt := range tests {
+		if got := CashRounding(tt.amount, tt.settings); got != tt.want {
+			t.Errorf("%s: CashRounding(%d) = %d, want %d", tt.name, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPromotion(t *testing.T) {
+	at := time.Date(2025, 1, 31, 14, 5, 0, 0, time.UTC)
+	before, after := at.Add(-time.Hour), at.Add(time.Hour)
+	limit := 3
+	promo := func(edit func(p *domain.Promotion)) domain.Promotion {
+		p := domain.Promotion{Code: "HEMAT", Active: true, MinSpend: domain.Money{Amount: 50000}}
+		if edit != nil {
+			edit(&p)
+		}
+		return p
+	}
+	tests := []struct {
+		name     string
+		p        domain.Promotion
+		subtotal int64
+		want     string
+	}{
+		{"valid", promo(nil), 50000, ""},
+		{"inactive", promo(func(p *domain.Promotion) { p.Active = false }), 50000, "promo code HEMAT is not valid"},
+		{"not started", promo(func(p *domain.Promotion) { p.StartsAt = &after }), 50000, "promo code HEMAT is not active yet"},
+		{"starts exactly then", promo(func(p *domain.Promotion) { p.StartsAt = &at }), 50000, ""},
+		{"ended", promo(func(p *domain.Promotion) { p.EndsAt = &before }), 50000, "promo code HEMAT has expired"},
+		{"ends exactly then", promo(func(p *domain.Promotion) { p.EndsAt = &at }), 50000, ""},
+		{"within its window", promo(func(p *domain.Promotion) { p.StartsAt, p.EndsAt = &before, &after }), 50000, ""},
+		{"used up", promo(func(p *domain.Promotion) { p.UsageLimit, p.UsedCount = &limit, 3 }), 50000, "promo code HEMAT has reached its usage limit"},
+		{"uses left", promo(func(p *domain.Promotion) { p.UsageLimit, p.UsedCount = &limit, 2 }), 50000, ""},
+		{"below minimum spend", promo(nil), 49999, "promo code HEMAT requires a minimum spend"},
+	}
+	for _, tt := range tests {
+		got := checkPromotion(tt.p, tt.subtotal, at)
+		switch {
+		case tt.want == "" && got != nil:
+			t.Errorf("%s: %q, want the promotion to apply", tt.name, got.Message)
+		case tt.want != "" && got == nil:
+			t.Errorf("%s: promotion applies, want %q", tt.name, tt.want)
+		case tt.want != "" && got.Message != tt.want:
+			t.Errorf("%s: %q, want %q", tt.name, got.Message, tt.want)
+		}
+	}
+
+	// Offline orders are checked at the time they were rung up, not when they sync.
+	ended := promo(func(p *domain.Promotion) { p.EndsAt = &at })
+	if got := checkPromotion(ended, 50000, before); got != nil {
+		t.Errorf("order rung up before the promotion ended: %q", got.Message)
+	}
+	if got := checkPromotion(promo(nil), 40000, at); got == nil || got.Expected != 50000 || got.Actual != 40000 {
+		t.Errorf("minimum spend discrepancy = %+v, want expected 50000, actual 40000", got)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		net    []int64
+		amount int64
+		want   []int64
+	}{
+		{"proportional", []int64{60000, 40000}, 10000, []int64{54000, 36000}},
+		{"remainder to the last line", []int64{10000, 10000, 10000}, 1000, []int64{9667, 9667, 9666}},
+		{"skips lines with nothing left", []int64{0, 30000, 0}, 5000, []int64{0, 25000, 0}},
+		{"clamped to the net", []int64{20000, 10000}, 50000, []int64{0, 0}},
+		{"nothing to allocate", []int64{20000}, 0, []int64{20000}},
+	}
+	for _, tt := range tests {
+		net := append([]int64(nil), tt.net...)
+		allocate(net, tt.amount)
+		for i := range net {
+			if net[i] != tt.want[i] {
+				t.Errorf("%s: net = %v, want %v", tt.name, net, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/receipt"
+	"barberpos-backend/internal/repository"
+)
+
+// ErrNoNetworkPrinter is returned when the owner has not configured a LAN printer to send jobs to.
+var ErrNoNetworkPrinter = errors.New("no network printer configured")
+
+// PrintService renders receipts and closing reports as ESC/POS and queues them for the owner's network
+// printer; Run delivers the queue over raw TCP (port 9100 by default).
+type PrintService struct {
+	Jobs     repository.PrintJobRepository
+	Settings repository.SettingsRepository
+	Logger   *slog.Logger
+	// Interval between queue polls; DialTimeout bounds connecting and writing one job; MaxAttempts caps retries.
+	Interval    time.Duration
+	DialTimeout time.Duration
+	MaxAttempts int
+}
+
+// EnqueueReceipt queues the receipt of t for the owner's network printer.
+func (s PrintService) EnqueueReceipt(ctx context.Context, ownerUserID int64, t domain.Transaction) (*domain.PrintJob, error) {
+	settings, err := s.Settings.Get(ctx, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(ctx, ownerUserID, settings, domain.PrintJobReceipt, t.Code, receipt.ESCPOS(receipt.Build(t, *settings)))
+}
+
+// AutoPrintReceipt queues the receipt when the owner has auto print on and a network printer set up;
+// otherwise it does nothing.
+func (s PrintService) AutoPrintReceipt(ctx context.Context, ownerUserID int64, t domain.Transaction) error {
+	settings, err := s.Settings.Get(ctx, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if !settings.AutoPrint || !strings.EqualFold(settings.PrinterType, "lan") {
+		return nil
+	}
+	_, err = s.enqueue(ctx, ownerUserID, settings, domain.PrintJobReceipt, t.Code, receipt.ESCPOS(receipt.Build(t, *settings)))
+	return err
+}
+
+// EnqueueClosing queues a closing report for the owner's network printer.
+func (s PrintService) EnqueueClosing(ctx context.Context, ownerUserID int64, report receipt.ClosingReport) (*domain.PrintJob, error) {
+	settings, err := s.Settings.Get(ctx, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(ctx, ownerUserID, settings, domain.PrintJobClosing, report.Date.Format("2006-01-02"), receipt.ESCPOS(receipt.BuildClosing(report, *settings)))
+}
+
+// EnqueueTest queues a short test page so the owner can check the printer address.
+func (s PrintService) EnqueueTest(ctx context.Context, ownerUserID int64) (*domain.PrintJob, error) {
+	settings, err := s.Settings.Get(ctx, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(ctx, ownerUserID, settings, domain.PrintJobTest, "", receipt.ESCPOS(receipt.BuildTest(*settings)))
+}
+
+func (s PrintService) enqueue(ctx context.Context, ownerUserID int64, settings *domain.Settings, kind domain.PrintJobKind, ref string, payload []byte) (*domain.PrintJob, error) {
+	if !strings.EqualFold(settings.PrinterType, "lan") || strings.TrimSpace(settings.PrinterHost) == "" {
+		return nil, ErrNoNetworkPrinter
+	}
+	port := settings.PrinterPort
+	if port <= 0 {
+		port = 9100
+	}
+	return s.Jobs.Create(ctx, ownerUserID, repository.CreatePrintJobInput{
+		Kind:        kind,
+		Reference:   ref,
+		PrinterHost: strings.TrimSpace(settings.PrinterHost),
+		PrinterPort: port,
+		Payload:     payload,
+		MaxAttempts: s.MaxAttempts,
+	})
+}
+
+// Run sends due jobs until ctx is cancelled.
+func (s PrintService) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s PrintService) drain(ctx context.Context) {
+	timeout := s.dialTimeout()
+	// The lease must outlast a send so a slow printer is not picked up twice.
+	jobs, err := s.Jobs.ClaimDue(ctx, 20, 2*timeout+time.Second)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.Logger.Warn("print queue claim failed", "err", err)
+		}
+		return
+	}
+	for _, j := range jobs {
+		if err := s.send(ctx, j); err != nil {
+			retryAt := time.Now().Add(printBackoff(j.Attempts))
+			if markErr := s.Jobs.MarkAttemptFailed(ctx, j.ID, err.Error(), retryAt); markErr != nil {
+				s.Logger.Warn("print job update failed", "job", j.ID, "err", markErr)
+			}
+			s.Logger.Warn("print job send failed", "job", j.ID, "attempt", j.Attempts, "err", err)
+			continue
+		}
+		if err := s.Jobs.MarkSent(ctx, j.ID); err != nil {
+			s.Logger.Warn("print job update failed", "job", j.ID, "err", err)
+		}
+	}
+}
+
+func (s PrintService) send(ctx context.Context, j domain.PrintJob) error {
+	timeout := s.dialTimeout()
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(j.PrinterHost, strconv.Itoa(j.PrinterPort)))
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(j.Payload); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (s PrintService) dialTimeout() time.Duration {
+	if s.DialTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return s.DialTimeout
+}
+
+// printBackoff waits 5s, 20s, 45s, ... after each failed attempt, capped at five minutes.
+func printBackoff(attempts int) time.Duration {
+	d := time.Duration(attempts*attempts) * 5 * time.Second
+	if d > 5*time.Minute {
+		d = 5 * time.Minute
+	}
+	return d
+}
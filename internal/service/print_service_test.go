@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/receipt"
+)
+
+// printerStandIn listens like a network printer on 127.0.0.1 and hands back what one connection wrote.
+func printerStandIn(t *testing.T) (host string, port int, received <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		b, _ := io.ReadAll(conn)
+		out <- b
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestPrintServiceSendDeliversPayload(t *testing.T) {
+	host, port, received := printerStandIn(t)
+	payload := receipt.ESCPOS(receipt.BuildTest(domain.Settings{BusinessName: "Barber", PaperSize: "58mm"}))
+
+	s := PrintService{DialTimeout: time.Second}
+	err := s.send(context.Background(), domain.PrintJob{ID: 1, PrinterHost: host, PrinterPort: port, Payload: payload})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("printer got %d bytes, want the %d-byte job", len(got), len(payload))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("printer received nothing")
+	}
+}
+
+func TestPrintServiceSendUnreachablePrinter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	s := PrintService{DialTimeout: time.Second}
+	err = s.send(context.Background(), domain.PrintJob{ID: 1, PrinterHost: addr.IP.String(), PrinterPort: addr.Port, Payload: []byte("x")})
+	if err == nil {
+		t.Fatal("send to a closed port succeeded")
+	}
+	if !strings.HasPrefix(err.Error(), "connect:") {
+		t.Fatalf("err = %q, want a connect error", err)
+	}
+}
+
+func TestPrintBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 5 * time.Second},
+		{2, 20 * time.Second},
+		{3, 45 * time.Second},
+		{7, 245 * time.Second},
+		{8, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := printBackoff(tt.attempts); got != tt.want {
+			t.Errorf("printBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+
+	"barberpos-backend/internal/domain"
+	"barberpos-backend/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaleReleaser puts back what recording a sale consumed when the sale is voided or its payment fails.
+type SaleReleaser struct {
+	Stocks     repository.StockRepository
+	Promotions repository.PromotionRepository
+	Membership *MembershipService
+}
+
+// ReleaseWithTx returns product stock, promotion uses and membership quota taken by t. reason tags the
+// stock movements.
+func (s SaleReleaser) ReleaseWithTx(ctx context.Context, tx pgx.Tx, ownerID int64, t domain.Transaction, reason string) error {
+	units := 0
+	for _, it := range t.Items {
+		units += it.Qty
+		if it.ProductID == nil || it.Qty <= 0 {
+			continue
+		}
+		_ = s.Stocks.AdjustByProductIDWithTx(ctx, tx, ownerID, *it.ProductID, it.Qty, reason, reason+" "+t.Code)
+	}
+	released := make(map[int64]struct{})
+	for _, d := range t.Discounts {
+		if d.PromotionID == nil {
+			continue
+		}
+		if _, ok := released[*d.PromotionID]; ok {
+			continue
+		}
+		released[*d.PromotionID] = struct{}{}
+		if err := s.Promotions.ReleaseWithTx(ctx, tx, ownerID, *d.PromotionID); err != nil {
+			return err
+		}
+	}
+	if s.Membership == nil {
+		return nil
+	}
+	_, err := s.Membership.RefundWithTx(ctx, tx, ownerID, units)
+	return err
+}
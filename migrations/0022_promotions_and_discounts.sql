@@ -0,0 +1,69 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS promotions (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL CHECK (kind IN ('percent','fixed')),
+    scope TEXT NOT NULL DEFAULT 'order' CHECK (scope IN ('order','item')),
+    value BIGINT NOT NULL CHECK (value > 0),
+    product_id BIGINT REFERENCES products(id) ON DELETE SET NULL,
+    min_spend BIGINT NOT NULL DEFAULT 0 CHECK (min_spend >= 0),
+    max_discount BIGINT CHECK (max_discount > 0),
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    usage_limit INTEGER CHECK (usage_limit > 0),
+    used_count INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS promotions_owner_code_unique
+ON promotions (owner_user_id, code)
+WHERE deleted_at IS NULL;
+
+-- Gross (list) subtotal and total discount per transaction; amount stays the net charged.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS subtotal BIGINT,
+    ADD COLUMN IF NOT EXISTS discount_total BIGINT NOT NULL DEFAULT 0;
+
+UPDATE transactions SET subtotal = amount WHERE subtotal IS NULL;
+
+ALTER TABLE transactions
+    ALTER COLUMN subtotal SET DEFAULT 0,
+    ALTER COLUMN subtotal SET NOT NULL;
+
+-- Each line's share of all discounts, so price*qty - discount is the line's net revenue.
+ALTER TABLE transaction_items
+    ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0 CHECK (discount >= 0);
+
+CREATE TABLE IF NOT EXISTS transaction_discounts (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    transaction_item_id BIGINT REFERENCES transaction_items(id) ON DELETE CASCADE,
+    promotion_id BIGINT REFERENCES promotions(id) ON DELETE SET NULL,
+    code TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL CHECK (kind IN ('percent','fixed')),
+    scope TEXT NOT NULL CHECK (scope IN ('order','item')),
+    value BIGINT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_discounts_transaction ON transaction_discounts (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_discounts_promotion ON transaction_discounts (promotion_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS transaction_discounts;
+
+ALTER TABLE transaction_items
+    DROP COLUMN IF EXISTS discount;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS discount_total,
+    DROP COLUMN IF EXISTS subtotal;
+
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (tax_rate >= 0 AND tax_rate <= 100),
+    ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS service_charge_rate NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (service_charge_rate >= 0 AND service_charge_rate <= 100);
+
+-- Per-transaction snapshot of the rates in force plus the computed amounts.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS tax_amount BIGINT NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
+    ADD COLUMN IF NOT EXISTS service_charge_rate NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS service_charge BIGINT NOT NULL DEFAULT 0 CHECK (service_charge >= 0);
+
+-- +goose Down
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS service_charge,
+    DROP COLUMN IF EXISTS service_charge_rate,
+    DROP COLUMN IF EXISTS tax_amount,
+    DROP COLUMN IF EXISTS tax_inclusive,
+    DROP COLUMN IF EXISTS tax_rate;
+
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS service_charge_rate,
+    DROP COLUMN IF EXISTS tax_inclusive,
+    DROP COLUMN IF EXISTS tax_rate;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- One row per tender; a transaction paid part cash, part QRIS has two rows.
+CREATE TABLE IF NOT EXISTS transaction_payments (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    method TEXT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    reference TEXT,
+    payment_intent_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_payments_transaction ON transaction_payments (transaction_id);
+
+-- Existing transactions become a single tender of their header payment method.
+INSERT INTO transaction_payments (transaction_id, method, amount, reference, payment_intent_id, created_at)
+SELECT t.id, t.payment_method, t.amount, t.payment_reference, t.payment_intent_id, t.created_at
+FROM transactions t
+WHERE NOT EXISTS (SELECT 1 FROM transaction_payments p WHERE p.transaction_id = t.id);
+
+-- +goose Down
+DROP TABLE IF EXISTS transaction_payments;
@@ -0,0 +1,68 @@
+-- +goose Up
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('paid','partially_refunded','refund'));
+
+-- Running totals so the remaining refundable amount/qty is known without summing refund rows.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0 CHECK (refunded_amount >= 0);
+
+ALTER TABLE transaction_items
+    ADD COLUMN IF NOT EXISTS refunded_qty INTEGER NOT NULL DEFAULT 0 CHECK (refunded_qty >= 0);
+
+-- Each refund (full or partial) is its own record; reversed_at is set when mark-paid undoes it.
+CREATE TABLE IF NOT EXISTS transaction_refunds (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    units INTEGER NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT '',
+    refunded_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reversed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_transaction ON transaction_refunds (transaction_id);
+
+CREATE TABLE IF NOT EXISTS transaction_refund_items (
+    id BIGSERIAL PRIMARY KEY,
+    refund_id BIGINT NOT NULL REFERENCES transaction_refunds(id) ON DELETE CASCADE,
+    transaction_item_id BIGINT NOT NULL REFERENCES transaction_items(id) ON DELETE CASCADE,
+    qty INTEGER NOT NULL CHECK (qty > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_refund_items_refund ON transaction_refund_items (refund_id);
+
+-- Existing full refunds become one refund record covering every line.
+UPDATE transactions SET refunded_amount = amount WHERE status = 'refund';
+UPDATE transaction_items ti
+SET refunded_qty = ti.qty
+FROM transactions t
+WHERE t.id = ti.transaction_id AND t.status = 'refund';
+
+INSERT INTO transaction_refunds (transaction_id, amount, units, note, refunded_by, created_at)
+SELECT t.id, t.amount, COALESCE((SELECT SUM(qty) FROM transaction_items WHERE transaction_id = t.id), 0),
+       COALESCE(t.refund_note, ''), t.refunded_by, COALESCE(t.refunded_at, t.updated_at)
+FROM transactions t
+WHERE t.status = 'refund';
+
+INSERT INTO transaction_refund_items (refund_id, transaction_item_id, qty)
+SELECT r.id, ti.id, ti.qty
+FROM transaction_refunds r
+JOIN transaction_items ti ON ti.transaction_id = r.transaction_id
+WHERE ti.qty > 0;
+
+-- +goose Down
+DROP TABLE IF EXISTS transaction_refund_items;
+DROP TABLE IF EXISTS transaction_refunds;
+
+ALTER TABLE transaction_items
+    DROP COLUMN IF EXISTS refunded_qty;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS refunded_amount;
+
+UPDATE transactions SET status = 'refund' WHERE status = 'partially_refunded';
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('paid','refund'));
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Tips are kept out of amount (service revenue); tenders cover amount + tip_total.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS tip_total BIGINT NOT NULL DEFAULT 0 CHECK (tip_total >= 0);
+
+-- One row per stylist receiving part of the tip.
+CREATE TABLE IF NOT EXISTS transaction_tips (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    stylist_id BIGINT REFERENCES employees(id) ON DELETE SET NULL,
+    stylist TEXT NOT NULL DEFAULT '',
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_tips_transaction ON transaction_tips (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_tips_stylist ON transaction_tips (stylist_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS transaction_tips;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS tip_total;
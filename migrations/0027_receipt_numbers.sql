@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS receipt_prefix TEXT NOT NULL DEFAULT 'INV',
+    ADD COLUMN IF NOT EXISTS receipt_date_format TEXT NOT NULL DEFAULT 'yyyymmdd'
+        CHECK (receipt_date_format IN ('none','yyyy','yyyymm','yyyymmdd')),
+    ADD COLUMN IF NOT EXISTS receipt_reset TEXT NOT NULL DEFAULT 'daily'
+        CHECK (receipt_reset IN ('daily','yearly')),
+    ADD COLUMN IF NOT EXISTS receipt_digits INTEGER NOT NULL DEFAULT 4
+        CHECK (receipt_digits BETWEEN 1 AND 10);
+
+-- One counter per owner and reset period ('2025-01-31' for daily, '2025' for yearly).
+-- The row stays locked until the creating transaction commits, so numbers are gap-free under concurrency.
+CREATE TABLE IF NOT EXISTS receipt_sequences (
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period TEXT NOT NULL,
+    last_value BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (owner_user_id, period)
+);
+
+-- Customer-facing number; code (ORD-<millis>) stays as the internal id. Legacy rows have none.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS receipt_number TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_owner_receipt_number_unique
+ON transactions (owner_user_id, receipt_number)
+WHERE receipt_number IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_owner_receipt_number_unique;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS receipt_number;
+
+DROP TABLE IF EXISTS receipt_sequences;
+
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS receipt_digits,
+    DROP COLUMN IF EXISTS receipt_reset,
+    DROP COLUMN IF EXISTS receipt_date_format,
+    DROP COLUMN IF EXISTS receipt_prefix;
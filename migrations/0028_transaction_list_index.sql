@@ -0,0 +1,10 @@
+-- +goose Up
+-- Keyset pagination walks (transacted_date, id) backwards per owner.
+CREATE INDEX IF NOT EXISTS idx_transactions_owner_date_id
+    ON transactions (owner_user_id, transacted_date DESC, id DESC)
+    WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_transaction_payments_method ON transaction_payments (method, transaction_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_transaction_payments_method;
+DROP INDEX IF EXISTS idx_transactions_owner_date_id;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- A void cancels a sale made by mistake in the same shift; unlike a refund no money goes back out.
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('paid','partially_refunded','refund','void'));
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS voided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS void_approved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS void_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS void_note TEXT NOT NULL DEFAULT '';
+
+-- Reason codes offered when voiding, and the PIN a manager types to approve a staff void.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS void_reasons TEXT[] NOT NULL DEFAULT ARRAY['wrong_item','wrong_payment','duplicate','customer_cancelled','other'],
+    ADD COLUMN IF NOT EXISTS manager_pin_hash TEXT;
+
+-- +goose Down
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS manager_pin_hash,
+    DROP COLUMN IF EXISTS void_reasons;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS void_note,
+    DROP COLUMN IF EXISTS void_reason,
+    DROP COLUMN IF EXISTS void_approved_by,
+    DROP COLUMN IF EXISTS voided_by,
+    DROP COLUMN IF EXISTS voided_at;
+
+UPDATE transactions SET status='refund' WHERE status='void';
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('paid','partially_refunded','refund'));
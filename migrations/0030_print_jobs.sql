@@ -0,0 +1,25 @@
+-- +goose Up
+-- ESC/POS jobs waiting for (or already sent to) a network printer. The payload is the raw byte stream.
+CREATE TABLE IF NOT EXISTS print_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('receipt','closing','test')),
+    reference TEXT NOT NULL DEFAULT '',
+    printer_host TEXT NOT NULL,
+    printer_port INTEGER NOT NULL DEFAULT 9100,
+    payload BYTEA NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued','sent','failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_print_jobs_owner ON print_jobs (owner_user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_due ON print_jobs (next_attempt_at) WHERE status = 'queued';
+
+-- +goose Down
+DROP TABLE IF EXISTS print_jobs;
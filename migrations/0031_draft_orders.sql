@@ -0,0 +1,41 @@
+-- +goose Up
+-- Open tickets (tabs): orders being built while the customer is in the chair. Nothing is charged,
+-- and no stock or membership quota is used, until the draft is paid and becomes a transaction.
+CREATE TABLE IF NOT EXISTS draft_orders (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open','paid','cancelled')),
+    customer_name TEXT NOT NULL DEFAULT '',
+    stylist TEXT NOT NULL DEFAULT '',
+    stylist_id BIGINT REFERENCES employees(id) ON DELETE SET NULL,
+    shift_id TEXT,
+    operator_name TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_draft_orders_open ON draft_orders (owner_user_id, created_at) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS draft_order_lines (
+    id BIGSERIAL PRIMARY KEY,
+    draft_id BIGINT NOT NULL REFERENCES draft_orders(id) ON DELETE CASCADE,
+    product_id BIGINT REFERENCES products(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    category TEXT NOT NULL DEFAULT '',
+    price BIGINT NOT NULL CHECK (price >= 0),
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    discount_type TEXT NOT NULL DEFAULT '',
+    discount_value BIGINT NOT NULL DEFAULT 0,
+    discount_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_draft_order_lines_draft ON draft_order_lines (draft_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS draft_order_lines;
+DROP TABLE IF EXISTS draft_orders;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Each line can be done by a different stylist (haircut by one barber, shave by another).
+-- Existing lines inherit the transaction's stylist so per-line reports match the old totals.
+ALTER TABLE transaction_items
+    ADD COLUMN IF NOT EXISTS stylist_id BIGINT REFERENCES employees(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS stylist TEXT NOT NULL DEFAULT '';
+
+UPDATE transaction_items ti
+SET stylist_id = t.stylist_id, stylist = t.stylist
+FROM transactions t
+WHERE t.id = ti.transaction_id;
+
+CREATE INDEX IF NOT EXISTS idx_transaction_items_stylist ON transaction_items (stylist_id) WHERE stylist_id IS NOT NULL;
+
+ALTER TABLE draft_order_lines
+    ADD COLUMN IF NOT EXISTS stylist_id BIGINT REFERENCES employees(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS stylist TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE draft_order_lines
+    DROP COLUMN IF EXISTS stylist,
+    DROP COLUMN IF EXISTS stylist_id;
+
+DROP INDEX IF EXISTS idx_transaction_items_stylist;
+
+ALTER TABLE transaction_items
+    DROP COLUMN IF EXISTS stylist,
+    DROP COLUMN IF EXISTS stylist_id;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Visit statistics kept on the customer so receipts and the customer list do not scan transactions.
+-- They are recomputed from the customer's non-void transactions whenever one is recorded, refunded or voided.
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS visit_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit DATE,
+    ADD COLUMN IF NOT EXISTS lifetime_spend BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_customer ON transactions (customer_id) WHERE customer_id IS NOT NULL;
+
+UPDATE customers c
+SET visit_count = s.visits, last_visit = s.last_visit, lifetime_spend = s.spend
+FROM (
+    SELECT customer_id, COUNT(*) AS visits, MAX(transacted_date) AS last_visit, COALESCE(SUM(amount - refunded_amount), 0) AS spend
+    FROM transactions
+    WHERE customer_id IS NOT NULL AND status <> 'void'
+    GROUP BY customer_id
+) s
+WHERE s.customer_id = c.id;
+
+ALTER TABLE draft_orders
+    ADD COLUMN IF NOT EXISTS customer_id BIGINT REFERENCES customers(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS customer_phone TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE draft_orders
+    DROP COLUMN IF EXISTS customer_phone,
+    DROP COLUMN IF EXISTS customer_id;
+
+DROP INDEX IF EXISTS idx_transactions_customer;
+
+ALTER TABLE customers
+    DROP COLUMN IF EXISTS lifetime_spend,
+    DROP COLUMN IF EXISTS last_visit,
+    DROP COLUMN IF EXISTS visit_count;
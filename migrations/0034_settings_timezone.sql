@@ -0,0 +1,9 @@
+-- +goose Up
+-- IANA timezone of the shop. Business dates (transaction dates, "today" on the dashboard and closing,
+-- attendance days) follow it instead of the server's or the database's clock.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'Asia/Jakarta';
+
+-- +goose Down
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS timezone;
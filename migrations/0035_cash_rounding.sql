@@ -0,0 +1,18 @@
+-- +goose Up
+-- Cash rounding: when rounding_price is on, the cash part of an order is rounded to rounding_unit rupiah
+-- (100, 500 or 1000) in rounding_mode (nearest, up or down). The signed difference is stored per
+-- transaction so the drawer reconciles with sales.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS rounding_unit INT NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS rounding_mode TEXT NOT NULL DEFAULT 'nearest';
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS rounding_adjustment BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS rounding_adjustment;
+
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS rounding_mode,
+    DROP COLUMN IF EXISTS rounding_unit;
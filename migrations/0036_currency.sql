@@ -0,0 +1,35 @@
+-- +goose Up
+-- Amounts are minor units of the currency they were recorded in. Existing rows take their owner's
+-- settings currency, so a shop that later changes currency does not silently relabel its history.
+UPDATE settings SET currency_code = upper(trim(currency_code));
+UPDATE settings SET currency_code = 'IDR' WHERE currency_code = '';
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE transaction_items
+    ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE finance_entries
+    ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+
+UPDATE transactions t
+SET currency = s.currency_code
+FROM settings s
+WHERE s.owner_user_id = t.owner_user_id AND s.currency_code <> 'IDR';
+
+UPDATE transaction_items ti
+SET currency = t.currency
+FROM transactions t
+WHERE t.id = ti.transaction_id AND t.currency <> 'IDR';
+
+UPDATE finance_entries f
+SET currency = s.currency_code
+FROM settings s
+WHERE s.owner_user_id = f.owner_user_id AND s.currency_code <> 'IDR';
+
+-- +goose Down
+ALTER TABLE finance_entries
+    DROP COLUMN IF EXISTS currency;
+ALTER TABLE transaction_items
+    DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Non-cash tenders collected through a payment provider. The id is ours and is what orders reference in
+-- transaction_payments.payment_intent_id; provider_ref is the provider's id for the same payment.
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id TEXT PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    provider_ref TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL CHECK (method IN ('qris','card')),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','paid','failed','cancelled','expired','refunded')),
+    qr_string TEXT NOT NULL DEFAULT '',
+    reference TEXT NOT NULL DEFAULT '',
+    refunded_amount BIGINT NOT NULL DEFAULT 0,
+    draft_id BIGINT REFERENCES draft_orders(id) ON DELETE SET NULL,
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_owner ON payment_intents (owner_user_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_intents_provider_ref ON payment_intents (provider, provider_ref) WHERE provider_ref <> '';
+
+-- +goose Down
+DROP TABLE IF EXISTS payment_intents;
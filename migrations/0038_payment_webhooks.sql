@@ -0,0 +1,34 @@
+-- +goose Up
+-- An order paid with a payment intent that has not settled yet is recorded as pending; the provider's
+-- webhook then moves it to paid, or to failed when the payment does not go through.
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('pending','paid','partially_refunded','refund','void','failed'));
+
+-- Every authentic webhook delivery, once per provider event id. Events that match no intent stay
+-- 'unmatched' with no owner until someone reviews them.
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    provider_ref TEXT NOT NULL DEFAULT '',
+    intent_ref TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    outcome TEXT NOT NULL DEFAULT 'received' CHECK (outcome IN ('received','applied','unmatched')),
+    owner_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    payment_intent_id TEXT REFERENCES payment_intents(id) ON DELETE SET NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_unmatched ON payment_webhook_events (received_at) WHERE outcome = 'unmatched';
+
+-- +goose Down
+DROP TABLE IF EXISTS payment_webhook_events;
+
+UPDATE transactions SET status='paid' WHERE status='pending';
+UPDATE transactions SET status='void' WHERE status='failed';
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_check CHECK (status IN ('paid','partially_refunded','refund','void'));
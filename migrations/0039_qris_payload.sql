@@ -0,0 +1,17 @@
+-- +goose Up
+-- The uploaded QRIS image columns were only ever created by hand; make sure they exist.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS qris_image BYTEA,
+    ADD COLUMN IF NOT EXISTS qris_image_mime TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS qris_image_updated_at TIMESTAMPTZ;
+
+-- The merchant's static QRIS as text, so a dynamic code with the amount filled in can be made per payment.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS qris_payload TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS qris_payload_updated_at TIMESTAMPTZ;
+
+-- +goose Down
+-- The image columns stay: the code relied on them before this migration.
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS qris_payload_updated_at,
+    DROP COLUMN IF EXISTS qris_payload;
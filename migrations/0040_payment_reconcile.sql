@@ -0,0 +1,14 @@
+-- +goose Up
+-- Set when the manager has been told about a payment the provider collected but no sale records.
+ALTER TABLE payment_intents
+    ADD COLUMN IF NOT EXISTS unrecorded_notified_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_pending ON payment_intents (updated_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_payment_intents_unrecorded ON payment_intents (paid_at)
+    WHERE status = 'paid' AND unrecorded_notified_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_payment_intents_unrecorded;
+DROP INDEX IF EXISTS idx_payment_intents_pending;
+ALTER TABLE payment_intents
+    DROP COLUMN IF EXISTS unrecorded_notified_at;
@@ -0,0 +1,53 @@
+-- +goose Up
+-- One cash drawer session per shift: the opening float, cash put in or taken out outside sales, and at
+-- closing the expected cash against what was counted.
+CREATE TABLE IF NOT EXISTS cash_drawer_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shift_id TEXT NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'IDR',
+    opening_float BIGINT NOT NULL CHECK (opening_float >= 0),
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open','closed')),
+    note TEXT NOT NULL DEFAULT '',
+    opened_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMPTZ,
+    expected_cash BIGINT,
+    counted_cash BIGINT,
+    variance BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cash_drawer_sessions_shift ON cash_drawer_sessions (owner_user_id, shift_id);
+CREATE INDEX IF NOT EXISTS idx_cash_drawer_sessions_open ON cash_drawer_sessions (owner_user_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS cash_drawer_movements (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES cash_drawer_sessions(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('pay_in','pay_out')),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cash_drawer_movements_session ON cash_drawer_movements (session_id, created_at);
+
+-- Closings made against a drawer keep the server's figures next to the cashier's.
+ALTER TABLE closing_history
+    ADD COLUMN IF NOT EXISTS cash_drawer_session_id BIGINT REFERENCES cash_drawer_sessions(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS expected_cash BIGINT,
+    ADD COLUMN IF NOT EXISTS counted_cash BIGINT,
+    ADD COLUMN IF NOT EXISTS variance BIGINT;
+
+-- +goose Down
+ALTER TABLE closing_history
+    DROP COLUMN IF EXISTS variance,
+    DROP COLUMN IF EXISTS counted_cash,
+    DROP COLUMN IF EXISTS expected_cash,
+    DROP COLUMN IF EXISTS cash_drawer_session_id;
+DROP TABLE IF EXISTS cash_drawer_movements;
+DROP TABLE IF EXISTS cash_drawer_sessions;
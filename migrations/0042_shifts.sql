@@ -0,0 +1,31 @@
+-- +goose Up
+-- Shifts are opened and closed on the server, which hands out their IDs. A terminal (one POS device) has
+-- at most one open shift. shift_id on transactions, drafts, cash drawers and closings stays TEXT: rows from
+-- before this migration carry whatever the client sent.
+CREATE TABLE IF NOT EXISTS shifts (
+    id TEXT PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    terminal_id TEXT NOT NULL,
+    operator_name TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open','closed')),
+    note TEXT NOT NULL DEFAULT '',
+    opened_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_terminal ON shifts (owner_user_id, terminal_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_shifts_owner_opened ON shifts (owner_user_id, opened_at DESC);
+
+-- What an order without an open shift gets: off (accepted as before), reject, or auto (joins the open shift).
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS shift_policy TEXT NOT NULL DEFAULT 'off';
+
+-- +goose Down
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS shift_policy;
+
+DROP TABLE IF EXISTS shifts;
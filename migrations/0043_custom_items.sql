@@ -0,0 +1,15 @@
+-- +goose Up
+-- Lines without a product are priced by the client, so staff may only ring them up when the owner allows it
+-- (managers always may). Such lines are flagged on the sale.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS allow_custom_items BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE transaction_items
+    ADD COLUMN IF NOT EXISTS custom BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE transaction_items
+    DROP COLUMN IF EXISTS custom;
+
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS allow_custom_items;
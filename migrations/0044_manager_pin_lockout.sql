@@ -0,0 +1,11 @@
+-- +goose Up
+-- Manager PIN attempts are counted so a staff device cannot guess the PIN: enough wrong ones in a row lock PIN
+-- entry until manager_pin_locked_until. A correct PIN or a new PIN clears both.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS manager_pin_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS manager_pin_locked_until TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS manager_pin_locked_until,
+    DROP COLUMN IF EXISTS manager_pin_failures;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Manual line and order discounts are the cashier's call, so staff may only give them when the owner allows
+-- it (managers always may).
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS allow_manual_discounts BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE settings
+    DROP COLUMN IF EXISTS allow_manual_discounts;
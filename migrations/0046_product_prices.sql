@@ -0,0 +1,15 @@
+-- +goose Up
+-- Catalog price changes, so orders synced from the offline queue are priced as they were when rung up.
+-- A product's first row is its price since it was created, written when the price first changes;
+-- products without rows have never changed price.
+CREATE TABLE IF NOT EXISTS product_prices (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price BIGINT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices (product_id, valid_from DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS product_prices;
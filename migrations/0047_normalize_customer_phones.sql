@@ -0,0 +1,51 @@
+-- +goose Up
+-- Customers saved before phones were normalized may hold "0812-345 678" next to "0812345678".
+-- Phones are brought to the form NormalizePhone stores (trimmed, without spaces, dashes, dots or brackets),
+-- and customers of one owner that end up with the same number are merged into the oldest live one,
+-- which takes over the others' transactions and draft orders.
+CREATE TABLE customer_phone_merges AS
+SELECT id, keep_id
+FROM (
+    SELECT id, first_value(id) OVER (
+        PARTITION BY owner_user_id, phone
+        ORDER BY deleted_at IS NOT NULL, created_at, id
+    ) AS keep_id
+    FROM (
+        SELECT id, owner_user_id, created_at, deleted_at,
+               regexp_replace(regexp_replace(phone, '^\s+|\s+$', '', 'g'), '[ .()-]', '', 'g') AS phone
+        FROM customers
+    ) c
+) m
+WHERE id <> keep_id;
+
+UPDATE transactions t
+SET customer_id = m.keep_id
+FROM customer_phone_merges m
+WHERE t.customer_id = m.id;
+
+UPDATE draft_orders d
+SET customer_id = m.keep_id
+FROM customer_phone_merges m
+WHERE d.customer_id = m.id;
+
+DELETE FROM customers WHERE id IN (SELECT id FROM customer_phone_merges);
+
+UPDATE customers
+SET phone = regexp_replace(regexp_replace(phone, '^\s+|\s+$', '', 'g'), '[ .()-]', '', 'g'),
+    updated_at = now()
+WHERE phone <> regexp_replace(regexp_replace(phone, '^\s+|\s+$', '', 'g'), '[ .()-]', '', 'g');
+
+UPDATE customers c
+SET visit_count = s.visits, last_visit = s.last_visit, lifetime_spend = s.spend, updated_at = now()
+FROM (
+    SELECT customer_id, COUNT(*) AS visits, MAX(transacted_date) AS last_visit, COALESCE(SUM(amount - refunded_amount), 0) AS spend
+    FROM transactions
+    WHERE customer_id IN (SELECT keep_id FROM customer_phone_merges) AND status NOT IN ('pending','void','failed')
+    GROUP BY customer_id
+) s
+WHERE s.customer_id = c.id;
+
+DROP TABLE customer_phone_merges;
+
+-- +goose Down
+-- Merged customers cannot be told apart again; phones stay normalized.